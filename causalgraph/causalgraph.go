@@ -1,7 +1,10 @@
 package causalgraph
 
 import (
+	"container/heap"
 	"fmt"
+	"math"
+	"slices"
 	"sort"
 )
 
@@ -9,11 +12,26 @@ import (
 func CreateCG() *CausalGraph {
 	return &CausalGraph{
 		AgentToVersion: make(map[AgentID][]ClientEntry),
-		// Entries and Heads are initialized as empty slices by default.
-		// NextLV starts at 0.
+		// Entries, Heads and the agent table are initialized as empty slices
+		// by default. NextLV starts at 0.
 	}
 }
 
+// internAgent returns the copy of agent in the agent table of cg, adding
+// the agent if it is new. A graph built from its exported fields starts with
+// an empty table, which fills up as AddRaw sees its agents.
+func internAgent(cg *CausalGraph, agent AgentID) AgentID {
+	if idx, ok := cg.agentIndexes[agent]; ok {
+		return cg.agents[idx]
+	}
+	if cg.agentIndexes == nil {
+		cg.agentIndexes = make(map[AgentID]agentIndex)
+	}
+	cg.agentIndexes[agent] = agentIndex(len(cg.agents))
+	cg.agents = append(cg.agents, agent)
+	return agent
+}
+
 // NextLV returns the next available local version (LV) in the graph.
 // It's equivalent to the total number of versions assigned so far.
 func NextLV(cg *CausalGraph) LV {
@@ -108,7 +126,7 @@ func LVToRawWithParents(cg *CausalGraph, v LV) (AgentID, int, []LV, bool) {
 func RawToLV(cg *CausalGraph, agent AgentID, seq int) (LV, error) {
 	entry, offset, found := findEntryContainingRaw(cg, agent, seq)
 	if !found || entry == nil {
-		return -1, fmt.Errorf("raw version %s:%d not found in causal graph", agent, seq)
+		return -1, &ErrUnknownVersion{Raw: RawVersion{Agent: agent, Seq: seq}}
 	}
 	return entry.Version + LV(offset), nil
 }
@@ -123,7 +141,7 @@ func LVToRawList(cg *CausalGraph, lvs []LV) ([]RawVersion, error) {
 	for i, lv := range lvs {
 		rv, found := LVToRaw(cg, lv)
 		if !found {
-			return nil, fmt.Errorf("failed to convert LV %d to RawVersion: not found", lv)
+			return nil, fmt.Errorf("LVToRawList: %w", &ErrLVOutOfRange{LV: lv, NextLV: cg.NextLV})
 		}
 		raws[i] = rv
 	}
@@ -133,11 +151,19 @@ func LVToRawList(cg *CausalGraph, lvs []LV) ([]RawVersion, error) {
 // AddRaw adds a new version span to the causal graph.
 func AddRaw(cg *CausalGraph, id RawVersion, length int, rawParents []RawVersion) (*CGEntry, error) {
 	if length <= 0 {
-		return nil, fmt.Errorf("length must be positive")
+		return nil, fmt.Errorf("AddRaw: %w: length must be positive, got %d", ErrInvalidSpan, length)
 	}
 
 	if id.Seq < 0 {
-		return nil, fmt.Errorf("sequence number cannot be negative: %d", id.Seq)
+		return nil, fmt.Errorf("AddRaw: %w: sequence number cannot be negative: %d", ErrInvalidSpan, id.Seq)
+	}
+
+	if id.Agent == "" {
+		return nil, fmt.Errorf("AddRaw: %w", &ErrInvalidAgentID{Agent: id.Agent, Reason: "empty"})
+	}
+
+	if id.Seq > math.MaxInt-length || int(cg.NextLV) > math.MaxInt-length {
+		return nil, fmt.Errorf("AddRaw: %w: span of length %d at sequence number %d overflows", ErrInvalidSpan, length, id.Seq)
 	}
 
 	expectedSeq := NextSeqForAgent(cg, id.Agent)
@@ -148,7 +174,15 @@ func AddRaw(cg *CausalGraph, id RawVersion, length int, rawParents []RawVersion)
 		// This also implicitly handles re-adding an identical operation if it were to start at an earlier seq,
 		// though re-adding an identical op starting at expectedSeq would be caught if we checked RawToLV
 		// *after* confirming seq is expected. However, by definition, if seq is expected, it's new.
-		return nil, fmt.Errorf("out of order sequence number for agent %s: expected %d, got %d", id.Agent, expectedSeq, id.Seq)
+		return nil, fmt.Errorf("AddRaw: %w", seqError(id.Agent, expectedSeq, id.Seq, length))
+	}
+
+	// Limits are checked after the sequence number, so a span the graph
+	// already has is reported as such even when the graph is full.
+	if cg.Limits != nil {
+		if err := cg.Limits.checkSpan(cg, id.Agent, length); err != nil {
+			return nil, fmt.Errorf("AddRaw: %w", err)
+		}
 	}
 
 	var parentLVs []LV
@@ -160,18 +194,25 @@ func AddRaw(cg *CausalGraph, id RawVersion, length int, rawParents []RawVersion)
 		for _, rp := range rawParents {
 			lv, err := RawToLV(cg, rp.Agent, rp.Seq)
 			if err != nil {
-				return nil, fmt.Errorf("parent %s:%d not found: %w", rp.Agent, rp.Seq, err)
+				return nil, fmt.Errorf("AddRaw: %w", &ErrUnknownParent{Raw: rp})
 			}
 			parentLVs = append(parentLVs, lv)
 		}
 	}
 	parentLVs = sortLVsAndDedup(parentLVs)
+	// The graph's own heads are not checked: they are not sent by a peer.
+	if cg.Limits != nil && rawParents != nil {
+		if err := cg.Limits.checkParents(len(parentLVs)); err != nil {
+			return nil, fmt.Errorf("AddRaw: %w", err)
+		}
+	}
 
 	startLV := cg.NextLV
 	endLV := startLV + LV(length)
 
+	agent := internAgent(cg, id.Agent)
 	newEntry := CGEntry{
-		Agent:   id.Agent,
+		Agent:   agent,
 		Seq:     id.Seq,
 		Version: startLV,
 		VEnd:    endLV,
@@ -184,8 +225,7 @@ func AddRaw(cg *CausalGraph, id RawVersion, length int, rawParents []RawVersion)
 
 	cg.NextLV = endLV
 
-	clientEntries, _ := cg.AgentToVersion[id.Agent]
-	clientEntries = append(clientEntries, ClientEntry{
+	clientEntries := append(cg.AgentToVersion[agent], ClientEntry{
 		Seq:     id.Seq,
 		SeqEnd:  id.Seq + length,
 		Version: startLV,
@@ -193,9 +233,9 @@ func AddRaw(cg *CausalGraph, id RawVersion, length int, rawParents []RawVersion)
 	sort.Slice(clientEntries, func(i, j int) bool {
 		return clientEntries[i].Seq < clientEntries[j].Seq
 	})
-	cg.AgentToVersion[id.Agent] = clientEntries
+	cg.AgentToVersion[agent] = clientEntries
 
-	newHeads := make([]LV, 0, len(cg.Heads)+length) // Max capacity
+	newHeads := make([]LV, 0, len(cg.Heads)+1) // Max capacity
 	for _, h := range cg.Heads {
 		isParent := false
 		for _, p := range parentLVs {
@@ -208,15 +248,15 @@ func AddRaw(cg *CausalGraph, id RawVersion, length int, rawParents []RawVersion)
 			newHeads = append(newHeads, h)
 		}
 	}
-	for i := 0; i < length; i++ {
-		newHeads = append(newHeads, startLV+LV(i))
-	}
+	// Versions within the span each have the previous one as parent, so only
+	// the last one is a head.
+	newHeads = append(newHeads, endLV-1)
 	cg.Heads = sortLVsAndDedup(newHeads)
 
 	idx := sort.Search(len(cg.Entries), func(i int) bool {
 		return cg.Entries[i].Version >= startLV
 	})
-	if idx < len(cg.Entries) && cg.Entries[idx].Version == startLV && cg.Entries[idx].Agent == id.Agent {
+	if idx < len(cg.Entries) && cg.Entries[idx].Version == startLV && cg.Entries[idx].Agent == agent {
 		return &cg.Entries[idx], nil
 	}
 
@@ -250,13 +290,13 @@ func VersionContainsLV(cg *CausalGraph, frontier []LV, targetLV LV) (bool, error
 		// If cg.NextLV is 0, any non-negative targetLV is out of bounds.
 		// If targetLV is negative, it's always out of bounds.
 		if targetLV < 0 || (cg.NextLV == 0 && targetLV >= 0) || (cg.NextLV > 0 && targetLV >= cg.NextLV) {
-			return false, fmt.Errorf("targetLV %d is out of bounds for graph with %d LVs", targetLV, cg.NextLV)
+			return false, fmt.Errorf("VersionContainsLV: target %w", &ErrLVOutOfRange{LV: targetLV, NextLV: cg.NextLV})
 		}
 	}
 
 	for _, fv := range frontier {
 		if fv < 0 || fv >= cg.NextLV {
-			return false, fmt.Errorf("frontier LV %d is out of bounds for graph with %d LVs", fv, cg.NextLV)
+			return false, fmt.Errorf("VersionContainsLV: frontier %w", &ErrLVOutOfRange{LV: fv, NextLV: cg.NextLV})
 		}
 		if fv == targetLV {
 			return true, nil
@@ -321,7 +361,7 @@ func SummarizeVersion(cg *CausalGraph, frontier []LV) (VersionSummary, error) {
 
 	for _, fv := range frontier {
 		if fv < 0 || fv >= cg.NextLV {
-			return nil, fmt.Errorf("frontier LV %d is out of bounds for graph with %d LVs", fv, cg.NextLV)
+			return nil, fmt.Errorf("SummarizeVersion: frontier %w", &ErrLVOutOfRange{LV: fv, NextLV: cg.NextLV})
 		}
 	}
 
@@ -397,12 +437,12 @@ func Diff(cg *CausalGraph, from []LV, to VersionSummary) ([]LVRange, error) {
 	for _, v := range from {
 		if cg.NextLV == 0 { // Empty graph
 			if v != 0 {
-				return nil, fmt.Errorf("Diff: 'from' LV %d is invalid for an empty graph (must be 0)", v)
+				return nil, fmt.Errorf("Diff: 'from' %w", &ErrLVOutOfRange{LV: v, NextLV: cg.NextLV})
 			}
 			// If v is 0 and graph is empty, it's a valid 'from' for an empty diff.
 		} else { // Non-empty graph
 			if v < 0 || v >= cg.NextLV {
-				return nil, fmt.Errorf("Diff: 'from' LV %d is out of bounds for graph with %d LVs", v, cg.NextLV)
+				return nil, fmt.Errorf("Diff: 'from' %w", &ErrLVOutOfRange{LV: v, NextLV: cg.NextLV})
 			}
 		}
 
@@ -446,12 +486,10 @@ func Diff(cg *CausalGraph, from []LV, to VersionSummary) ([]LVRange, error) {
 		for lvIter := entry.Version; lvIter < entry.VEnd; lvIter++ {
 			seqIter := entry.Seq + int(lvIter-entry.Version)
 			isLVCoveredByTo := false
-			if ranges, ok := to[entry.Agent]; ok {
-				for _, r := range ranges {
-					if seqIter >= r[0] && seqIter < r[1] {
-						isLVCoveredByTo = true
-						break
-					}
+			for _, r := range to[entry.Agent] {
+				if seqIter >= r[0] && seqIter < r[1] {
+					isLVCoveredByTo = true
+					break
 				}
 			}
 
@@ -475,14 +513,12 @@ func Diff(cg *CausalGraph, from []LV, to VersionSummary) ([]LVRange, error) {
 			for _, p := range entry.Parents {
 				if _, qProc := processedInQueue[p]; !qProc && p >= 0 {
 					pIsCoveredByTo := false
-					pRaw, pFound := LVToRaw(cg, p)
-					if pFound {
-						if ranges, ok := to[pRaw.Agent]; ok {
-							for _, r := range ranges {
-								if pRaw.Seq >= r[0] && pRaw.Seq < r[1] {
-									pIsCoveredByTo = true
-									break
-								}
+					if pEntry, pOffset, pFound := findEntryContaining(cg, p); pFound {
+						pSeq := pEntry.Seq + pOffset
+						for _, r := range to[pEntry.Agent] {
+							if pSeq >= r[0] && pSeq < r[1] {
+								pIsCoveredByTo = true
+								break
 							}
 						}
 					}
@@ -530,7 +566,7 @@ func FindDominators(cg *CausalGraph, versions []LV) ([]LV, error) {
 	if len(uniqueVersions) == 1 {
 		v := uniqueVersions[0]
 		if v < 0 || v >= cg.NextLV {
-			return nil, fmt.Errorf("version %d not found in graph", v)
+			return nil, fmt.Errorf("FindDominators: %w", &ErrLVOutOfRange{LV: v, NextLV: cg.NextLV})
 		}
 		return []LV{v}, nil
 	}
@@ -538,7 +574,7 @@ func FindDominators(cg *CausalGraph, versions []LV) ([]LV, error) {
 	ancestorSets := make([]map[LV]struct{}, len(uniqueVersions))
 	for i, v := range uniqueVersions {
 		if v < 0 || v >= cg.NextLV {
-			return nil, fmt.Errorf("version %d not found in graph or invalid", v)
+			return nil, fmt.Errorf("FindDominators: %w", &ErrLVOutOfRange{LV: v, NextLV: cg.NextLV})
 		}
 		set := make(map[LV]struct{})
 		q := []LV{v}
@@ -623,6 +659,36 @@ func FindDominators(cg *CausalGraph, versions []LV) ([]LV, error) {
 	return sortLVsAndDedup(dominators), nil
 }
 
+// FindMaximal returns, sorted, the versions in versions which are not
+// ancestors of another version in versions. For a set of concurrent writes,
+// these are the writes no other write has seen.
+func FindMaximal(cg *CausalGraph, versions []LV) ([]LV, error) {
+	unique := sortLVsAndDedup(append([]LV(nil), versions...))
+	var maximal []LV
+	// A version can only be an ancestor of higher versions, and an ancestor of
+	// a dominated version is also an ancestor of whatever dominates it, so each
+	// version only needs checking against the maximal ones above it.
+	for i := len(unique) - 1; i >= 0; i-- {
+		v := unique[i]
+		if v < 0 || v >= cg.NextLV {
+			return nil, fmt.Errorf("FindMaximal: %w", &ErrLVOutOfRange{LV: v, NextLV: cg.NextLV})
+		}
+		dominated := false
+		if len(maximal) > 0 {
+			var err error
+			dominated, err = VersionContainsLV(cg, maximal, v)
+			if err != nil {
+				return nil, fmt.Errorf("FindMaximal: %w", err)
+			}
+		}
+		if !dominated {
+			maximal = append(maximal, v)
+		}
+	}
+	slices.Reverse(maximal)
+	return maximal, nil
+}
+
 // FindConflicting returns operations in `versions` that are not descendants of `commonAncestors`.
 func FindConflicting(cg *CausalGraph, versions []LV, commonAncestors []LV) ([]LVRange, error) {
 	summary, err := SummarizeVersion(cg, commonAncestors)
@@ -743,14 +809,14 @@ func IterVersionsBetween(cg *CausalGraph, from []LV, to LV,
 		// but if cg.NextLV > 0, then to >= cg.NextLV is out of bounds.
 		// If cg.NextLV == 0 and to is not 0, it's out of bounds.
 		if !(cg.NextLV == 0 && to == 0) { // Special case: to=0 is valid for empty graph if from is also empty or contains 0
-			return fmt.Errorf("IterVersionsBetween: 'to' LV %d is out of bounds for graph with %d LVs", to, cg.NextLV)
+			return fmt.Errorf("IterVersionsBetween: 'to' %w", &ErrLVOutOfRange{LV: to, NextLV: cg.NextLV})
 		}
 	}
 
 	for _, fv := range from {
 		if fv < 0 || (cg.NextLV > 0 && fv >= cg.NextLV) || (cg.NextLV == 0 && fv != 0) {
 			if !(cg.NextLV == 0 && fv == 0) {
-				return fmt.Errorf("IterVersionsBetween: 'from' LV %d is out of bounds for graph with %d LVs", fv, cg.NextLV)
+				return fmt.Errorf("IterVersionsBetween: 'from' %w", &ErrLVOutOfRange{LV: fv, NextLV: cg.NextLV})
 			}
 		}
 		if fv == to {
@@ -818,12 +884,10 @@ func IntersectWithSummaryFull(cg *CausalGraph, summary VersionSummary) ([]CGEntr
 
 			seqIter := entry.Seq + int(lvIter-entry.Version)
 			isCovered := false
-			if ranges, ok := summary[entry.Agent]; ok {
-				for _, r := range ranges {
-					if seqIter >= r[0] && seqIter < r[1] {
-						isCovered = true
-						break
-					}
+			for _, r := range summary[entry.Agent] {
+				if seqIter >= r[0] && seqIter < r[1] {
+					isCovered = true
+					break
 				}
 			}
 
@@ -903,3 +967,126 @@ func IntersectWithSummary(cg *CausalGraph, summary VersionSummary) ([]LV, error)
 	}
 	return sortLVsAndDedup(lvs), nil
 }
+
+// diffFlag records which side(s) of a DiffVersions query a version was reached from.
+type diffFlag uint8
+
+const (
+	diffOnlyA diffFlag = iota
+	diffOnlyB
+	diffShared
+)
+
+// lvMaxHeap is a max-heap of LVs used to walk the graph from newest to oldest.
+type lvMaxHeap []LV
+
+func (h lvMaxHeap) Len() int           { return len(h) }
+func (h lvMaxHeap) Less(i, j int) bool { return h[i] > h[j] }
+func (h lvMaxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *lvMaxHeap) Push(x any)        { *h = append(*h, x.(LV)) }
+func (h *lvMaxHeap) Pop() any {
+	old := *h
+	v := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return v
+}
+
+// DiffVersions compares the histories of two frontiers. It returns the ranges of
+// versions which are only in the history of a and the ranges only in the history
+// of b. Both results are sorted by LV and contain no overlapping ranges.
+func DiffVersions(cg *CausalGraph, a, b []LV) (aOnly, bOnly []LVRange, err error) {
+	for _, v := range a {
+		if v < 0 || v >= cg.NextLV {
+			return nil, nil, fmt.Errorf("DiffVersions: in a: %w", &ErrLVOutOfRange{LV: v, NextLV: cg.NextLV})
+		}
+	}
+	for _, v := range b {
+		if v < 0 || v >= cg.NextLV {
+			return nil, nil, fmt.Errorf("DiffVersions: in b: %w", &ErrLVOutOfRange{LV: v, NextLV: cg.NextLV})
+		}
+	}
+
+	flags := make(map[LV]diffFlag)
+	queue := &lvMaxHeap{}
+	numShared := 0
+
+	enqueue := func(v LV, flag diffFlag) {
+		current, ok := flags[v]
+		if !ok {
+			heap.Push(queue, v)
+			flags[v] = flag
+			if flag == diffShared {
+				numShared++
+			}
+		} else if flag != current && current != diffShared {
+			flags[v] = diffShared
+			numShared++
+		}
+	}
+	for _, v := range a {
+		enqueue(v, diffOnlyA)
+	}
+	for _, v := range b {
+		enqueue(v, diffOnlyB)
+	}
+
+	// Runs are discovered from newest to oldest, so they are collected in
+	// descending order and reversed at the end.
+	markRun := func(start, endInclusive LV, flag diffFlag) {
+		if flag == diffShared || endInclusive < start {
+			return
+		}
+		target := &aOnly
+		if flag == diffOnlyB {
+			target = &bOnly
+		}
+		if n := len(*target); n > 0 && (*target)[n-1].Start == endInclusive+1 {
+			(*target)[n-1].Start = start
+		} else {
+			*target = append(*target, LVRange{Start: start, End: endInclusive + 1})
+		}
+	}
+
+	for queue.Len() > numShared {
+		v := heap.Pop(queue).(LV)
+		flag := flags[v]
+		if flag == diffShared {
+			numShared--
+		}
+
+		entry, _, found := findEntryContaining(cg, v)
+		if !found {
+			return nil, nil, fmt.Errorf("DiffVersions: LV %d not found in graph", v)
+		}
+
+		// Other queued versions inside this entry are reached by walking back
+		// along it, so they are folded in here rather than visited separately.
+		for queue.Len() > 0 && (*queue)[0] >= entry.Version {
+			v2 := heap.Pop(queue).(LV)
+			flag2 := flags[v2]
+			if flag2 == diffShared {
+				numShared--
+			}
+			if flag2 != flag {
+				markRun(v2+1, v, flag)
+				v = v2
+				flag = diffShared
+			}
+		}
+
+		markRun(entry.Version, v, flag)
+		for _, p := range entry.Parents {
+			enqueue(p, flag)
+		}
+	}
+
+	reverseLVRanges(aOnly)
+	reverseLVRanges(bOnly)
+	return aOnly, bOnly, nil
+}
+
+func reverseLVRanges(ranges []LVRange) {
+	for i, j := 0, len(ranges)-1; i < j; i, j = i+1, j-1 {
+		ranges[i], ranges[j] = ranges[j], ranges[i]
+	}
+}
@@ -1,9 +1,12 @@
 package causalgraph
 
 import (
+	"errors"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
+	"unsafe"
 )
 
 // Helper function to check deep equality for slices of LV, as direct == doesn't work.
@@ -184,6 +187,23 @@ func TestAddRaw_SingleEntry(t *testing.T) {
 	}
 }
 
+func TestAddRaw_SpanHeads(t *testing.T) {
+	cg := CreateCG()
+	if _, err := AddRaw(cg, RawVersion{Agent: "A", Seq: 0}, 3, []RawVersion{}); err != nil {
+		t.Fatalf("AddRaw failed: %v", err)
+	}
+	// Only the last version of a span is a head.
+	if want := []LV{2}; !compareLVSlices(cg.Heads, want) {
+		t.Errorf("expected Heads %v, got %v", want, cg.Heads)
+	}
+	if _, err := AddRaw(cg, RawVersion{Agent: "B", Seq: 0}, 2, []RawVersion{{Agent: "A", Seq: 0}}); err != nil {
+		t.Fatalf("AddRaw failed: %v", err)
+	}
+	if want := []LV{2, 4}; !compareLVSlices(cg.Heads, want) {
+		t.Errorf("expected Heads %v, got %v", want, cg.Heads)
+	}
+}
+
 func TestAddRaw_AdvancedScenarios(t *testing.T) {
 	agentA := AgentID("agentA")
 	agentB := AgentID("agentB")
@@ -198,7 +218,16 @@ func TestAddRaw_AdvancedScenarios(t *testing.T) {
 		if err == nil {
 			t.Errorf("Expected error when adding overlapping operation (A, seq 1) after (A, seq 0, len 3), but got nil")
 		}
-		// TODO: Check specific error message if it becomes part of the API contract, e.g., strings.Contains(err.Error(), "out of order")
+		var overlap *ErrSeqOverlap
+		if !errors.As(err, &overlap) || overlap.Expected != 3 || overlap.Got != 1 {
+			t.Errorf("Expected ErrSeqOverlap{Expected: 3, Got: 1}, got %v", err)
+		}
+
+		// A2..A4 is only partly known, so it is not a duplicate.
+		_, err = AddRaw(cg, RawVersion{Agent: agentA, Seq: 2}, 3, nil)
+		if !errors.As(err, &overlap) || overlap.Length != 3 || overlap.Duplicate() {
+			t.Errorf("Expected a partial ErrSeqOverlap of length 3, got %v", err)
+		}
 	})
 
 	// Scenario 2: Attempting to add a contained operation
@@ -218,8 +247,9 @@ func TestAddRaw_AdvancedScenarios(t *testing.T) {
 		_, _ = AddRaw(cg, RawVersion{Agent: agentA, Seq: 0}, 3, nil) // A0, A1, A2. NextSeq for A is 3.
 
 		_, err := AddRaw(cg, RawVersion{Agent: agentA, Seq: 0}, 3, nil) // Try to add A0-A2 again
-		if err == nil {
-			t.Errorf("Expected error when re-adding identical operation (A, seq 0, len 3), but got nil")
+		var overlap *ErrSeqOverlap
+		if !errors.As(err, &overlap) || !overlap.Duplicate() {
+			t.Errorf("Expected a duplicate ErrSeqOverlap when re-adding identical operation (A, seq 0, len 3), got %v", err)
 		}
 	})
 
@@ -232,7 +262,10 @@ func TestAddRaw_AdvancedScenarios(t *testing.T) {
 		if err == nil {
 			t.Errorf("Expected error when adding operation with a gap in sequence (A, seq 2 after A, seq 0), but got nil")
 		}
-		// TODO: Check specific error message, e.g., strings.Contains(err.Error(), "gap in sequence numbers")
+		var gap *ErrSeqGap
+		if !errors.As(err, &gap) || gap.Agent != agentA || gap.Expected != 1 || gap.Got != 2 {
+			t.Errorf("Expected ErrSeqGap{%s, 1, 2}, got %v", agentA, err)
+		}
 	})
 
 	// Scenario 5: Valid sequential add (control case)
@@ -304,7 +337,10 @@ func TestAddRaw_AdvancedScenarios(t *testing.T) {
 		if err == nil {
 			t.Errorf("Expected error when adding operation with unknown raw parent, but got nil")
 		}
-		// TODO: Check specific error message if desired
+		var unknown *ErrUnknownParent
+		if !errors.As(err, &unknown) || unknown.Raw != parentsRaw[0] || !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrUnknownParent{%v}, got %v", parentsRaw[0], err)
+		}
 	})
 
 	// Scenario 8: Adding an operation with a non-existent agent in parent RawVersion
@@ -326,16 +362,73 @@ func TestAddRaw_AdvancedScenarios(t *testing.T) {
 		if errZero == nil {
 			t.Errorf("Expected error for length 0, but got nil")
 		}
-		// TODO: Check specific error message if desired, e.g., strings.Contains(errZero.Error(), "length must be positive")
+		if !errors.Is(errZero, ErrInvalidSpan) {
+			t.Errorf("Expected ErrInvalidSpan for length 0, got %v", errZero)
+		}
 
 		_, errNegative := AddRaw(cg, RawVersion{Agent: agentA, Seq: 0}, -1, nil)
 		if errNegative == nil {
 			t.Errorf("Expected error for negative length, but got nil")
 		}
-		// TODO: Check specific error message if desired
+		if !errors.Is(errNegative, ErrInvalidSpan) {
+			t.Errorf("Expected ErrInvalidSpan for negative length, got %v", errNegative)
+		}
 	})
 }
 
+func TestAgentTable(t *testing.T) {
+	cg := CreateCG()
+	agentA := AgentID("agentA")
+	agentB := AgentID("agentB")
+	// Each span carries its own copy of the agent ID, as when decoded from
+	// the network.
+	id := func(agent AgentID, seq int) RawVersion {
+		return RawVersion{Agent: AgentID(strings.Clone(string(agent))), Seq: seq}
+	}
+	if _, err := AddRaw(cg, id(agentA, 0), 2, nil); err != nil {
+		t.Fatalf("AddRaw failed: %v", err)
+	}
+	if _, err := AddRaw(cg, id(agentB, 0), 1, []RawVersion{}); err != nil {
+		t.Fatalf("AddRaw failed: %v", err)
+	}
+	if _, err := AddRaw(cg, id(agentA, 2), 1, nil); err != nil {
+		t.Fatalf("AddRaw failed: %v", err)
+	}
+
+	// Each agent is stored once, however many runs it has.
+	if want := []AgentID{agentA, agentB}; !reflect.DeepEqual(cg.agents, want) {
+		t.Errorf("expected agent table %v, got %v", want, cg.agents)
+	}
+	if unsafe.StringData(string(cg.Entries[0].Agent)) != unsafe.StringData(string(cg.Entries[2].Agent)) {
+		t.Errorf("expected the runs of %s to share one copy of its ID", agentA)
+	}
+	if len(cg.AgentToVersion[agentA]) != 2 {
+		t.Errorf("expected 2 runs for %s, got %v", agentA, cg.AgentToVersion[agentA])
+	}
+
+	// The public API still speaks agent IDs.
+	raw, ok := LVToRaw(cg, 3)
+	if !ok || raw != (RawVersion{Agent: agentA, Seq: 2}) {
+		t.Errorf("LVToRaw(3) = %v, %v", raw, ok)
+	}
+	if lv, err := RawToLV(cg, agentB, 0); err != nil || lv != 2 {
+		t.Errorf("RawToLV(%s, 0) = %d, %v", agentB, lv, err)
+	}
+
+	// A graph built from the exported fields works without the table, and
+	// fills it as AddRaw sees its agents.
+	loaded := &CausalGraph{Heads: cg.Heads, Entries: cg.Entries, AgentToVersion: cg.AgentToVersion, NextLV: cg.NextLV}
+	if lv, err := RawToLV(loaded, agentA, 2); err != nil || lv != 3 {
+		t.Errorf("RawToLV(%s, 2) on a loaded graph = %d, %v", agentA, lv, err)
+	}
+	if _, err := AddRaw(loaded, id(agentB, 1), 1, nil); err != nil {
+		t.Fatalf("AddRaw on a loaded graph failed: %v", err)
+	}
+	if want := []AgentID{agentB}; !reflect.DeepEqual(loaded.agents, want) {
+		t.Errorf("expected agent table %v on the loaded graph, got %v", want, loaded.agents)
+	}
+}
+
 func TestRawToLV_ErrorCases(t *testing.T) {
 	cg := setupTestGraphG1(t) // G1: A0(0) -> B0(1), A0(0) -> A1(2), (B0(1),A1(2)) -> C0(3)
 	agentA := AgentID("agentA")
@@ -723,6 +816,85 @@ func TestDiff(t *testing.T) {
 	}
 }
 
+func TestDiffVersions(t *testing.T) {
+	g1 := setupTestGraphG1(t)
+	g2 := setupTestGraphG2(t)
+	g4 := setupTestGraphG4(t)
+
+	tests := []struct {
+		name      string
+		cg        *CausalGraph
+		a, b      []LV
+		wantAOnly []LVRange
+		wantBOnly []LVRange
+		wantErr   bool
+	}{
+		{
+			name:      "G1_Concurrent_Branches",
+			cg:        g1,
+			a:         []LV{1}, // B0
+			b:         []LV{2}, // A1
+			wantAOnly: []LVRange{{Start: 1, End: 2}},
+			wantBOnly: []LVRange{{Start: 2, End: 3}},
+		},
+		{
+			name:      "G1_Root_To_Merge",
+			cg:        g1,
+			a:         []LV{},
+			b:         []LV{3},
+			wantBOnly: []LVRange{{Start: 0, End: 4}},
+		},
+		{
+			name:      "G1_Merge_Vs_Branch",
+			cg:        g1,
+			a:         []LV{3},
+			b:         []LV{1},
+			wantAOnly: []LVRange{{Start: 2, End: 4}},
+		},
+		{
+			name:      "G2_Inside_Entry",
+			cg:        g2,
+			a:         []LV{1},
+			b:         []LV{4},
+			wantBOnly: []LVRange{{Start: 2, End: 5}},
+		},
+		{
+			name: "G2_Equal",
+			cg:   g2,
+			a:    []LV{4},
+			b:    []LV{4},
+		},
+		{
+			name:      "G4_Independent_Roots",
+			cg:        g4,
+			a:         []LV{0},
+			b:         []LV{0, 1},
+			wantBOnly: []LVRange{{Start: 1, End: 2}},
+		},
+		{
+			name:    "Out_Of_Bounds",
+			cg:      g1,
+			a:       []LV{0},
+			b:       []LV{10},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aOnly, bOnly, err := DiffVersions(tt.cg, tt.a, tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DiffVersions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			compareLVRangeSlices(t, aOnly, tt.wantAOnly)
+			compareLVRangeSlices(t, bOnly, tt.wantBOnly)
+		})
+	}
+}
+
 func TestFindDominators(t *testing.T) {
 	g1 := setupTestGraphG1(t)
 	g3 := setupTestGraphG3(t)
@@ -842,6 +1014,38 @@ func TestFindDominators(t *testing.T) {
 	}
 }
 
+func TestFindMaximal(t *testing.T) {
+	g1 := setupTestGraphG1(t) // A0(0) -> B0(1), A0(0) -> A1(2), (B0(1),A1(2)) -> C0(3)
+	g2 := setupTestGraphG2(t) // A0-2(0,1,2) -> B0-1(3,4)
+
+	tests := []struct {
+		name     string
+		cg       *CausalGraph
+		versions []LV
+		want     []LV
+		wantErr  bool
+	}{
+		{"Empty", g1, nil, nil, false},
+		{"Concurrent", g1, []LV{2, 1}, []LV{1, 2}, false},
+		{"AncestorDropped", g1, []LV{0, 1, 2}, []LV{1, 2}, false},
+		{"DescendantOfAll", g1, []LV{3, 0, 1}, []LV{3}, false},
+		{"Duplicates", g1, []LV{1, 1}, []LV{1}, false},
+		{"WithinSpan", g2, []LV{1, 0, 2}, []LV{2}, false},
+		{"OutOfBounds", g1, []LV{9}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindMaximal(tt.cg, tt.versions)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindMaximal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !compareLVSlices(got, tt.want) {
+				t.Errorf("FindMaximal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestFindConflicting(t *testing.T) {
 	g1 := setupTestGraphG1(t)
 	// agentA := AgentID("agentA") // Not directly used in table, but good for context
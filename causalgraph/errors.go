@@ -0,0 +1,96 @@
+package causalgraph
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by this package wrap one of these sentinels or one of the
+// error types below, along with ErrLimitExceeded and ErrInvalidAgentID, so
+// callers can tell the cases apart with errors.Is and errors.As.
+var (
+	// ErrInvalidSpan is returned by AddRaw for a span with a length below 1
+	// or a negative sequence number.
+	ErrInvalidSpan = errors.New("invalid span")
+	// ErrNotFound is matched by ErrUnknownVersion and ErrUnknownParent.
+	ErrNotFound = errors.New("not found in causal graph")
+)
+
+// ErrUnknownVersion is returned when a raw version is not in the graph.
+type ErrUnknownVersion struct {
+	Raw RawVersion
+}
+
+func (e *ErrUnknownVersion) Error() string {
+	return fmt.Sprintf("raw version %s:%d not found in causal graph", e.Raw.Agent, e.Raw.Seq)
+}
+
+func (e *ErrUnknownVersion) Is(target error) bool { return target == ErrNotFound }
+
+// ErrUnknownParent is returned by AddRaw when a parent of the span is not in
+// the graph. The span can be added once the parent has been.
+type ErrUnknownParent struct {
+	Raw RawVersion
+}
+
+func (e *ErrUnknownParent) Error() string {
+	return fmt.Sprintf("parent %s:%d not found in causal graph", e.Raw.Agent, e.Raw.Seq)
+}
+
+func (e *ErrUnknownParent) Is(target error) bool { return target == ErrNotFound }
+
+// ErrSeqGap is returned by AddRaw when a span starts after the next sequence
+// number of its agent: the versions in between are missing.
+type ErrSeqGap struct {
+	Agent    AgentID
+	Expected int
+	Got      int
+}
+
+func (e *ErrSeqGap) Error() string {
+	return fmt.Sprintf("gap in sequence numbers for agent %s: expected %d, got %d", e.Agent, e.Expected, e.Got)
+}
+
+// ErrSeqOverlap is returned by AddRaw when a span starts before the next
+// sequence number of its agent: the graph already has at least its first
+// version. Expected is the end of the versions known for the agent, and
+// Length the length of the span, so Duplicate tells a span the graph holds
+// in full from one which only partly overlaps it.
+type ErrSeqOverlap struct {
+	Agent    AgentID
+	Expected int
+	Got      int
+	Length   int
+}
+
+func (e *ErrSeqOverlap) Error() string {
+	if e.Duplicate() {
+		return fmt.Sprintf("duplicate span for agent %s: seq %d+%d is already known up to %d", e.Agent, e.Got, e.Length, e.Expected)
+	}
+	return fmt.Sprintf("out of order sequence number for agent %s: expected %d, got %d", e.Agent, e.Expected, e.Got)
+}
+
+// Duplicate reports whether every version of the span is already in the
+// graph.
+func (e *ErrSeqOverlap) Duplicate() bool {
+	return e.Got+e.Length <= e.Expected
+}
+
+// ErrLVOutOfRange is returned when an LV passed in is not in the graph.
+type ErrLVOutOfRange struct {
+	LV     LV
+	NextLV LV
+}
+
+func (e *ErrLVOutOfRange) Error() string {
+	return fmt.Sprintf("LV %d is out of bounds for graph with %d LVs", e.LV, e.NextLV)
+}
+
+// seqError returns the error for a span of the given length by agent
+// starting at got when the agent's next sequence number is expected.
+func seqError(agent AgentID, expected, got, length int) error {
+	if got > expected {
+		return &ErrSeqGap{Agent: agent, Expected: expected, Got: got}
+	}
+	return &ErrSeqOverlap{Agent: agent, Expected: expected, Got: got, Length: length}
+}
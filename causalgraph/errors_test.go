@@ -0,0 +1,44 @@
+package causalgraph
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrors_LVOutOfRange(t *testing.T) {
+	cg := setupTestGraphG1(t) // 4 LVs.
+
+	calls := map[string]func() error{
+		"VersionContainsLV": func() error { _, err := VersionContainsLV(cg, []LV{3}, 4); return err },
+		"SummarizeVersion":  func() error { _, err := SummarizeVersion(cg, []LV{-1}); return err },
+		"Diff":              func() error { _, err := Diff(cg, []LV{4}, VersionSummary{}); return err },
+		"FindDominators":    func() error { _, err := FindDominators(cg, []LV{1, 9}); return err },
+		"FindMaximal":       func() error { _, err := FindMaximal(cg, []LV{0, 9}); return err },
+		"DiffVersions":      func() error { _, _, err := DiffVersions(cg, []LV{0}, []LV{9}); return err },
+		"LVToRawList":       func() error { _, err := LVToRawList(cg, []LV{0, 9}); return err },
+		"IterVersionsBetween": func() error {
+			return IterVersionsBetween(cg, []LV{0}, 9, func(LV, bool, bool) (bool, error) { return false, nil })
+		},
+	}
+	for name, call := range calls {
+		var outOfRange *ErrLVOutOfRange
+		if err := call(); !errors.As(err, &outOfRange) || outOfRange.NextLV != 4 {
+			t.Errorf("%s: expected ErrLVOutOfRange, got %v", name, err)
+		}
+	}
+}
+
+func TestErrors_UnknownVersion(t *testing.T) {
+	cg := setupTestGraphG1(t)
+	_, err := RawToLV(cg, "agentA", 5)
+	var unknown *ErrUnknownVersion
+	if !errors.As(err, &unknown) || unknown.Raw != (RawVersion{Agent: "agentA", Seq: 5}) {
+		t.Errorf("expected ErrUnknownVersion for agentA:5, got %v", err)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v to match ErrNotFound", err)
+	}
+	if errors.Is(&ErrSeqGap{}, ErrNotFound) {
+		t.Error("expected ErrSeqGap not to match ErrNotFound")
+	}
+}
@@ -0,0 +1,104 @@
+package causalgraph
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Limits bounds what AddRaw accepts into a graph, so that peers which are not
+// trusted cannot make it hold absurd agent IDs or grow without bound. Set
+// CausalGraph.Limits to enforce them. Zero fields impose no limit.
+type Limits struct {
+	// MaxAgentIDLen is the maximum length of an agent ID, in bytes.
+	MaxAgentIDLen int
+	// AllowedAgentRune reports whether r may appear in an agent ID. Nil
+	// allows any valid UTF-8.
+	AllowedAgentRune func(r rune) bool
+	// MaxSpanLen is the maximum number of versions added by one call.
+	MaxSpanLen int
+	// MaxParents is the maximum number of distinct parents passed for a span.
+	MaxParents int
+	// MaxVersions is the maximum number of versions in the graph.
+	MaxVersions int
+}
+
+// Limit names one of the bounds set by Limits.
+type Limit string
+
+const (
+	LimitAgentIDLen Limit = "agent ID length"
+	LimitSpanLen    Limit = "span length"
+	LimitParents    Limit = "parents"
+	LimitVersions   Limit = "versions"
+)
+
+// ErrLimitExceeded is returned by AddRaw when a span exceeds one of the
+// graph's Limits.
+type ErrLimitExceeded struct {
+	Limit Limit
+	// Value is the offending amount, such as the length of the agent ID or
+	// the number of versions the graph would hold.
+	Value int
+	Max   int
+}
+
+func (e *ErrLimitExceeded) Error() string {
+	return fmt.Sprintf("%s %d exceeds the limit of %d", e.Limit, e.Value, e.Max)
+}
+
+// ErrInvalidAgentID is returned by AddRaw when an agent ID is empty, or when
+// the graph has Limits and the ID is not valid UTF-8 or has a character which
+// is not allowed.
+type ErrInvalidAgentID struct {
+	Agent  AgentID
+	Reason string
+}
+
+func (e *ErrInvalidAgentID) Error() string {
+	return fmt.Sprintf("invalid agent ID %q: %s", e.Agent, e.Reason)
+}
+
+// checkAgent checks agent against l. Agents already in the graph were checked
+// when they were added, so callers only check new ones.
+func (l *Limits) checkAgent(agent AgentID) error {
+	if l.MaxAgentIDLen > 0 && len(agent) > l.MaxAgentIDLen {
+		return &ErrLimitExceeded{Limit: LimitAgentIDLen, Value: len(agent), Max: l.MaxAgentIDLen}
+	}
+	if !utf8.ValidString(string(agent)) {
+		return &ErrInvalidAgentID{Agent: agent, Reason: "not valid UTF-8"}
+	}
+	if l.AllowedAgentRune != nil {
+		for _, r := range agent {
+			if !l.AllowedAgentRune(r) {
+				return &ErrInvalidAgentID{Agent: agent, Reason: fmt.Sprintf("character %q is not allowed", r)}
+			}
+		}
+	}
+	return nil
+}
+
+// checkSpan checks a span of length versions by agent against l before it is
+// added to cg.
+func (l *Limits) checkSpan(cg *CausalGraph, agent AgentID, length int) error {
+	if _, known := cg.AgentToVersion[agent]; !known {
+		if err := l.checkAgent(agent); err != nil {
+			return err
+		}
+	}
+	if l.MaxSpanLen > 0 && length > l.MaxSpanLen {
+		return &ErrLimitExceeded{Limit: LimitSpanLen, Value: length, Max: l.MaxSpanLen}
+	}
+	// Written so that it cannot overflow.
+	if l.MaxVersions > 0 && length > l.MaxVersions-int(cg.NextLV) {
+		return &ErrLimitExceeded{Limit: LimitVersions, Value: int(cg.NextLV) + length, Max: l.MaxVersions}
+	}
+	return nil
+}
+
+// checkParents checks the number of distinct parents of a span against l.
+func (l *Limits) checkParents(numParents int) error {
+	if l.MaxParents > 0 && numParents > l.MaxParents {
+		return &ErrLimitExceeded{Limit: LimitParents, Value: numParents, Max: l.MaxParents}
+	}
+	return nil
+}
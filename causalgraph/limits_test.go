@@ -0,0 +1,131 @@
+package causalgraph
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestAddRaw_Limits(t *testing.T) {
+	limits := &Limits{
+		MaxAgentIDLen: 8,
+		AllowedAgentRune: func(r rune) bool {
+			return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-')
+		},
+		MaxSpanLen:  10,
+		MaxParents:  2,
+		MaxVersions: 20,
+	}
+	newCG := func(t *testing.T) *CausalGraph {
+		t.Helper()
+		cg := CreateCG()
+		cg.Limits = limits
+		for _, agent := range []AgentID{"a", "b", "c"} {
+			if _, err := AddRaw(cg, RawVersion{Agent: agent, Seq: 0}, 1, []RawVersion{}); err != nil {
+				t.Fatalf("AddRaw(%s) failed: %v", agent, err)
+			}
+		}
+		return cg
+	}
+	all := []RawVersion{{Agent: "a", Seq: 0}, {Agent: "b", Seq: 0}, {Agent: "c", Seq: 0}}
+
+	tests := []struct {
+		name      string
+		id        RawVersion
+		length    int
+		parents   []RawVersion
+		fill      int   // Versions added by another agent first.
+		wantLimit Limit // Empty when an ErrInvalidAgentID is expected.
+	}{
+		{name: "Empty_Agent", id: RawVersion{Agent: ""}, length: 1},
+		{name: "Long_Agent", id: RawVersion{Agent: AgentID(strings.Repeat("x", 1<<20))}, length: 1, wantLimit: LimitAgentIDLen},
+		{name: "Bad_Char", id: RawVersion{Agent: "bob ross"}, length: 1},
+		{name: "Bad_UTF8", id: RawVersion{Agent: "bob\xff"}, length: 1},
+		{name: "Long_Span", id: RawVersion{Agent: "bob"}, length: 11, wantLimit: LimitSpanLen},
+		{name: "Many_Parents", id: RawVersion{Agent: "bob"}, length: 1, parents: all, wantLimit: LimitParents},
+		{name: "Too_Many_Versions", id: RawVersion{Agent: "a", Seq: 1}, length: 10, parents: all[:1], fill: 8, wantLimit: LimitVersions},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cg := newCG(t)
+			if tt.fill > 0 {
+				if _, err := AddRaw(cg, RawVersion{Agent: "d", Seq: 0}, tt.fill, nil); err != nil {
+					t.Fatalf("AddRaw failed: %v", err)
+				}
+			}
+			_, err := AddRaw(cg, tt.id, tt.length, tt.parents)
+			if tt.wantLimit == "" {
+				var invalid *ErrInvalidAgentID
+				if !errors.As(err, &invalid) {
+					t.Fatalf("expected ErrInvalidAgentID, got %v", err)
+				}
+			} else {
+				var exceeded *ErrLimitExceeded
+				if !errors.As(err, &exceeded) || exceeded.Limit != tt.wantLimit {
+					t.Fatalf("expected the %s limit to be exceeded, got %v", tt.wantLimit, err)
+				}
+			}
+			if _, known := cg.AgentToVersion[tt.id.Agent]; known && tt.id.Agent != "a" {
+				t.Errorf("rejected agent %.10q was added to AgentToVersion", tt.id.Agent)
+			}
+		})
+	}
+
+	// Spans within the limits are accepted, up to the version cap.
+	cg := newCG(t)
+	if _, err := AddRaw(cg, RawVersion{Agent: "bob-2", Seq: 0}, 10, all[:2]); err != nil {
+		t.Fatalf("AddRaw within limits failed: %v", err)
+	}
+	if _, err := AddRaw(cg, RawVersion{Agent: "bob-2", Seq: 10}, 7, nil); err != nil {
+		t.Fatalf("AddRaw up to MaxVersions failed: %v", err)
+	}
+	if NextLV(cg) != 20 {
+		t.Errorf("expected 20 versions, got %d", NextLV(cg))
+	}
+
+	// Parents are counted once each.
+	cg = newCG(t)
+	dup := []RawVersion{all[0], all[1], all[0], all[1]}
+	if _, err := AddRaw(cg, RawVersion{Agent: "bob", Seq: 0}, 1, dup); err != nil {
+		t.Errorf("AddRaw with repeated parents failed: %v", err)
+	}
+
+	// A span the full graph already has is reported as a duplicate.
+	cg = newCG(t)
+	if _, err := AddRaw(cg, RawVersion{Agent: "d", Seq: 0}, 10, nil); err != nil {
+		t.Fatalf("AddRaw failed: %v", err)
+	}
+	if _, err := AddRaw(cg, RawVersion{Agent: "d", Seq: 10}, 7, nil); err != nil {
+		t.Fatalf("AddRaw failed: %v", err)
+	}
+	var overlap *ErrSeqOverlap
+	if _, err := AddRaw(cg, RawVersion{Agent: "d", Seq: 10}, 7, nil); !errors.As(err, &overlap) {
+		t.Errorf("expected ErrSeqOverlap re-adding a span at the cap, got %v", err)
+	}
+
+	// Huge spans are rejected rather than overflowing the version count.
+	cg = newCG(t)
+	cg.Limits = &Limits{MaxVersions: 20}
+	var exceeded *ErrLimitExceeded
+	if _, err := AddRaw(cg, RawVersion{Agent: "bob", Seq: 0}, math.MaxInt-3, nil); !errors.As(err, &exceeded) || exceeded.Limit != LimitVersions {
+		t.Errorf("expected the versions limit to be exceeded, got %v", err)
+	}
+	if _, err := AddRaw(cg, RawVersion{Agent: "bob", Seq: 0}, math.MaxInt, nil); !errors.Is(err, ErrInvalidSpan) {
+		t.Errorf("expected ErrInvalidSpan for a span overflowing NextLV, got %v", err)
+	}
+
+	// Without limits, anything goes but empty agents and overflow.
+	cg = CreateCG()
+	if _, err := AddRaw(cg, RawVersion{Agent: "bob"}, 100, nil); err != nil {
+		t.Errorf("AddRaw without limits failed: %v", err)
+	}
+	var invalid *ErrInvalidAgentID
+	if _, err := AddRaw(cg, RawVersion{Agent: ""}, 1, nil); !errors.As(err, &invalid) {
+		t.Errorf("expected ErrInvalidAgentID for an empty agent without limits, got %v", err)
+	}
+	if _, err := AddRaw(cg, RawVersion{Agent: "bob", Seq: 100}, math.MaxInt-50, nil); !errors.Is(err, ErrInvalidSpan) {
+		t.Errorf("expected ErrInvalidSpan for a span overflowing its sequence numbers, got %v", err)
+	}
+}
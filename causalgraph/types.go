@@ -3,6 +3,10 @@ package causalgraph
 // AgentID is a type alias for agent identifiers.
 type AgentID string
 
+// agentIndex identifies an agent within one causal graph: it is the agent's
+// position in the graph's agent table.
+type agentIndex uint32
+
 // RawVersion represents a version identifier as a [agent, seq] pair.
 type RawVersion struct {
 	Agent AgentID
@@ -44,8 +48,16 @@ type CausalGraph struct {
 	// AgentToVersion maps an agent ID to a list of ClientEntry runs by that agent.
 	// Sorted by sequence number.
 	AgentToVersion map[AgentID][]ClientEntry
+	// agents is the agent table: one copy of the ID of each agent AddRaw has
+	// seen, which the entries and runs of that agent share rather than each
+	// holding its own, possibly long, copy.
+	agents []AgentID
+	// agentIndexes maps an agent ID to its index in agents.
+	agentIndexes map[AgentID]agentIndex
 	// NextLV is the next available local version to assign.
 	NextLV LV
+	// Limits, if set, bounds the spans AddRaw accepts.
+	Limits *Limits
 }
 
 // VersionSummary is a map from agent ID to a list of [start_seq, end_seq) ranges.
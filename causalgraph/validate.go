@@ -0,0 +1,149 @@
+package causalgraph
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Invariant names a property every CausalGraph built by AddRaw has.
+type Invariant string
+
+const (
+	// InvariantEntries: Entries are non-empty runs, sorted and contiguous
+	// from LV 0 to NextLV.
+	InvariantEntries Invariant = "entries"
+	// InvariantParents: the parents of an entry are sorted, unique LVs
+	// below its first version.
+	InvariantParents Invariant = "parents"
+	// InvariantAgents: AgentToVersion lists each entry once, under its
+	// agent, in runs which start at sequence number 0 and neither overlap
+	// nor leave gaps.
+	InvariantAgents Invariant = "agents"
+	// InvariantHeads: Heads are the versions with no children, sorted.
+	InvariantHeads Invariant = "heads"
+)
+
+// Violation is a broken invariant found by Validate.
+type Violation struct {
+	Invariant Invariant
+	Detail    string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Invariant, v.Detail)
+}
+
+// ErrInvalidGraph is returned by Validate, listing every violation found.
+type ErrInvalidGraph struct {
+	Violations []Violation
+}
+
+func (e *ErrInvalidGraph) Error() string {
+	details := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		details[i] = v.String()
+	}
+	return fmt.Sprintf("invalid causal graph: %s", strings.Join(details, "; "))
+}
+
+// Validate checks that cg holds every Invariant, as when it is loaded from
+// disk or received from a peer rather than built by AddRaw. It returns nil
+// for a valid graph, and otherwise an *ErrInvalidGraph reporting every
+// violation found. Validate does not modify cg, and does not stop at the
+// first violation, so a corrupt graph may report several symptoms of the
+// same fault.
+func Validate(cg *CausalGraph) error {
+	var violations []Violation
+	report := func(inv Invariant, format string, args ...any) {
+		violations = append(violations, Violation{Invariant: inv, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	// Entries and their parents.
+	next := LV(0)
+	byVersion := make(map[LV]int, len(cg.Entries))
+	for i, e := range cg.Entries {
+		if e.Version != next {
+			report(InvariantEntries, "entry %d starts at LV %d, want %d", i, e.Version, next)
+		}
+		if e.VEnd <= e.Version {
+			report(InvariantEntries, "entry %d has empty range [%d, %d)", i, e.Version, e.VEnd)
+		} else {
+			next = e.VEnd
+		}
+		if e.Seq < 0 {
+			report(InvariantEntries, "entry %d has negative sequence number %d", i, e.Seq)
+		}
+		byVersion[e.Version] = i
+		for _, p := range e.Parents {
+			if p < 0 || p >= e.Version {
+				report(InvariantParents, "entry %d at LV %d has parent %d", i, e.Version, p)
+			}
+		}
+		if !slices.IsSorted(e.Parents) || len(slices.Compact(slices.Clone(e.Parents))) != len(e.Parents) {
+			report(InvariantParents, "entry %d has parents %v, which are not sorted and unique", i, e.Parents)
+		}
+	}
+	if next != cg.NextLV {
+		report(InvariantEntries, "entries end at LV %d, but NextLV is %d", next, cg.NextLV)
+	}
+
+	// The runs of each agent, in a stable order.
+	listed := make([]int, len(cg.Entries))
+	for _, agent := range slices.Sorted(maps.Keys(cg.AgentToVersion)) {
+		nextSeq := 0
+		for _, r := range cg.AgentToVersion[agent] {
+			switch {
+			case r.SeqEnd <= r.Seq:
+				report(InvariantAgents, "agent %s has empty run [%d, %d)", agent, r.Seq, r.SeqEnd)
+			case r.Seq < nextSeq:
+				report(InvariantAgents, "agent %s has run [%d, %d) overlapping sequence numbers before %d", agent, r.Seq, r.SeqEnd, nextSeq)
+			case r.Seq > nextSeq:
+				report(InvariantAgents, "agent %s has a gap in sequence numbers from %d to %d", agent, nextSeq, r.Seq)
+			}
+			nextSeq = max(nextSeq, r.SeqEnd)
+
+			i, ok := byVersion[r.Version]
+			if !ok {
+				report(InvariantAgents, "agent %s has run [%d, %d) at LV %d, where no entry starts", agent, r.Seq, r.SeqEnd, r.Version)
+				continue
+			}
+			listed[i]++
+			if e := cg.Entries[i]; e.Agent != agent || e.Seq != r.Seq || int(e.VEnd-e.Version) != r.SeqEnd-r.Seq {
+				report(InvariantAgents, "agent %s has run [%d, %d) at LV %d, but entry %d is %s [%d, %d)",
+					agent, r.Seq, r.SeqEnd, r.Version, i, e.Agent, e.Seq, e.Seq+int(e.VEnd-e.Version))
+			}
+		}
+	}
+	for i, n := range listed {
+		if n != 1 {
+			report(InvariantAgents, "entry %d is listed in %d runs, want 1", i, n)
+		}
+	}
+
+	// Heads. Within an entry, each version is the parent of the next, so
+	// only its last version can be a head.
+	isParent := make(map[LV]bool)
+	for _, e := range cg.Entries {
+		for _, p := range e.Parents {
+			isParent[p] = true
+		}
+	}
+	var heads []LV
+	for _, e := range cg.Entries {
+		if e.VEnd > e.Version && !isParent[e.VEnd-1] {
+			heads = append(heads, e.VEnd-1)
+		}
+	}
+	slices.Sort(heads)
+	heads = slices.Compact(heads)
+	if !slices.Equal(cg.Heads, heads) {
+		report(InvariantHeads, "heads are %v, want %v", cg.Heads, heads)
+	}
+
+	if len(violations) > 0 {
+		return &ErrInvalidGraph{Violations: violations}
+	}
+	return nil
+}
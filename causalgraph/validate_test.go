@@ -0,0 +1,135 @@
+package causalgraph
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// loadGraph returns a deep copy of cg built from its exported fields only, as
+// when a graph is loaded from disk.
+func loadGraph(cg *CausalGraph) *CausalGraph {
+	loaded := &CausalGraph{
+		Heads:          slices.Clone(cg.Heads),
+		AgentToVersion: make(map[AgentID][]ClientEntry, len(cg.AgentToVersion)),
+		NextLV:         cg.NextLV,
+	}
+	for _, e := range cg.Entries {
+		e.Parents = slices.Clone(e.Parents)
+		loaded.Entries = append(loaded.Entries, e)
+	}
+	for agent, runs := range cg.AgentToVersion {
+		loaded.AgentToVersion[agent] = slices.Clone(runs)
+	}
+	return loaded
+}
+
+func TestValidate_Valid(t *testing.T) {
+	for name, cg := range map[string]*CausalGraph{
+		"Empty": CreateCG(),
+		"G1":    setupTestGraphG1(t),
+		"G2":    setupTestGraphG2(t),
+	} {
+		if err := Validate(cg); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	// A loaded graph validates and keeps working.
+	cg := loadGraph(setupTestGraphG1(t))
+	if err := Validate(cg); err != nil {
+		t.Fatalf("loaded G1: %v", err)
+	}
+	if lv, err := RawToLV(cg, "agentA", 1); err != nil || lv != 2 {
+		t.Errorf("RawToLV(agentA, 1) = %d, %v", lv, err)
+	}
+	if _, err := AddRaw(cg, RawVersion{Agent: "agentB", Seq: 1}, 2, nil); err != nil {
+		t.Fatalf("AddRaw failed: %v", err)
+	}
+	if _, err := AddRaw(cg, RawVersion{Agent: "agentD", Seq: 0}, 1, []RawVersion{}); err != nil {
+		t.Fatalf("AddRaw failed: %v", err)
+	}
+	if err := Validate(cg); err != nil {
+		t.Errorf("loaded G1 after AddRaw: %v", err)
+	}
+}
+
+func TestValidate_Violations(t *testing.T) {
+	// G1: A0(0) -> B0(1), A0(0) -> A1(2), (B0(1),A1(2)) -> C0(3). Heads: [3]
+	tests := []struct {
+		name    string
+		corrupt func(cg *CausalGraph)
+		want    []Invariant
+	}{
+		{
+			name:    "Gap_In_Entries",
+			corrupt: func(cg *CausalGraph) { cg.Entries[2].Version, cg.Entries[2].VEnd = 3, 4 },
+			// A1's run no longer points at an entry, and the last two
+			// entries both start at 3.
+			want: []Invariant{InvariantEntries, InvariantEntries, InvariantAgents, InvariantAgents},
+		},
+		{
+			name:    "Wrong_NextLV",
+			corrupt: func(cg *CausalGraph) { cg.NextLV = 5 },
+			want:    []Invariant{InvariantEntries},
+		},
+		{
+			name:    "Parent_After_Entry",
+			corrupt: func(cg *CausalGraph) { cg.Entries[1].Parents = []LV{2} },
+			// LV 0 is no longer a parent of B0, but A1 keeps it from being a head.
+			want: []Invariant{InvariantParents},
+		},
+		{
+			name:    "Unsorted_Parents",
+			corrupt: func(cg *CausalGraph) { cg.Entries[3].Parents = []LV{2, 1} },
+			want:    []Invariant{InvariantParents},
+		},
+		{
+			name: "Overlapping_Runs",
+			corrupt: func(cg *CausalGraph) {
+				cg.AgentToVersion["agentA"][1].Seq = 0
+			},
+			// The run also no longer matches its entry.
+			want: []Invariant{InvariantAgents, InvariantAgents},
+		},
+		{
+			name:    "Entry_Missing_From_Runs",
+			corrupt: func(cg *CausalGraph) { cg.AgentToVersion["agentA"] = cg.AgentToVersion["agentA"][:1] },
+			want:    []Invariant{InvariantAgents},
+		},
+		{
+			name:    "Entry_With_Wrong_Agent",
+			corrupt: func(cg *CausalGraph) { cg.Entries[3].Agent = "agentA" },
+			want:    []Invariant{InvariantAgents},
+		},
+		{
+			name:    "Missing_Head",
+			corrupt: func(cg *CausalGraph) { cg.Heads = []LV{} },
+			want:    []Invariant{InvariantHeads},
+		},
+		{
+			name:    "Extra_Head",
+			corrupt: func(cg *CausalGraph) { cg.Heads = []LV{1, 3} },
+			want:    []Invariant{InvariantHeads},
+		},
+	}
+	g1 := setupTestGraphG1(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cg := loadGraph(g1)
+			tt.corrupt(cg)
+			err := Validate(cg)
+			var invalid *ErrInvalidGraph
+			if !errors.As(err, &invalid) {
+				t.Fatalf("expected ErrInvalidGraph, got %v", err)
+			}
+			var got []Invariant
+			for _, v := range invalid.Violations {
+				got = append(got, v.Invariant)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got violations %v, want invariants %v", invalid.Violations, tt.want)
+			}
+		})
+	}
+}
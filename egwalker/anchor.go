@@ -0,0 +1,163 @@
+package egwalker
+
+import (
+	"fmt"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// AnchorBias says which side of an anchor's position it sticks to.
+type AnchorBias int
+
+const (
+	// BiasRight anchors to the item after the position, so content inserted at
+	// the position ends up before the anchor.
+	BiasRight AnchorBias = iota
+	// BiasLeft anchors to the item before the position, so content inserted at
+	// the position ends up after the anchor.
+	BiasLeft
+)
+
+// Anchor is a position in a document which moves with the content around it,
+// for cursors, selections and comments. It refers to an item rather than an
+// index, so concurrent edits elsewhere don't shift it.
+type Anchor struct {
+	// LV is the insert operation of the element the anchor sticks to, or -1
+	// for the start (BiasLeft) or end (BiasRight) of the document. The anchor
+	// follows the element when it is moved.
+	LV   causalgraph.LV
+	Bias AnchorBias
+}
+
+// anchorAt returns an anchor for pos in the current version of ctx.
+func (ctx *EditContext) anchorAt(pos int, bias AnchorBias) (Anchor, error) {
+	if pos < 0 {
+		n := 0
+		for _, item := range ctx.Items {
+			if item.CurState == Inserted {
+				n++
+			}
+		}
+		return Anchor{}, &ErrPosOutOfRange{Pos: pos, Len: n}
+	}
+	target := pos
+	if bias == BiasLeft {
+		target = pos - 1
+	}
+	if target < 0 {
+		return Anchor{LV: -1, Bias: bias}, nil
+	}
+	i := 0
+	for _, item := range ctx.Items {
+		if item.CurState != Inserted {
+			continue
+		}
+		if i == target {
+			return Anchor{LV: ctx.element(item.OpID), Bias: bias}, nil
+		}
+		i++
+	}
+	if bias == BiasRight && target == i {
+		return Anchor{LV: -1, Bias: bias}, nil
+	}
+	return Anchor{}, &ErrPosOutOfRange{Pos: pos, Len: i}
+}
+
+// resolveAnchor returns the position of a in the current version of ctx. If
+// the anchored item is not visible, a resolves to where it would be.
+func (ctx *EditContext) resolveAnchor(a Anchor) (int, error) {
+	if a.LV < 0 {
+		if a.Bias == BiasLeft {
+			return 0, nil
+		}
+		n := 0
+		for _, item := range ctx.Items {
+			if item.CurState == Inserted {
+				n++
+			}
+		}
+		return n, nil
+	}
+	pos, visible, err := ctx.curPos(a.LV)
+	if err != nil {
+		return -1, err
+	}
+	if a.Bias == BiasLeft && visible {
+		pos++
+	}
+	return pos, nil
+}
+
+// resolveAnchorAt returns the position of a in the document at version. The
+// anchored item need not be in the version's history.
+func resolveAnchorAt[T any](cg *causalgraph.CausalGraph, a Anchor, version []causalgraph.LV, ops opIter[T]) (int, error) {
+	if a.LV >= cg.NextLV {
+		return -1, fmt.Errorf("anchor: %w", &causalgraph.ErrLVOutOfRange{LV: a.LV, NextLV: cg.NextLV})
+	}
+	union := append([]causalgraph.LV{}, version...)
+	if a.LV >= 0 {
+		union = append(union, a.LV)
+	}
+	ctx, err := replay(cg, union, ops, nil)
+	if err != nil {
+		return -1, err
+	}
+	if err := ctx.moveTo(cg, version); err != nil {
+		return -1, err
+	}
+	return ctx.resolveAnchor(a)
+}
+
+// AnchorAt returns an anchor for pos in the current document.
+func (w *Walker[T]) AnchorAt(pos int, bias AnchorBias) (Anchor, error) {
+	a, err := w.Ctx.anchorAt(pos, bias)
+	if err != nil {
+		return Anchor{}, fmt.Errorf("anchorAt: %w", err)
+	}
+	return a, nil
+}
+
+// ResolveAnchor returns the current position of a.
+func (w *Walker[T]) ResolveAnchor(a Anchor) (int, error) {
+	pos, err := w.Ctx.resolveAnchor(a)
+	if err != nil {
+		return -1, fmt.Errorf("resolveAnchor: %w", err)
+	}
+	return pos, nil
+}
+
+// ResolveAnchorAt returns the position of a in the document at version.
+func (w *Walker[T]) ResolveAnchorAt(a Anchor, version []causalgraph.LV) (int, error) {
+	pos, err := resolveAnchorAt(&w.Log.CG, a, version, w.Log.eachOp)
+	if err != nil {
+		return -1, fmt.Errorf("resolveAnchorAt: %w", err)
+	}
+	return pos, nil
+}
+
+// AnchorAt returns an anchor for the code point offset pos in the current text.
+func (d *TextDoc) AnchorAt(pos int, bias AnchorBias) (Anchor, error) {
+	a, err := d.Ctx.anchorAt(pos, bias)
+	if err != nil {
+		return Anchor{}, fmt.Errorf("anchorAt: %w", err)
+	}
+	return a, nil
+}
+
+// ResolveAnchor returns the current code point offset of a.
+func (d *TextDoc) ResolveAnchor(a Anchor) (int, error) {
+	pos, err := d.Ctx.resolveAnchor(a)
+	if err != nil {
+		return -1, fmt.Errorf("resolveAnchor: %w", err)
+	}
+	return pos, nil
+}
+
+// ResolveAnchorAt returns the code point offset of a in the text at version.
+func (d *TextDoc) ResolveAnchorAt(a Anchor, version []causalgraph.LV) (int, error) {
+	pos, err := resolveAnchorAt(&d.Log.CG, a, version, d.Log.eachOp)
+	if err != nil {
+		return -1, fmt.Errorf("resolveAnchorAt: %w", err)
+	}
+	return pos, nil
+}
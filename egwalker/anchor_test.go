@@ -0,0 +1,107 @@
+package egwalker
+
+import (
+	"testing"
+)
+
+func TestTextDoc_Anchors(t *testing.T) {
+	a := NewTextDoc()
+	if _, err := a.Insert("alice", 0, "hello world"); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	before := a.Version()
+
+	// A selection around "world", and cursors at both ends of the document.
+	start, _ := a.AnchorAt(6, BiasRight)
+	end, _ := a.AnchorAt(11, BiasLeft)
+	docStart, _ := a.AnchorAt(0, BiasLeft)
+	docEnd, _ := a.AnchorAt(11, BiasRight)
+	if docStart.LV != -1 || docEnd.LV != -1 {
+		t.Errorf("expected boundary anchors, got %+v and %+v", docStart, docEnd)
+	}
+
+	// Bob edits concurrently before and inside the selection.
+	b := NewTextDoc()
+	syncTextDocs(t, b, a)
+	b.Insert("bob", 0, ">> ")
+	b.Insert("bob", 9, "big ")
+	b.Insert("bob", b.Len(), "!")
+	syncTextDocs(t, a, b)
+	if a.String() != ">> hello big world!" {
+		t.Fatalf("unexpected text %q", a.String())
+	}
+
+	tests := []struct {
+		name   string
+		anchor Anchor
+		want   int
+	}{
+		{"SelectionStart", start, 13},
+		{"SelectionEnd", end, 18},
+		{"DocStart", docStart, 0},
+		{"DocEnd", docEnd, 19},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.ResolveAnchor(tt.anchor)
+			if err != nil {
+				t.Fatalf("ResolveAnchor failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// Anchors resolve in older versions too.
+	if got, _ := a.ResolveAnchorAt(start, before); got != 6 {
+		t.Errorf("at earlier version: got %d, want 6", got)
+	}
+	// Deleting the anchored character leaves the anchor where it was.
+	a.Delete("alice", 13, 6) // "world!"
+	if got, _ := a.ResolveAnchor(start); got != 13 {
+		t.Errorf("after delete: got %d, want 13", got)
+	}
+	if got, _ := a.ResolveAnchor(end); got != 13 {
+		t.Errorf("after delete: got %d, want 13", got)
+	}
+	if _, err := a.AnchorAt(100, BiasRight); err == nil {
+		t.Error("expected error for out of bounds position")
+	}
+}
+
+func TestWalker_AnchorAtVersionWithoutItem(t *testing.T) {
+	w := NewWalker[string]()
+	w.LocalInsert("alice", 0, "a")
+	early := w.GetVersion()
+	w.LocalInsert("alice", 0, "b")
+	w.LocalInsert("alice", 2, "c")
+
+	anchor, err := w.AnchorAt(2, BiasRight) // sticks to "c"
+	if err != nil {
+		t.Fatalf("AnchorAt failed: %v", err)
+	}
+	// "c" did not exist yet, so the anchor resolves to where it would go.
+	if got, err := w.ResolveAnchorAt(anchor, early); err != nil || got != 1 {
+		t.Errorf("got %d (%v), want 1", got, err)
+	}
+}
+
+func TestWalker_AnchorFollowsMove(t *testing.T) {
+	w := NewWalker[string]()
+	for i, s := range []string{"a", "b", "c"} {
+		w.LocalInsert("alice", i, s)
+	}
+	w.LocalMove("alice", 0, 2)              // "bca"
+	anchor, err := w.AnchorAt(2, BiasRight) // sticks to "a"
+	if err != nil {
+		t.Fatalf("AnchorAt failed: %v", err)
+	}
+	if anchor.LV != 0 {
+		t.Errorf("expected the anchor to stick to the insert of \"a\", got LV %d", anchor.LV)
+	}
+	w.LocalMove("bob", 2, 0) // "abc"
+	if got, err := w.ResolveAnchor(anchor); err != nil || got != 0 {
+		t.Errorf("after moving \"a\" back: got %d (%v), want 0", got, err)
+	}
+}
@@ -0,0 +1,66 @@
+package egwalker
+
+import (
+	"fmt"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// BlameRun attributes a run of consecutive elements in a snapshot to the
+// operations which inserted them. The elements were inserted by Agent with
+// sequence numbers Seq..Seq+Len-1, which are local versions LV..LV+Len-1.
+type BlameRun struct {
+	Pos   int // Position of the first element in the snapshot.
+	Len   int
+	Agent causalgraph.AgentID
+	Seq   int
+	LV    causalgraph.LV
+}
+
+// blame returns the authorship runs of the elements visible in ctx.
+func blame(cg *causalgraph.CausalGraph, ctx *EditContext) ([]BlameRun, error) {
+	var runs []BlameRun
+	pos := 0
+	for _, item := range ctx.Items {
+		if item.CurState != Inserted {
+			continue
+		}
+		// A moved element is blamed on its insert, not the move.
+		lv := ctx.element(item.OpID)
+		raw, ok := causalgraph.LVToRaw(cg, lv)
+		if !ok {
+			return nil, fmt.Errorf("blame: %w", &causalgraph.ErrLVOutOfRange{LV: lv, NextLV: cg.NextLV})
+		}
+		if n := len(runs); n > 0 {
+			last := &runs[n-1]
+			if last.Agent == raw.Agent && last.Seq+last.Len == raw.Seq && last.LV+causalgraph.LV(last.Len) == lv {
+				last.Len++
+				pos++
+				continue
+			}
+		}
+		runs = append(runs, BlameRun{Pos: pos, Len: 1, Agent: raw.Agent, Seq: raw.Seq, LV: lv})
+		pos++
+	}
+	return runs, nil
+}
+
+// Blame returns, for the snapshot at version, which agent inserted each
+// element, as runs of elements inserted by consecutive operations.
+func (w *Walker[T]) Blame(version []causalgraph.LV) ([]BlameRun, error) {
+	ctx, err := w.replay(version)
+	if err != nil {
+		return nil, fmt.Errorf("blame: %w", err)
+	}
+	return blame(&w.Log.CG, ctx)
+}
+
+// Blame returns, for the text at version, which agent inserted each
+// character. Positions and lengths are in code points.
+func (d *TextDoc) Blame(version []causalgraph.LV) ([]BlameRun, error) {
+	ctx, err := replay(&d.Log.CG, version, d.Log.eachOp, nil)
+	if err != nil {
+		return nil, fmt.Errorf("blame: %w", err)
+	}
+	return blame(&d.Log.CG, ctx)
+}
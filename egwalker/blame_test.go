@@ -0,0 +1,74 @@
+package egwalker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+func TestTextDoc_Blame(t *testing.T) {
+	a := NewTextDoc()
+	if _, err := a.Insert("alice", 0, "hello world"); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	before := a.Version()
+	b := NewTextDoc()
+	syncTextDocs(t, b, a)
+	if _, err := b.Insert("bob", 5, ","); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	syncTextDocs(t, a, b)
+	if _, err := a.Delete("alice", 0, 1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := a.Insert("alice", 0, "H"); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	got, err := a.Blame(a.Version())
+	if err != nil {
+		t.Fatalf("Blame failed: %v", err)
+	}
+	want := []BlameRun{
+		{Pos: 0, Len: 1, Agent: "alice", Seq: 12, LV: 13},
+		{Pos: 1, Len: 4, Agent: "alice", Seq: 1, LV: 1},
+		{Pos: 5, Len: 1, Agent: "bob", Seq: 0, LV: 11},
+		{Pos: 6, Len: 6, Agent: "alice", Seq: 5, LV: 5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	got, err = a.Blame(before)
+	if err != nil {
+		t.Fatalf("Blame failed: %v", err)
+	}
+	if want := []BlameRun{{Pos: 0, Len: 11, Agent: "alice", Seq: 0, LV: 0}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("at earlier version: got %+v, want %+v", got, want)
+	}
+	if _, err := a.Blame([]causalgraph.LV{100}); err == nil {
+		t.Error("expected error for unknown version")
+	}
+}
+
+func TestWalker_Blame(t *testing.T) {
+	w := NewWalker[string]()
+	w.LocalInsert("alice", 0, "a")
+	w.LocalInsert("bob", 1, "b")
+	w.LocalInsert("alice", 2, "c")
+
+	got, err := w.Blame(w.GetVersion())
+	if err != nil {
+		t.Fatalf("Blame failed: %v", err)
+	}
+	// alice's two inserts are not consecutive LVs, so they form separate runs.
+	want := []BlameRun{
+		{Pos: 0, Len: 1, Agent: "alice", Seq: 0, LV: 0},
+		{Pos: 1, Len: 1, Agent: "bob", Seq: 0, LV: 1},
+		{Pos: 2, Len: 1, Agent: "alice", Seq: 1, LV: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,141 @@
+package egwalker
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// CheckoutOptions configures CheckoutMany.
+type CheckoutOptions struct {
+	// Workers is the number of goroutines used to produce snapshots. Values
+	// below 2 check out every version on the calling goroutine.
+	Workers int
+}
+
+// CheckoutMany returns the document snapshots at each of versions, in the same
+// order. Instead of replaying history once per version, it replays the union of
+// their histories once and then walks back through the requested versions,
+// latest first, moving between neighbours rather than starting over.
+//
+// With opts.Workers > 1 the versions are split into contiguous runs of that
+// order, each checked out by its own goroutine. The operation log is only read,
+// so callers must not modify the walker until CheckoutMany returns.
+func (w *Walker[T]) CheckoutMany(versions [][]causalgraph.LV, opts CheckoutOptions) ([]*Branch[T], error) {
+	for _, version := range versions {
+		for _, lv := range version {
+			if lv < 0 || lv >= w.Log.CG.NextLV {
+				return nil, fmt.Errorf("checkoutMany: %w", &causalgraph.ErrLVOutOfRange{LV: lv, NextLV: w.Log.CG.NextLV})
+			}
+		}
+	}
+
+	if len(versions) == 0 {
+		return []*Branch[T]{}, nil
+	}
+
+	order := w.checkoutOrder(versions)
+
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(order) {
+		workers = len(order)
+	}
+
+	branches := make([]*Branch[T], len(versions))
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	chunk := (len(order) + workers - 1) / workers
+	for i := 0; i < workers; i++ {
+		start := i * chunk
+		end := min(start+chunk, len(order))
+		if start >= end {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, indices []int) {
+			defer wg.Done()
+			errs[i] = w.checkoutRun(versions, indices, branches)
+		}(i, order[start:end])
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("checkoutMany: %w", err)
+		}
+	}
+	return branches, nil
+}
+
+// checkoutRun checks out versions[i] for each i in indices, in that order,
+// storing the results in branches[i].
+func (w *Walker[T]) checkoutRun(versions [][]causalgraph.LV, indices []int, branches []*Branch[T]) error {
+	var union []causalgraph.LV
+	for _, i := range indices {
+		union = append(union, versions[i]...)
+	}
+	union = uniqueLVs(union)
+
+	ctx, err := w.replay(union)
+	if err != nil {
+		return err
+	}
+	for _, i := range indices {
+		if err := ctx.moveTo(&w.Log.CG, versions[i]); err != nil {
+			return err
+		}
+		snapshot := make([]T, 0)
+		for _, item := range ctx.Items {
+			if item.CurState == Inserted {
+				snapshot = append(snapshot, w.Log.Ops[ctx.element(item.OpID)].Content)
+			}
+		}
+		branches[i] = &Branch[T]{
+			Snapshot: snapshot,
+			Version:  append([]causalgraph.LV{}, versions[i]...),
+		}
+	}
+	return nil
+}
+
+// checkoutOrder returns the indices of versions ordered latest first, so a
+// run of them can be checked out by retreating from the union of their
+// histories. Versions are compared by their LVs from the greatest down. LVs
+// are assigned in causal order, so a version always comes after the versions
+// it contains, and versions which share most of their history end up close
+// together. Ordering needs no walk of the graph.
+func (w *Walker[T]) checkoutOrder(versions [][]causalgraph.LV) []int {
+	keys := make([][]causalgraph.LV, len(versions))
+	for i, version := range versions {
+		keys[i] = uniqueLVs(version)
+		slices.Reverse(keys[i])
+	}
+	order := make([]int, len(versions))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return -slices.Compare(keys[a], keys[b])
+	})
+	return order
+}
+
+// uniqueLVs returns a sorted copy of lvs with duplicates removed.
+func uniqueLVs(lvs []causalgraph.LV) []causalgraph.LV {
+	out := append([]causalgraph.LV{}, lvs...)
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	j := 0
+	for i := range out {
+		if i == 0 || out[i] != out[i-1] {
+			out[j] = out[i]
+			j++
+		}
+	}
+	return out[:j]
+}
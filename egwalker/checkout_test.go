@@ -0,0 +1,114 @@
+package egwalker
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// buildBranchyWalker returns a walker with two concurrent branches merged
+// together, along with a version after every operation.
+func buildBranchyWalker(t *testing.T) (*Walker[string], [][]causalgraph.LV) {
+	t.Helper()
+	a := NewWalker[string]()
+	b := NewWalker[string]()
+	var versions [][]causalgraph.LV
+	record := func() { versions = append(versions, a.GetVersion()) }
+
+	for i := 0; i < 5; i++ {
+		if _, err := a.LocalInsert("alice", i, fmt.Sprint("a", i)); err != nil {
+			t.Fatalf("LocalInsert failed: %v", err)
+		}
+		record()
+	}
+	syncWalkers(t, b, a)
+	for i := 0; i < 5; i++ {
+		if _, err := b.LocalInsert("bob", 0, fmt.Sprint("b", i)); err != nil {
+			t.Fatalf("LocalInsert failed: %v", err)
+		}
+		if _, err := a.LocalDelete("alice", 0); err != nil {
+			t.Fatalf("LocalDelete failed: %v", err)
+		}
+		record()
+	}
+	syncWalkers(t, a, b)
+	record()
+	for _, lv := range []causalgraph.LV{7, 12} { // Points on bob's branch, via alice's log.
+		versions = append(versions, []causalgraph.LV{lv})
+	}
+	versions = append(versions, []causalgraph.LV{})
+	return a, versions
+}
+
+func TestWalker_CheckoutMany(t *testing.T) {
+	w, versions := buildBranchyWalker(t)
+
+	want := make([]*Branch[string], len(versions))
+	for i, version := range versions {
+		branch, err := w.Checkout(version)
+		if err != nil {
+			t.Fatalf("Checkout(%v) failed: %v", version, err)
+		}
+		want[i] = branch
+	}
+
+	for _, workers := range []int{0, 1, 3, 100} {
+		t.Run(fmt.Sprintf("Workers_%d", workers), func(t *testing.T) {
+			got, err := w.CheckoutMany(versions, CheckoutOptions{Workers: workers})
+			if err != nil {
+				t.Fatalf("CheckoutMany failed: %v", err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("got %d branches, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if !reflect.DeepEqual(got[i], want[i]) {
+					t.Errorf("version %v: got %+v, want %+v", versions[i], got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWalker_CheckoutMany_Errors(t *testing.T) {
+	w, _ := buildBranchyWalker(t)
+	if _, err := w.CheckoutMany([][]causalgraph.LV{{0}, {1000}}, CheckoutOptions{}); err == nil {
+		t.Error("expected error for unknown version")
+	}
+	got, err := w.CheckoutMany(nil, CheckoutOptions{Workers: 4})
+	if err != nil || len(got) != 0 {
+		t.Errorf("CheckoutMany(nil) = %v, %v; want empty result", got, err)
+	}
+}
+
+func TestWalker_CheckoutOrder(t *testing.T) {
+	w := NewWalker[int]()
+	for i := 0; i < 10; i++ {
+		w.LocalInsert("alice", i, i)
+	}
+	versions := [][]causalgraph.LV{{0}, {9}, {4}, {8}}
+	if want, order := []int{1, 3, 2, 0}, w.checkoutOrder(versions); !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+
+	// With concurrent branches, no version comes before one containing it.
+	b, versions := buildBranchyWalker(t)
+	contains := func(version, sub []causalgraph.LV) bool {
+		for _, lv := range sub {
+			if ok, err := causalgraph.VersionContainsLV(&b.Log.CG, version, lv); err != nil || !ok {
+				return false
+			}
+		}
+		return true
+	}
+	order := b.checkoutOrder(versions)
+	for i, x := range order {
+		for _, y := range order[i+1:] {
+			if contains(versions[y], versions[x]) && !contains(versions[x], versions[y]) {
+				t.Errorf("%v comes before %v, which contains it", versions[x], versions[y])
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package egwalker
+
+import (
+	"fmt"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// CounterSpan is a run of consecutive counter operations by a single agent,
+// in the form exchanged between peers. Each operation is the amount added
+// to the counter, negative for decrements. See RemoteSpan.
+type CounterSpan struct {
+	ID      causalgraph.RawVersion
+	Parents []causalgraph.RawVersion
+	Ops     []int64
+}
+
+// Counter is a PN-counter whose increments and decrements are versioned by
+// a causal graph, which may be shared with other data. Concurrent updates
+// all count: its value at a version is the sum of the operations in the
+// version's history.
+//
+// As with LWWMap, when the graph is shared, spans must be applied on other
+// peers in the order of their LVs on the sending peer.
+type Counter struct {
+	log *sharedLog[int64]
+	// total is the sum of every operation in the log.
+	total int64
+}
+
+// NewCounter creates a counter with value 0 versioned by cg.
+func NewCounter(cg *causalgraph.CausalGraph) *Counter {
+	return &Counter{log: newSharedLog[int64](cg)}
+}
+
+// Increment adds n to the counter on behalf of agent.
+func (c *Counter) Increment(agent string, n int64) (causalgraph.LV, error) {
+	lv, err := c.log.local(agent, nil, n)
+	if err != nil {
+		return -1, fmt.Errorf("increment: %w", err)
+	}
+	c.total += n
+	return lv, nil
+}
+
+// Decrement subtracts n from the counter on behalf of agent.
+func (c *Counter) Decrement(agent string, n int64) (causalgraph.LV, error) {
+	lv, err := c.log.local(agent, nil, -n)
+	if err != nil {
+		return -1, fmt.Errorf("decrement: %w", err)
+	}
+	c.total -= n
+	return lv, nil
+}
+
+// ApplyRemote integrates a span of counter operations received from another
+// peer. Operations the graph already knows are skipped.
+func (c *Counter) ApplyRemote(span CounterSpan) error {
+	r, err := c.log.applyRemote(span.ID, span.Parents, span.Ops)
+	if err != nil {
+		return fmt.Errorf("applyRemote: %w", err)
+	}
+	for lv := r.Start; lv < r.End; lv++ {
+		c.total += c.log.ops[lv]
+	}
+	return nil
+}
+
+// Spans returns the counter's whole history as spans which can be passed to
+// ApplyRemote on another Counter.
+func (c *Counter) Spans() ([]CounterSpan, error) {
+	var spans []CounterSpan
+	err := c.log.eachSpan(func(id causalgraph.RawVersion, parents []causalgraph.RawVersion, ops []int64) {
+		spans = append(spans, CounterSpan{ID: id, Parents: parents, Ops: ops})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("spans: %w", err)
+	}
+	return spans, nil
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value() int64 {
+	return c.total
+}
+
+// ValueAt returns the value of the counter at version.
+func (c *Counter) ValueAt(version []causalgraph.LV) (int64, error) {
+	summary, err := causalgraph.SummarizeVersion(c.log.cg, version)
+	if err != nil {
+		return 0, fmt.Errorf("valueAt: %w", err)
+	}
+	v, err := c.ValueAtSummary(summary)
+	if err != nil {
+		return 0, fmt.Errorf("valueAt: %w", err)
+	}
+	return v, nil
+}
+
+// ValueAtSummary returns the value of the counter counting only the
+// operations in summary, such as the version of another peer. Operations in
+// summary which this counter has not received are not counted.
+func (c *Counter) ValueAtSummary(summary causalgraph.VersionSummary) (int64, error) {
+	// Operations are usually near the heads, so subtract those summary
+	// lacks from the total rather than adding up its whole history.
+	missing, err := causalgraph.Diff(c.log.cg, c.log.cg.Heads, summary)
+	if err != nil {
+		return 0, fmt.Errorf("valueAtSummary: %w", err)
+	}
+	v := c.total
+	for _, r := range missing {
+		for lv := r.Start; lv < r.End; lv++ {
+			v -= c.log.ops[lv]
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,80 @@
+package egwalker
+
+import (
+	"testing"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+func syncCounters(t *testing.T, dst, src *Counter) {
+	t.Helper()
+	spans, err := src.Spans()
+	if err != nil {
+		t.Fatalf("Spans failed: %v", err)
+	}
+	for _, span := range spans {
+		if err := dst.ApplyRemote(span); err != nil {
+			t.Fatalf("ApplyRemote(%+v) failed: %v", span.ID, err)
+		}
+	}
+}
+
+func TestCounter_Concurrent(t *testing.T) {
+	a := NewCounter(causalgraph.CreateCG())
+	b := NewCounter(causalgraph.CreateCG())
+	a.Increment("alice", 5)
+	syncCounters(t, b, a)
+	before := a.log.cg.Heads
+
+	a.Increment("alice", 2)
+	a.Decrement("alice", 1)
+	b.Increment("bob", 10)
+	bobSummary, err := causalgraph.SummarizeVersion(b.log.cg, b.log.cg.Heads)
+	if err != nil {
+		t.Fatalf("SummarizeVersion failed: %v", err)
+	}
+	syncCounters(t, a, b)
+	syncCounters(t, b, a)
+	syncCounters(t, b, a) // Spans already applied are skipped.
+
+	for _, c := range []*Counter{a, b} {
+		if got := c.Value(); got != 16 {
+			t.Errorf("got %d, want 16", got)
+		}
+	}
+	if got, err := a.ValueAt(before); err != nil || got != 5 {
+		t.Errorf("ValueAt = %d, %v; want 5", got, err)
+	}
+	if got, err := a.ValueAt(nil); err != nil || got != 0 {
+		t.Errorf("ValueAt(nil) = %d, %v; want 0", got, err)
+	}
+	if got, err := a.ValueAtSummary(bobSummary); err != nil || got != 15 {
+		t.Errorf("ValueAtSummary = %d, %v; want 15", got, err)
+	}
+}
+
+func TestCounter_SharedGraph(t *testing.T) {
+	a, b := NewTextDoc(), NewTextDoc()
+	votes := NewCounter(a.GetCG())
+	a.Insert("alice", 0, "proposal")
+	votes.Increment("alice", 1)
+	a.Insert("alice", 8, "!")
+	votes.Increment("alice", 1)
+
+	other := NewCounter(b.GetCG())
+	textSpans, _ := a.Spans()
+	counterSpans, _ := votes.Spans()
+	// Deliver in LV order, as the graph is shared.
+	for i := range textSpans {
+		b.ApplyRemote(textSpans[i])
+		if err := other.ApplyRemote(counterSpans[i]); err != nil {
+			t.Fatalf("ApplyRemote failed: %v", err)
+		}
+	}
+	if b.String() != "proposal!" || other.Value() != 2 {
+		t.Errorf("got %q and %d votes", b.String(), other.Value())
+	}
+	if got, _ := other.ValueAt([]causalgraph.LV{8}); got != 1 {
+		t.Errorf("ValueAt = %d, want 1", got)
+	}
+}
@@ -0,0 +1,108 @@
+package egwalker
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// DeleteOp identifies an operation which deleted an element.
+type DeleteOp struct {
+	LV causalgraph.LV
+	ID causalgraph.RawVersion
+}
+
+// Deletion describes an element deleted between two versions.
+type Deletion[T any] struct {
+	Content T
+	// Pos is the position in the later snapshot at which the element stood,
+	// so deleted content can be shown inline or reinserted.
+	Pos int
+	// InsertLV and Inserted identify the operation which inserted the element.
+	InsertLV causalgraph.LV
+	Inserted causalgraph.RawVersion
+	// Deletes lists, in LV order, the operations between the two versions which
+	// deleted the element. There is more than one if peers deleted it concurrently.
+	Deletes []DeleteOp
+}
+
+// deletions returns the elements deleted by operations in to but not in from,
+// in document order.
+func deletions[T any](cg *causalgraph.CausalGraph, from, to []causalgraph.LV, ops opIter[T]) ([]Deletion[T], error) {
+	_, newOps, err := causalgraph.DiffVersions(cg, from, to)
+	if err != nil {
+		return nil, err
+	}
+	// Every element deleted in to was also inserted in to, so replaying to is enough.
+	ctx, err := replay(cg, to, ops, nil)
+	if err != nil {
+		return nil, err
+	}
+	deletes := make(map[causalgraph.LV][]DeleteOp)
+	for del, target := range ctx.DelTargets {
+		if !lvRangesContain(newOps, del) {
+			continue
+		}
+		raw, ok := causalgraph.LVToRaw(cg, del)
+		if !ok {
+			return nil, &causalgraph.ErrLVOutOfRange{LV: del, NextLV: cg.NextLV}
+		}
+		deletes[target] = append(deletes[target], DeleteOp{LV: del, ID: raw})
+	}
+
+	var result []Deletion[T]
+	pos := 0
+	for _, item := range ctx.Items {
+		if item.CurState == Inserted {
+			pos++
+			continue
+		}
+		// A moved element is reported where it stood when it was deleted:
+		// at the position item of the winning move.
+		elem := ctx.element(item.OpID)
+		if ms, moved := ctx.moved[elem]; moved && ms.curWinner != item.OpID {
+			continue
+		}
+		dels, ok := deletes[elem]
+		if !ok {
+			continue
+		}
+		slices.SortFunc(dels, func(a, b DeleteOp) int { return int(a.LV - b.LV) })
+		raw, ok := causalgraph.LVToRaw(cg, elem)
+		if !ok {
+			return nil, &causalgraph.ErrLVOutOfRange{LV: elem, NextLV: cg.NextLV}
+		}
+		d := Deletion[T]{Pos: pos, InsertLV: elem, Inserted: raw, Deletes: dels}
+		err := ops(elem, elem+1, func(_ causalgraph.LV, op ListOp[T]) error {
+			d.Content = op.Content
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, d)
+	}
+	return result, nil
+}
+
+// Deletions returns the elements deleted by operations which are in the
+// history of to but not of from, in the order they stood in the document.
+func (w *Walker[T]) Deletions(from, to []causalgraph.LV) ([]Deletion[T], error) {
+	result, err := deletions(&w.Log.CG, from, to, w.Log.eachOp)
+	if err != nil {
+		return nil, fmt.Errorf("deletions: %w", err)
+	}
+	return result, nil
+}
+
+// Deletions returns the characters deleted by operations which are in the
+// history of to but not of from, in the order they stood in the text.
+// Positions are in code points.
+func (d *TextDoc) Deletions(from, to []causalgraph.LV) ([]Deletion[rune], error) {
+	result, err := deletions(&d.Log.CG, from, to, d.Log.eachOp)
+	if err != nil {
+		return nil, fmt.Errorf("deletions: %w", err)
+	}
+	return result, nil
+}
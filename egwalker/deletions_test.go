@@ -0,0 +1,110 @@
+package egwalker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+func TestTextDoc_Deletions(t *testing.T) {
+	a := NewTextDoc()
+	if _, err := a.Insert("alice", 0, "abcdef"); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	base := a.Version()
+	b := NewTextDoc()
+	syncTextDocs(t, b, a)
+
+	// Both peers delete "c" concurrently; bob also deletes "e".
+	if _, err := a.Delete("alice", 2, 1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := b.Delete("bob", 2, 1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := b.Delete("bob", 3, 1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	syncTextDocs(t, a, b)
+	if a.String() != "abdf" {
+		t.Fatalf("got %q, want %q", a.String(), "abdf")
+	}
+
+	got, err := a.Deletions(base, a.Version())
+	if err != nil {
+		t.Fatalf("Deletions failed: %v", err)
+	}
+	want := []Deletion[rune]{
+		{
+			Content: 'c', Pos: 2, InsertLV: 2,
+			Inserted: causalgraph.RawVersion{Agent: "alice", Seq: 2},
+			Deletes: []DeleteOp{
+				{LV: 6, ID: causalgraph.RawVersion{Agent: "alice", Seq: 6}},
+				{LV: 7, ID: causalgraph.RawVersion{Agent: "bob", Seq: 0}},
+			},
+		},
+		{
+			Content: 'e', Pos: 3, InsertLV: 4,
+			Inserted: causalgraph.RawVersion{Agent: "alice", Seq: 4},
+			Deletes:  []DeleteOp{{LV: 8, ID: causalgraph.RawVersion{Agent: "bob", Seq: 1}}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Deletions already in from are not reported.
+	got, err = a.Deletions([]causalgraph.LV{6}, a.Version())
+	if err != nil {
+		t.Fatalf("Deletions failed: %v", err)
+	}
+	if len(got) != 2 || len(got[0].Deletes) != 1 || got[0].Deletes[0].ID.Agent != "bob" {
+		t.Errorf("since alice's delete: got %+v", got)
+	}
+	if got, _ := a.Deletions(a.Version(), a.Version()); len(got) != 0 {
+		t.Errorf("expected no deletions between equal versions, got %+v", got)
+	}
+	if _, err := a.Deletions(base, []causalgraph.LV{100}); err == nil {
+		t.Error("expected error for unknown version")
+	}
+}
+
+func TestWalker_Deletions(t *testing.T) {
+	w := NewWalker[string]()
+	w.LocalInsert("alice", 0, "x")
+	w.LocalInsert("alice", 1, "y")
+	w.LocalDelete("bob", 0)
+
+	got, err := w.Deletions(nil, w.GetVersion())
+	if err != nil {
+		t.Fatalf("Deletions failed: %v", err)
+	}
+	want := []Deletion[string]{{
+		Content: "x", Pos: 0, InsertLV: 0,
+		Inserted: causalgraph.RawVersion{Agent: "alice", Seq: 0},
+		Deletes:  []DeleteOp{{LV: 2, ID: causalgraph.RawVersion{Agent: "bob", Seq: 0}}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestWalker_Deletions_Moved(t *testing.T) {
+	w := NewWalker[string]()
+	for i, s := range []string{"a", "b", "c"} {
+		w.LocalInsert("alice", i, s)
+	}
+	base := w.GetVersion()
+	w.LocalMove("alice", 0, 2) // "bca"
+	w.LocalDelete("bob", 2)    // "bc"
+
+	got, err := w.Deletions(base, w.GetVersion())
+	if err != nil {
+		t.Fatalf("Deletions failed: %v", err)
+	}
+	// "a" is reported where it was moved to, not where it was inserted.
+	if len(got) != 1 || got[0].Content != "a" || got[0].Pos != 2 || got[0].InsertLV != 0 {
+		t.Errorf("got %+v, want \"a\" at 2 inserted at LV 0", got)
+	}
+}
@@ -0,0 +1,254 @@
+package egwalker
+
+import (
+	"fmt"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// diffKind is the kind of a diffRun.
+type diffKind int
+
+const (
+	diffEqual diffKind = iota
+	diffDelete
+	diffInsert
+)
+
+// diffRun is a run of consecutive edits of one kind produced by myersDiff.
+// Equal and delete runs cover a[AStart:AStart+Len]; equal and insert runs cover
+// b[BStart:BStart+Len].
+type diffRun struct {
+	Kind   diffKind
+	AStart int
+	BStart int
+	Len    int
+}
+
+// diffMaxD bounds the number of edits myersDiff looks for between two parts
+// of the sequences it compares. Parts which differ by more are replaced
+// wholesale, so the script is no longer minimal, but diffing large unrelated
+// sequences takes O((N+M)·diffMaxD) time rather than O((N+M)²).
+const diffMaxD = 1024
+
+// myersDiff returns a shortest edit script turning a into b, using the
+// linear space refinement of Myers' O((N+M)D) algorithm: it finds the middle
+// of a shortest path between the sequences and recurses on both halves.
+// Common prefixes and suffixes are stripped first, since most real edits
+// touch a small part of a large sequence. See diffMaxD for the limit on D.
+func myersDiff[T comparable](a, b []T) []diffRun {
+	d := &differ[T]{a: a, b: b}
+	d.diff(0, len(a), 0, len(b))
+	return d.runs
+}
+
+// differ holds the state of a myersDiff.
+type differ[T comparable] struct {
+	a, b []T
+	runs []diffRun
+}
+
+// push appends n edits of kind at a[aStart] and b[bStart], extending the
+// last run if it has the same kind.
+func (d *differ[T]) push(kind diffKind, aStart, bStart, n int) {
+	if n == 0 {
+		return
+	}
+	if last := len(d.runs) - 1; last >= 0 && d.runs[last].Kind == kind {
+		d.runs[last].Len += n
+		return
+	}
+	d.runs = append(d.runs, diffRun{Kind: kind, AStart: aStart, BStart: bStart, Len: n})
+}
+
+// diff appends the edits turning a[a0:a1] into b[b0:b1].
+func (d *differ[T]) diff(a0, a1, b0, b1 int) {
+	prefix := 0
+	for a0+prefix < a1 && b0+prefix < b1 && d.a[a0+prefix] == d.b[b0+prefix] {
+		prefix++
+	}
+	d.push(diffEqual, a0, b0, prefix)
+	a0, b0 = a0+prefix, b0+prefix
+	suffix := 0
+	for a1-suffix > a0 && b1-suffix > b0 && d.a[a1-1-suffix] == d.b[b1-1-suffix] {
+		suffix++
+	}
+	a1, b1 = a1-suffix, b1-suffix
+
+	switch {
+	case a0 == a1:
+		d.push(diffInsert, a0, b0, b1-b0)
+	case b0 == b1:
+		d.push(diffDelete, a0, b0, a1-a0)
+	default:
+		if x, y, ok := d.split(a0, a1, b0, b1); ok {
+			d.diff(a0, x, b0, y)
+			d.diff(x, a1, y, b1)
+		} else {
+			d.push(diffDelete, a0, b0, a1-a0)
+			d.push(diffInsert, a1, b0, b1-b0)
+		}
+	}
+	d.push(diffEqual, a1, b1, suffix)
+}
+
+// split returns a point (x, y) on a shortest path from (a0, b0) to (a1, b1),
+// found by searching from both ends until the paths meet. It reports false
+// if the sequences have nothing in common, or differ by more than diffMaxD
+// edits. a[a0:a1] and b[b0:b1] must both be non-empty.
+func (d *differ[T]) split(a0, a1, b0, b1 int) (x, y int, ok bool) {
+	n, m := a1-a0, b1-b0
+	maxD := min((n+m+1)/2, diffMaxD)
+	offset := maxD
+	// vf[offset+k] and vr[offset+k] hold the furthest x reached on diagonal k
+	// searching forward from the start and backward from the end, where the
+	// backward search counts x and y from the end.
+	vf := make([]int, 2*maxD+2)
+	vr := make([]int, 2*maxD+2)
+	for i := range vf {
+		vf[i], vr[i] = -1, -1
+	}
+	vf[offset+1], vr[offset+1] = 0, 0
+	delta := n - m
+	// The paths meet on a forward step if delta is odd, else on a backward one.
+	front := delta%2 != 0
+	// Diagonals which ran off the edge of the grid are not searched again.
+	var kfStart, kfEnd, krStart, krEnd int
+
+	for step := 0; step < maxD; step++ {
+		for k := -step + kfStart; k <= step-kfEnd; k += 2 {
+			i := offset + k
+			var x int
+			if k == -step || (k != step && vf[i-1] < vf[i+1]) {
+				x = vf[i+1]
+			} else {
+				x = vf[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[a0+x] == d.b[b0+y] {
+				x++
+				y++
+			}
+			vf[i] = x
+			switch {
+			case x > n:
+				kfEnd += 2
+			case y > m:
+				kfStart += 2
+			case front:
+				if j := offset + delta - k; j >= 0 && j < len(vr) && vr[j] != -1 && x >= n-vr[j] {
+					return a0 + x, b0 + y, true
+				}
+			}
+		}
+		for k := -step + krStart; k <= step-krEnd; k += 2 {
+			i := offset + k
+			var x int
+			if k == -step || (k != step && vr[i-1] < vr[i+1]) {
+				x = vr[i+1]
+			} else {
+				x = vr[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[a1-1-x] == d.b[b1-1-y] {
+				x++
+				y++
+			}
+			vr[i] = x
+			switch {
+			case x > n:
+				krEnd += 2
+			case y > m:
+				krStart += 2
+			case !front:
+				if j := offset + delta - k; j >= 0 && j < len(vf) && vf[j] != -1 && vf[j] >= n-x {
+					fx := vf[j]
+					return a0 + fx, b0 + fx - (j - offset), true
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// DiffOps returns a minimal sequence of list operations which turns oldItems
+// into newItems. Each operation's position refers to the list as left by the
+// operations before it.
+func DiffOps[T comparable](oldItems, newItems []T) []ListOp[T] {
+	var ops []ListOp[T]
+	pos := 0
+	for _, run := range myersDiff(oldItems, newItems) {
+		switch run.Kind {
+		case diffEqual:
+			pos += run.Len
+		case diffDelete:
+			for i := 0; i < run.Len; i++ {
+				ops = append(ops, ListOp[T]{Type: ListOpTypeDelete, Pos: pos})
+			}
+		case diffInsert:
+			for i := 0; i < run.Len; i++ {
+				ops = append(ops, ListOp[T]{Type: ListOpTypeInsert, Pos: pos, Content: newItems[run.BStart+i]})
+				pos++
+			}
+		}
+	}
+	return ops
+}
+
+// ApplyDiff diffs the walker's current content against target and commits,
+// on behalf of agent, the local inserts and deletes needed to reach it as a
+// single span. This lets clients which only send whole new versions of a
+// list take part in the collaborative history. It returns the LVs of the new
+// operations. If it fails, the walker is left untouched.
+func ApplyDiff[T comparable](w *Walker[T], agent string, target []T) ([]causalgraph.LV, error) {
+	r, err := w.Transact(agent, func(tx *Tx[T]) error {
+		for _, op := range DiffOps(w.GetActiveItems(), target) {
+			var err error
+			if op.Type == ListOpTypeInsert {
+				err = tx.Insert(op.Pos, op.Content)
+			} else {
+				err = tx.Delete(op.Pos)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("applyDiff: %w", err)
+	}
+	var lvs []causalgraph.LV
+	for lv := r.Start; lv < r.End; lv++ {
+		lvs = append(lvs, lv)
+	}
+	return lvs, nil
+}
+
+// SetText replaces the document's text with text on behalf of agent, emitting
+// a minimal set of insert and delete runs computed by diffing the current text
+// against the new one. The runs are committed as a single span, so if it
+// fails, the document is left untouched.
+func (d *TextDoc) SetText(agent string, text string) error {
+	newRunes := []rune(text)
+	var ops []TextOp
+	pos := 0
+	for _, run := range myersDiff(d.content.Slice(), newRunes) {
+		switch run.Kind {
+		case diffEqual:
+			pos += run.Len
+		case diffDelete:
+			ops = append(ops, TextOp{Type: ListOpTypeDelete, Pos: pos, Len: run.Len})
+		case diffInsert:
+			ops = append(ops, TextOp{Type: ListOpTypeInsert, Pos: pos, Len: run.Len, Content: string(newRunes[run.BStart : run.BStart+run.Len])})
+			pos += run.Len
+		}
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+	if _, err := d.local(agent, ops...); err != nil {
+		return fmt.Errorf("setText: %w", err)
+	}
+	return nil
+}
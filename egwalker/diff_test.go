@@ -0,0 +1,206 @@
+package egwalker
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// applyListOps applies ops in order to a copy of items.
+func applyListOps[T any](items []T, ops []ListOp[T]) []T {
+	out := append([]T(nil), items...)
+	for _, op := range ops {
+		if op.Type == ListOpTypeInsert {
+			out = append(out, op.Content)
+			copy(out[op.Pos+1:], out[op.Pos:])
+			out[op.Pos] = op.Content
+		} else {
+			out = append(out[:op.Pos], out[op.Pos+1:]...)
+		}
+	}
+	return out
+}
+
+func TestDiffOps(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		wantOps int
+	}{
+		{"Empty", "", "", 0},
+		{"Identical", "hello", "hello", 0},
+		{"InsertAll", "", "abc", 3},
+		{"DeleteAll", "abc", "", 3},
+		{"Middle", "hello world", "hello brave world", 6},
+		{"Myers", "ABCABBA", "CBABAC", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old, next := []rune(tt.old), []rune(tt.new)
+			ops := DiffOps(old, next)
+			if len(ops) != tt.wantOps {
+				t.Errorf("got %d ops, want %d", len(ops), tt.wantOps)
+			}
+			if got := string(applyListOps(old, ops)); got != tt.new {
+				t.Errorf("applying ops gave %q, want %q", got, tt.new)
+			}
+		})
+	}
+}
+
+func TestDiffOps_Random(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	randString := func() []byte {
+		b := make([]byte, rng.IntN(30))
+		for i := range b {
+			b[i] = "abc"[rng.IntN(3)]
+		}
+		return b
+	}
+	for i := 0; i < 500; i++ {
+		old, next := randString(), randString()
+		ops := DiffOps(old, next)
+		if got := applyListOps(old, ops); string(got) != string(next) {
+			t.Fatalf("diff %q -> %q produced %q", old, next, got)
+		}
+		if want := len(old) + len(next) - 2*lcsLen(old, next); len(ops) != want {
+			t.Fatalf("diff %q -> %q has %d ops, want %d", old, next, len(ops), want)
+		}
+	}
+}
+
+// lcsLen returns the length of the longest common subsequence of a and b.
+func lcsLen(a, b []byte) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func TestDiffOps_Large(t *testing.T) {
+	// Unrelated sequences differ by more than diffMaxD edits, so they are
+	// replaced wholesale rather than searched for a minimal script.
+	old, next := make([]int, 20000), make([]int, 20000)
+	for i := range old {
+		old[i], next[i] = i, -i-1
+	}
+	ops := DiffOps(old, next)
+	if len(ops) != len(old)+len(next) {
+		t.Errorf("got %d ops, want %d", len(ops), len(old)+len(next))
+	}
+	if got := applyListOps(old, ops); !reflect.DeepEqual(got, next) {
+		t.Error("applying ops did not give the new sequence")
+	}
+
+	// Large sequences with scattered changes still get a minimal script.
+	rng := rand.New(rand.NewPCG(3, 4))
+	next = append([]int(nil), old...)
+	for i := 0; i < 50; i++ {
+		next[rng.IntN(len(next))] = -1
+	}
+	ops = DiffOps(old, next)
+	changed := 0
+	for i := range old {
+		if old[i] != next[i] {
+			changed++
+		}
+	}
+	if len(ops) != 2*changed {
+		t.Errorf("got %d ops, want %d", len(ops), 2*changed)
+	}
+	if got := applyListOps(old, ops); !reflect.DeepEqual(got, next) {
+		t.Error("applying ops did not give the new sequence")
+	}
+}
+
+func TestApplyDiff_Walker(t *testing.T) {
+	w := NewWalker[string]()
+	if _, err := ApplyDiff(w, "alice", []string{"a", "b", "c"}); err != nil {
+		t.Fatalf("ApplyDiff failed: %v", err)
+	}
+	lvs, err := ApplyDiff(w, "alice", []string{"a", "x", "c", "d"})
+	if err != nil {
+		t.Fatalf("ApplyDiff failed: %v", err)
+	}
+	if len(lvs) != 3 {
+		t.Errorf("expected 3 new ops, got %d", len(lvs))
+	}
+	if got, want := w.GetActiveItems(), []string{"a", "x", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTextDoc_SetText_Merges(t *testing.T) {
+	a := NewTextDoc()
+	if err := a.SetText("alice", "The quick fox"); err != nil {
+		t.Fatalf("SetText failed: %v", err)
+	}
+	b := NewTextDoc()
+	syncTextDocs(t, b, a)
+
+	// Each peer saves a whole new version; the edits merge like keystrokes.
+	if err := a.SetText("alice", "The quick brown fox"); err != nil {
+		t.Fatalf("SetText failed: %v", err)
+	}
+	if err := b.SetText("bob", "The quick fox jumps"); err != nil {
+		t.Fatalf("SetText failed: %v", err)
+	}
+	syncTextDocs(t, a, b)
+	syncTextDocs(t, b, a)
+
+	want := "The quick brown fox jumps"
+	if a.String() != want || b.String() != want {
+		t.Errorf("got %q and %q, want %q", a.String(), b.String(), want)
+	}
+	// An unchanged save produces no operations.
+	before := a.GetCG().NextLV
+	if err := a.SetText("alice", strings.Clone(want)); err != nil {
+		t.Fatalf("SetText failed: %v", err)
+	}
+	if a.GetCG().NextLV != before {
+		t.Errorf("unchanged SetText logged %d ops", a.GetCG().NextLV-before)
+	}
+}
+
+func TestApplyDiff_Atomic(t *testing.T) {
+	w := NewWalker[string]()
+	if _, err := ApplyDiff(w, "alice", []string{"a", "b"}); err != nil {
+		t.Fatalf("ApplyDiff failed: %v", err)
+	}
+	// The whole diff is one span, which is too long.
+	w.Log.CG.Limits = &causalgraph.Limits{MaxSpanLen: 2}
+	if _, err := ApplyDiff(w, "alice", []string{"x", "y", "z"}); err == nil {
+		t.Fatal("expected ApplyDiff to fail")
+	}
+	if got, want := w.GetActiveItems(), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("failed ApplyDiff changed the walker: got %v, want %v", got, want)
+	}
+
+	d := NewTextDoc()
+	if err := d.SetText("alice", "ab"); err != nil {
+		t.Fatalf("SetText failed: %v", err)
+	}
+	d.Log.CG.Limits = &causalgraph.Limits{MaxSpanLen: 2}
+	if err := d.SetText("alice", "xyz"); err == nil {
+		t.Fatal("expected SetText to fail")
+	}
+	if got := d.String(); got != "ab" {
+		t.Errorf("failed SetText changed the document: got %q, want %q", got, "ab")
+	}
+	if d.GetCG().NextLV != 2 {
+		t.Errorf("failed SetText logged ops: NextLV %d", d.GetCG().NextLV)
+	}
+}
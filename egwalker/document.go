@@ -0,0 +1,254 @@
+package egwalker
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// Document is a thread-safe wrapper around a Walker.
+// Any number of goroutines may read from a Document (checkouts, version
+// queries) concurrently, while operations are integrated by a single writer
+// at a time.
+type Document[T any] struct {
+	mu sync.RWMutex
+	w  *Walker[T]
+
+	// observers are the document's subscribers. While there are any, the
+	// walker's events are collected in pending and delivered by unlock once
+	// mu is released, so observers may read the document.
+	observers      []observer[T]
+	nextObserverID int
+	stopObserving  func()
+	pending        []ChangeEvent[T]
+
+	// queueMu guards queue and delivering. It is only held briefly, never
+	// while calling observers.
+	queueMu sync.Mutex
+	// queue holds the events awaiting delivery, in the order the writes were
+	// made.
+	queue []delivery[T]
+	// delivering is set while a writer is draining queue.
+	delivering bool
+}
+
+// delivery is an event along with the observers subscribed when it happened.
+type delivery[T any] struct {
+	ev        ChangeEvent[T]
+	observers []observer[T]
+}
+
+// NewDocument creates a new, empty Document.
+func NewDocument[T any]() *Document[T] {
+	return &Document[T]{w: NewWalker[T]()}
+}
+
+// LocalInsert inserts content at pos in the current document on behalf of agent.
+func (d *Document[T]) LocalInsert(agent string, pos int, content T) (causalgraph.LV, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	return d.w.LocalInsert(agent, pos, content)
+}
+
+// LocalDelete deletes the element at pos in the current document on behalf of agent.
+func (d *Document[T]) LocalDelete(agent string, pos int) (causalgraph.LV, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	return d.w.LocalDelete(agent, pos)
+}
+
+// LocalMove moves the element at from to to in the current document on
+// behalf of agent. See Walker.LocalMove.
+func (d *Document[T]) LocalMove(agent string, from, to int) (causalgraph.LV, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	return d.w.LocalMove(agent, from, to)
+}
+
+// Transact builds and commits a group of local operations atomically on
+// behalf of agent. See Walker.Transact.
+func (d *Document[T]) Transact(agent string, fn func(tx *Tx[T]) error) (causalgraph.LVRange, error) {
+	d.mu.Lock()
+	defer d.unlock()
+	return d.w.Transact(agent, fn)
+}
+
+// ApplyRemote integrates a span of operations received from another peer.
+func (d *Document[T]) ApplyRemote(span RemoteSpan[T]) error {
+	d.mu.Lock()
+	defer d.unlock()
+	return d.w.ApplyRemote(span)
+}
+
+// Spans returns the document's whole history as spans for another peer.
+func (d *Document[T]) Spans() ([]RemoteSpan[T], error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.w.Spans()
+}
+
+// Checkout returns the document snapshot at version.
+func (d *Document[T]) Checkout(version []causalgraph.LV) (*Branch[T], error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.w.Checkout(version)
+}
+
+// CheckoutMany returns the document snapshots at each of versions.
+// See Walker.CheckoutMany.
+func (d *Document[T]) CheckoutMany(versions [][]causalgraph.LV, opts CheckoutOptions) ([]*Branch[T], error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.w.CheckoutMany(versions, opts)
+}
+
+// Items returns a copy of the current document content.
+func (d *Document[T]) Items() []T {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.w.GetActiveItems()
+}
+
+// View returns an immutable snapshot of the current document. The read lock is
+// only held while the snapshot handle is taken, so readers holding Views never
+// block writers.
+func (d *Document[T]) View() View[T] {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.w.View()
+}
+
+// Len returns the number of elements in the current document.
+func (d *Document[T]) Len() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.w.Len()
+}
+
+// Version returns the current version (frontier) of the document.
+func (d *Document[T]) Version() []causalgraph.LV {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.w.GetVersion()
+}
+
+// RawVersion returns the current version of the document as raw versions,
+// which are meaningful to other peers.
+func (d *Document[T]) RawVersion() ([]causalgraph.RawVersion, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return causalgraph.LVToRawList(&d.w.Log.CG, d.w.Log.CG.Heads)
+}
+
+// HasVersion reports whether the document contains the operation raw.
+func (d *Document[T]) HasVersion(raw causalgraph.RawVersion) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	_, err := causalgraph.RawToLV(&d.w.Log.CG, raw.Agent, raw.Seq)
+	return err == nil
+}
+
+// AnchorAt returns an anchor for pos in the current document.
+func (d *Document[T]) AnchorAt(pos int, bias AnchorBias) (Anchor, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.w.AnchorAt(pos, bias)
+}
+
+// ResolveAnchor returns the current position of a.
+func (d *Document[T]) ResolveAnchor(a Anchor) (int, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.w.ResolveAnchor(a)
+}
+
+// Subscribe registers fn to be called after every change to the document, in
+// the order changes are made. fn is called without the document's lock held,
+// so it may read the document, but it must not modify it. Calls are made one
+// at a time, on the goroutine of the change's writer or of an earlier writer
+// still delivering events, so the document may have changed further by the
+// time fn runs. If fn panics, the panic reaches that writer, and later events
+// are still delivered. The returned function unsubscribes fn; it is safe to
+// call more than once.
+func (d *Document[T]) Subscribe(fn func(ChangeEvent[T])) (unsubscribe func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id := d.nextObserverID
+	d.nextObserverID++
+	d.observers = append(d.observers, observer[T]{id: id, fn: fn})
+	if d.stopObserving == nil {
+		d.stopObserving = d.w.Subscribe(func(ev ChangeEvent[T]) {
+			d.pending = append(d.pending, ev)
+		})
+	}
+	return func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		for i, o := range d.observers {
+			if o.id == id {
+				d.observers = append(d.observers[:i:i], d.observers[i+1:]...)
+				break
+			}
+		}
+		if len(d.observers) == 0 && d.stopObserving != nil {
+			d.stopObserving()
+			d.stopObserving = nil
+		}
+	}
+}
+
+// unlock releases the write lock and delivers the events collected while it
+// was held. The events are queued before mu is released, so they keep the
+// order of the writes. A single writer at a time drains the queue, after
+// releasing mu, so observers reading the document never wait on a writer
+// waiting on them.
+func (d *Document[T]) unlock() {
+	events := d.pending
+	d.pending = nil
+	if len(events) == 0 {
+		d.mu.Unlock()
+		return
+	}
+	observers := append([]observer[T]{}, d.observers...)
+	d.queueMu.Lock()
+	for _, ev := range events {
+		d.queue = append(d.queue, delivery[T]{ev: ev, observers: observers})
+	}
+	drain := !d.delivering
+	d.delivering = true
+	d.queueMu.Unlock()
+	d.mu.Unlock()
+	if !drain {
+		return
+	}
+	// If an observer panics, the panic reaches this writer, and the next
+	// writer takes over delivering the events after the one that panicked.
+	var batch []delivery[T]
+	delivered := 0
+	defer func() {
+		if delivered < len(batch) {
+			d.queueMu.Lock()
+			d.queue = append(slices.Clone(batch[delivered+1:]), d.queue...)
+			d.delivering = false
+			d.queueMu.Unlock()
+		}
+	}()
+	for {
+		d.queueMu.Lock()
+		batch, delivered = d.queue, 0
+		d.queue = nil
+		if len(batch) == 0 {
+			d.delivering = false
+			d.queueMu.Unlock()
+			return
+		}
+		d.queueMu.Unlock()
+		for _, dl := range batch {
+			for _, o := range dl.observers {
+				o.fn(dl.ev)
+			}
+			delivered++
+		}
+	}
+}
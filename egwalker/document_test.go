@@ -0,0 +1,390 @@
+package egwalker
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+func TestDocument_LocalEdits(t *testing.T) {
+	doc := NewDocument[string]()
+	if _, err := doc.LocalInsert("alice", 0, "a"); err != nil {
+		t.Fatalf("LocalInsert failed: %v", err)
+	}
+	if _, err := doc.LocalInsert("alice", 1, "b"); err != nil {
+		t.Fatalf("LocalInsert failed: %v", err)
+	}
+	if _, err := doc.LocalDelete("alice", 0); err != nil {
+		t.Fatalf("LocalDelete failed: %v", err)
+	}
+
+	if got, want := doc.Items(), []string{"b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Items: got %v, want %v", got, want)
+	}
+	if doc.Len() != 1 {
+		t.Errorf("Len: got %d, want 1", doc.Len())
+	}
+	compareLVSlices(t, doc.Version(), []causalgraph.LV{2})
+
+	raw, err := doc.RawVersion()
+	if err != nil {
+		t.Fatalf("RawVersion failed: %v", err)
+	}
+	if want := []causalgraph.RawVersion{{Agent: "alice", Seq: 2}}; !reflect.DeepEqual(raw, want) {
+		t.Errorf("RawVersion: got %v, want %v", raw, want)
+	}
+	if !doc.HasVersion(causalgraph.RawVersion{Agent: "alice", Seq: 1}) {
+		t.Error("HasVersion(alice:1) = false, want true")
+	}
+	if doc.HasVersion(causalgraph.RawVersion{Agent: "bob", Seq: 0}) {
+		t.Error("HasVersion(bob:0) = true, want false")
+	}
+}
+
+func TestDocument_View_Immutable(t *testing.T) {
+	doc := NewDocument[string]()
+	doc.LocalInsert("alice", 0, "a")
+	doc.LocalInsert("alice", 1, "b")
+
+	view := doc.View()
+	doc.LocalDelete("alice", 0)
+	doc.LocalInsert("alice", 1, "c")
+
+	if got, want := view.Content.Slice(), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("view changed after writes: got %v, want %v", got, want)
+	}
+	compareLVSlices(t, view.Version, []causalgraph.LV{1})
+	if got, want := doc.View().Content.Slice(), []string{"b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("new view: got %v, want %v", got, want)
+	}
+
+	branch := view.Branch()
+	if got, want := branch.Snapshot, []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Branch snapshot: got %v, want %v", got, want)
+	}
+}
+
+func TestDocument_Views_ParallelWithWriter(t *testing.T) {
+	doc := NewDocument[int]()
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	for r := 0; r < 8; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				view := doc.View()
+				// Writers only append, so a view must hold 0..n-1 in order
+				// where n is the number of ops in its version.
+				n := 0
+				if len(view.Version) > 0 {
+					n = int(view.Version[0]) + 1
+				}
+				if view.Content.Len() != n {
+					t.Errorf("view at %v has %d items, want %d", view.Version, view.Content.Len(), n)
+					return
+				}
+				view.Content.Each(func(i int, v int) bool {
+					if v != i {
+						t.Errorf("view at %v: item %d = %d", view.Version, i, v)
+						return false
+					}
+					return true
+				})
+			}
+		}()
+	}
+
+	for i := 0; i < 300; i++ {
+		if _, err := doc.LocalInsert("writer", i, i); err != nil {
+			t.Fatalf("LocalInsert failed: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestDocument_ParallelReadersAndWriters(t *testing.T) {
+	const (
+		writers      = 4
+		opsPerWriter = 25
+		readers      = 4
+	)
+	doc := NewDocument[int]()
+
+	var writersWG, readersWG sync.WaitGroup
+	done := make(chan struct{})
+
+	for r := 0; r < readers; r++ {
+		readersWG.Add(1)
+		go func() {
+			defer readersWG.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				version := doc.Version()
+				branch, err := doc.Checkout(version)
+				if err != nil {
+					t.Errorf("Checkout(%v) failed: %v", version, err)
+					return
+				}
+				// Every writer only inserts, so a version's length is its op count.
+				if want := len(historyOf(t, doc, version)); len(branch.Snapshot) != want {
+					t.Errorf("Checkout(%v): got %d items, want %d", version, len(branch.Snapshot), want)
+					return
+				}
+				_ = doc.Items()
+			}
+		}()
+	}
+
+	for wi := 0; wi < writers; wi++ {
+		writersWG.Add(1)
+		go func(wi int) {
+			defer writersWG.Done()
+			agent := fmt.Sprintf("agent%d", wi)
+			for i := 0; i < opsPerWriter; i++ {
+				if _, err := doc.LocalInsert(agent, 0, wi*opsPerWriter+i); err != nil {
+					t.Errorf("LocalInsert failed: %v", err)
+					return
+				}
+			}
+		}(wi)
+	}
+
+	writersWG.Wait()
+	close(done)
+	readersWG.Wait()
+
+	if got := doc.Len(); got != writers*opsPerWriter {
+		t.Errorf("Len: got %d, want %d", got, writers*opsPerWriter)
+	}
+}
+
+func TestDocument_ParallelSync(t *testing.T) {
+	const peers = 4
+	docs := make([]*Document[string], peers)
+	for i := range docs {
+		docs[i] = NewDocument[string]()
+	}
+
+	var wg sync.WaitGroup
+	for i := range docs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			agent := fmt.Sprintf("peer%d", i)
+			for j := 0; j < 20; j++ {
+				if _, err := docs[i].LocalInsert(agent, docs[i].Len(), fmt.Sprintf("%d.%d", i, j)); err != nil {
+					t.Errorf("LocalInsert failed: %v", err)
+					return
+				}
+				// Push our history to the next peer while it is editing too.
+				spans, err := docs[i].Spans()
+				if err != nil {
+					t.Errorf("Spans failed: %v", err)
+					return
+				}
+				for _, span := range spans {
+					if err := docs[(i+1)%peers].ApplyRemote(span); err != nil {
+						t.Errorf("ApplyRemote failed: %v", err)
+						return
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Finish with a full exchange so every peer has every operation.
+	for round := 0; round < 2; round++ {
+		for i := range docs {
+			spans, err := docs[i].Spans()
+			if err != nil {
+				t.Fatalf("Spans failed: %v", err)
+			}
+			for j := range docs {
+				for _, span := range spans {
+					if err := docs[j].ApplyRemote(span); err != nil {
+						t.Fatalf("ApplyRemote failed: %v", err)
+					}
+				}
+			}
+		}
+	}
+
+	want := docs[0].Items()
+	if len(want) != peers*20 {
+		t.Fatalf("expected %d items, got %d", peers*20, len(want))
+	}
+	for i := 1; i < peers; i++ {
+		if got := docs[i].Items(); !reflect.DeepEqual(got, want) {
+			t.Errorf("peer %d diverged:\ngot:  %v\nwant: %v", i, got, want)
+		}
+	}
+}
+
+// historyOf returns the set of versions in the history of version.
+func historyOf[T any](t *testing.T, doc *Document[T], version []causalgraph.LV) []causalgraph.LV {
+	t.Helper()
+	doc.mu.RLock()
+	defer doc.mu.RUnlock()
+	_, history, err := causalgraph.DiffVersions(&doc.w.Log.CG, nil, version)
+	if err != nil {
+		t.Fatalf("DiffVersions failed: %v", err)
+	}
+	var lvs []causalgraph.LV
+	for _, r := range history {
+		for lv := r.Start; lv < r.End; lv++ {
+			lvs = append(lvs, lv)
+		}
+	}
+	return lvs
+}
+
+func TestDocument_Transact(t *testing.T) {
+	d := NewDocument[int]()
+	_, err := d.Transact("alice", func(tx *Tx[int]) error {
+		for i := 0; i < 3; i++ {
+			if err := tx.Insert(i, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Transact failed: %v", err)
+	}
+	if got := d.Len(); got != 3 {
+		t.Errorf("got length %d, want 3", got)
+	}
+}
+
+func TestDocument_LocalMove(t *testing.T) {
+	d := NewDocument[int]()
+	for i := 0; i < 3; i++ {
+		d.LocalInsert("alice", i, i)
+	}
+	if _, err := d.LocalMove("alice", 2, 0); err != nil {
+		t.Fatalf("LocalMove failed: %v", err)
+	}
+	if got, want := d.Items(), []int{2, 0, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDocument_Subscribe_Ordered(t *testing.T) {
+	d := NewDocument[int]()
+	var m mirror[int]
+	var lengths []int
+	unsubscribe := d.Subscribe(func(ev ChangeEvent[int]) {
+		m.observe(ev)
+		lengths = append(lengths, d.Len()) // Reading from a callback must not deadlock.
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(agent string) {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				if _, err := d.LocalInsert(agent, 0, j); err != nil {
+					t.Errorf("LocalInsert failed: %v", err)
+				}
+			}
+		}(fmt.Sprintf("agent%d", i))
+	}
+	wg.Wait()
+	unsubscribe()
+
+	if len(m.events) != 100 || len(lengths) != 100 {
+		t.Fatalf("expected 100 events, got %d", len(m.events))
+	}
+	for i, ev := range m.events {
+		if ev.LVs.Start != causalgraph.LV(i) {
+			t.Fatalf("event %d delivered out of order: %+v", i, ev.LVs)
+		}
+	}
+	if got := d.Items(); !reflect.DeepEqual(m.items, got) {
+		t.Errorf("mirror got %v, want %v", m.items, got)
+	}
+}
+
+func TestDocument_Subscribe_ObserverPanics(t *testing.T) {
+	d := NewDocument[int]()
+	var got []causalgraph.LV
+	d.Subscribe(func(ev ChangeEvent[int]) {
+		if ev.LVs.Start == 0 {
+			panic("boom")
+		}
+		got = append(got, ev.LVs.Start)
+	})
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("expected the observer's panic, got %v", r)
+			}
+		}()
+		d.LocalInsert("alice", 0, 1)
+	}()
+	// The document still delivers events.
+	if _, err := d.LocalInsert("alice", 1, 2); err != nil {
+		t.Fatalf("LocalInsert failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, []causalgraph.LV{1}) {
+		t.Errorf("got events %v, want [1]", got)
+	}
+}
+
+func TestDocument_Subscribe_ReadDuringWrites(t *testing.T) {
+	d := NewDocument[int]()
+	var delivered int
+	d.Subscribe(func(ev ChangeEvent[int]) {
+		// Give the other writer time to take the lock, then read.
+		time.Sleep(10 * time.Microsecond)
+		if d.Len() < int(ev.LVs.End) {
+			t.Errorf("document shorter than event %+v", ev.LVs)
+		}
+		delivered++
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func(agent string) {
+				defer wg.Done()
+				for j := 0; j < 200; j++ {
+					if _, err := d.LocalInsert(agent, 0, j); err != nil {
+						t.Errorf("LocalInsert failed: %v", err)
+					}
+				}
+			}(fmt.Sprintf("agent%d", i))
+		}
+		wg.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writers and an observer reading the document deadlocked")
+	}
+	if delivered != 400 {
+		t.Errorf("expected 400 events, got %d", delivered)
+	}
+}
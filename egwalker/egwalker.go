@@ -8,10 +8,12 @@ import (
 // newEditCtx creates and returns a new EditContext, initialized to an empty state.
 func newEditCtx() *EditContext {
 	return &EditContext{
-		Items:      []Item{},
-		DelTargets: make(map[causalgraph.LV]causalgraph.LV),
-		ItemsByLV:  make(map[causalgraph.LV]*Item),
-		CurVersion: []causalgraph.LV{}, // Starts at "root" or empty version
+		Items:       []Item{},
+		DelTargets:  make(map[causalgraph.LV]causalgraph.LV),
+		MoveTargets: make(map[causalgraph.LV]causalgraph.LV),
+		moved:       make(map[causalgraph.LV]*moveSet),
+		ItemsByLV:   make(map[causalgraph.LV]*Item),
+		CurVersion:  []causalgraph.LV{}, // Starts at "root" or empty version
 	}
 }
 
@@ -31,352 +33,531 @@ func NewWalker[T any]() *Walker[T] {
 // Integrate incorporates a new operation into the walker's log and context.
 // op: The operation to integrate.
 // agent: The agent ID creating this operation.
-// rawParents: The RawVersion parents of this operation. If nil, current Ctx.CurVersion is used.
+// rawParents: The RawVersion parents of this operation. If nil, the operation is
+// local: its parents are the graph heads and op.Pos refers to the current document.
 // Returns the LV of the integrated operation and an error if any.
 func (w *Walker[T]) Integrate(op ListOp[T], agent string, rawParents []causalgraph.RawVersion) (causalgraph.LV, error) {
-	// Tentative LV was previously len(w.Log.Ops), but actual LV comes from CG.
 	cgAgentID := causalgraph.AgentID(agent)
 	seq := causalgraph.NextSeqForAgent(&w.Log.CG, cgAgentID)
-	w.Log.Ops = append(w.Log.Ops, op) // Op is appended, its index will be len(w.Log.Ops)-1
 
 	var cgParents []causalgraph.RawVersion
 	if rawParents != nil {
 		cgParents = rawParents
 	} else {
+		if err := w.checkLocalPos(op); err != nil {
+			return -1, err
+		}
 		var err error
-		cgParents, err = causalgraph.LVToRawList(&w.Log.CG, w.Ctx.CurVersion)
+		cgParents, err = causalgraph.LVToRawList(&w.Log.CG, w.Log.CG.Heads)
 		if err != nil {
-			w.Log.Ops = w.Log.Ops[:len(w.Log.Ops)-1] // Rollback op append
 			return -1, fmt.Errorf("failed to convert current version to raw parents: %w", err)
 		}
+		if cgParents == nil {
+			cgParents = []causalgraph.RawVersion{}
+		}
 	}
 
+	w.changes = nil
 	id := causalgraph.RawVersion{Agent: cgAgentID, Seq: seq}
-	cgEntry, err := causalgraph.AddRaw(&w.Log.CG, id, 1, cgParents)
+	r, err := w.addSpan(id, cgParents, []ListOp[T]{op})
 	if err != nil {
-		w.Log.Ops = w.Log.Ops[:len(w.Log.Ops)-1] // Rollback op append
-		return -1, fmt.Errorf("failed to add to causal graph: %w", err)
-	}
-	if cgEntry == nil {
-		w.Log.Ops = w.Log.Ops[:len(w.Log.Ops)-1] // Rollback op append
-		return -1, fmt.Errorf("operation (%s, %d) already exists in causal graph or failed to add", agent, seq)
+		return -1, err
 	}
+	w.emit(r, rawParents == nil)
+	return r.Start, nil
+}
 
-	actualLV := cgEntry.Version
-	// The op log index for this operation is len(w.Log.Ops)-1.
-	// We need to ensure that applyOp and retreatOp can find the op if actualLV is not the same as this index.
-	// For now, assume they are aligned or that applyOp/retreatOp use a mapping if necessary.
-	// The current applyOp/retreatOp use LV as an index into w.Log.Ops. This implies actualLV must be len(w.Log.Ops)-1.
-	// This holds if LVs are assigned sequentially starting from 0 and incrementing by 1 for each op.
-	// causalgraph.AddRaw uses cg.NextLV, which is total ops length. So this should align.
-
-	if rawParents == nil { // Implies local op, advance context version and apply op
-		w.Ctx.CurVersion = []causalgraph.LV{actualLV}
-		if errApply := w.applyOp(actualLV); errApply != nil {
-			return actualLV, fmt.Errorf("op integrated (LV %d) but failed to apply to context: %w", actualLV, errApply)
+// checkLocalPos verifies that a local operation's position is valid in the
+// current document, so invalid edits are rejected before they reach the log.
+func (w *Walker[T]) checkLocalPos(op ListOp[T]) error {
+	length := w.Len()
+	switch op.Type {
+	case ListOpTypeInsert:
+		if op.Pos < 0 || op.Pos > length {
+			return fmt.Errorf("insert: %w", &ErrPosOutOfRange{Pos: op.Pos, Len: length})
+		}
+	case ListOpTypeDelete:
+		if op.Pos < 0 || op.Pos >= length {
+			return fmt.Errorf("delete: %w", &ErrPosOutOfRange{Pos: op.Pos, Len: length})
+		}
+	case ListOpTypeMove:
+		if op.From < 0 || op.From >= length {
+			return fmt.Errorf("move source: %w", &ErrPosOutOfRange{Pos: op.From, Len: length})
+		}
+		if op.Pos < 0 || op.Pos >= length {
+			return fmt.Errorf("move destination: %w", &ErrPosOutOfRange{Pos: op.Pos, Len: length})
 		}
+	default:
+		return fmt.Errorf("%w: unknown op type %q", ErrInvalidOp, op.Type)
 	}
-	return actualLV, nil
+	return nil
 }
 
-// applyOp applies a single operation (specified by its LV) to the EditContext.
-// It modifies Ctx.Items and Ctx.DelTargets. This is an internal method.
-func (w *Walker[T]) applyOp(lv causalgraph.LV) error {
-	// Assuming LV is the index in w.Log.Ops for this operation.
-	opIndex := int(lv)
-	if opIndex < 0 || opIndex >= len(w.Log.Ops) {
-		return fmt.Errorf("applyOp: LV %d (index %d) is out of bounds for op log of length %d", lv, opIndex, len(w.Log.Ops))
+// ApplyRemote integrates a span of operations received from another peer.
+// Operations the walker already knows are skipped, so spans may be delivered
+// more than once.
+func (w *Walker[T]) ApplyRemote(span RemoteSpan[T]) error {
+	id, parents, ops := span.ID, span.Parents, span.Ops
+	if len(ops) == 0 {
+		return nil
+	}
+	w.changes = nil
+	if parents == nil {
+		// A nil slice would make AddRaw use our own heads.
+		parents = []causalgraph.RawVersion{}
 	}
-	op := w.Log.Ops[opIndex]
 
-	switch op.Type {
-	case ListOpTypeInsert:
-		newItem := Item{
-			OpID:        lv,
-			CurState:    Inserted,
-			EndState:    NotYetInserted,
-			OriginLeft:  -1,
-			RightParent: -1,
+	if next := causalgraph.NextSeqForAgent(&w.Log.CG, id.Agent); id.Seq < next {
+		known := next - id.Seq
+		if known >= len(ops) {
+			return nil
 		}
-		// The item is first created, then a pointer to it is stored in ItemsByLV.
-		// Then, this item (a copy of it) is inserted into the Items slice.
-		// To ensure ItemsByLV points to the item *in the slice*, we update it after insertion.
+		ops = ops[known:]
+		parents = []causalgraph.RawVersion{{Agent: id.Agent, Seq: next - 1}}
+		id.Seq = next
+	}
 
-		insertAtIndex := op.Pos
-		if insertAtIndex > len(w.Ctx.Items) {
-			insertAtIndex = len(w.Ctx.Items)
-		}
-		// Insert a temporary copy, then get a pointer to the actual item in the slice.
-		w.Ctx.Items = append(w.Ctx.Items[:insertAtIndex], append([]Item{newItem}, w.Ctx.Items[insertAtIndex:]...)...)
-		w.Ctx.ItemsByLV[lv] = &w.Ctx.Items[insertAtIndex] // Point to the newly inserted item in the slice.
+	r, err := w.addSpan(id, parents, ops)
+	if err != nil {
+		return fmt.Errorf("applyRemote: %w", err)
+	}
+	w.emit(r, false)
+	return nil
+}
 
-	case ListOpTypeDelete:
-		targetLV := causalgraph.LV(-1)
-		visibleCount := 0
-		foundTarget := false
-		for i := range w.Ctx.Items {
-			item := &w.Ctx.Items[i]
-			if item.CurState == Inserted {
-				if visibleCount == op.Pos {
-					targetLV = item.OpID
-					item.CurState = Deleted // Update the item in the slice
-					if itemInMap, ok := w.Ctx.ItemsByLV[targetLV]; ok {
-						itemInMap.CurState = Deleted // Also update the map entry if it's distinct
-					}
-					w.Ctx.DelTargets[lv] = targetLV
-					foundTarget = true
-					break
-				}
-				visibleCount++
-			}
+// Spans returns the walker's whole history as spans which can be passed to
+// ApplyRemote on another walker.
+func (w *Walker[T]) Spans() ([]RemoteSpan[T], error) {
+	spans := make([]RemoteSpan[T], 0, len(w.Log.CG.Entries))
+	for _, entry := range w.Log.CG.Entries {
+		parents, err := causalgraph.LVToRawList(&w.Log.CG, entry.Parents)
+		if err != nil {
+			return nil, fmt.Errorf("spans: %w", err)
 		}
-		if !foundTarget {
-			w.Ctx.DelTargets[lv] = causalgraph.LV(-1) // No-op
+		if parents == nil {
+			parents = []causalgraph.RawVersion{}
 		}
+		ops := make([]ListOp[T], entry.VEnd-entry.Version)
+		copy(ops, w.Log.Ops[entry.Version:entry.VEnd])
+		spans = append(spans, RemoteSpan[T]{
+			ID:      causalgraph.RawVersion{Agent: entry.Agent, Seq: entry.Seq},
+			Parents: parents,
+			Ops:     ops,
+		})
 	}
-	return nil
+	return spans, nil
 }
 
-// retreatOp un-applies a single operation (specified by its LV) from the EditContext.
-func (w *Walker[T]) retreatOp(lv causalgraph.LV) error {
-	opIndex := int(lv)
-	if opIndex < 0 || opIndex >= len(w.Log.Ops) {
-		return fmt.Errorf("retreatOp: LV %d (index %d) is out of bounds for op log of length %d", lv, opIndex, len(w.Log.Ops))
+// findByCurPos returns the index in Items of the item at pos in the current
+// version, along with the number of items before it in the merged document.
+func (ctx *EditContext) findByCurPos(pos int) (idx int, endPos int, err error) {
+	if pos < 0 {
+		return -1, -1, fmt.Errorf("%w: position %d is negative", ErrInvalidOp, pos)
 	}
-	op := w.Log.Ops[opIndex]
-	itemToModifyInMap, itemExistsInMap := w.Ctx.ItemsByLV[lv]
-
-	switch op.Type {
-	case ListOpTypeInsert:
-		if !itemExistsInMap {
-			return fmt.Errorf("retreatOp: item for insert LV %d not found in ItemsByLV", lv)
-		}
-		itemToModifyInMap.CurState = NotYetInserted
-		foundInSlice := false
-		for i := range w.Ctx.Items {
-			if w.Ctx.Items[i].OpID == lv {
-				w.Ctx.Items[i].CurState = NotYetInserted
-				foundInSlice = true
-				break
-			}
+	for curPos := 0; curPos < pos; idx++ {
+		if idx >= len(ctx.Items) {
+			return -1, -1, fmt.Errorf("%w: position %d is past the end of the document", ErrInvalidOp, pos)
 		}
-		if !foundInSlice {
-			return fmt.Errorf("retreatOp: item for insert LV %d found in ItemsByLV but not in Items slice", lv)
+		item := &ctx.Items[idx]
+		if item.CurState == Inserted {
+			curPos++
 		}
-	case ListOpTypeDelete:
-		targetLV, wasDeleteRecorded := w.Ctx.DelTargets[lv]
-		if !wasDeleteRecorded || targetLV == causalgraph.LV(-1) {
-			return nil
+		if item.EndState == Inserted {
+			endPos++
 		}
-		targetItemInMap, targetExistsInMap := w.Ctx.ItemsByLV[targetLV]
-		if !targetExistsInMap {
-			return fmt.Errorf("retreatOp: target item LV %d for delete op LV %d not found in ItemsByLV", targetLV, lv)
-		}
-		targetItemInMap.CurState = Inserted
-		foundInSlice := false
-		for i := range w.Ctx.Items {
-			if w.Ctx.Items[i].OpID == targetLV {
-				w.Ctx.Items[i].CurState = Inserted
-				foundInSlice = true
-				break
-			}
+	}
+	return idx, endPos, nil
+}
+
+// findItemIdx returns the index in Items of the item created by the insert at lv.
+func (ctx *EditContext) findItemIdx(lv causalgraph.LV) (int, error) {
+	for i := range ctx.Items {
+		if ctx.Items[i].OpID == lv {
+			return i, nil
 		}
-		if !foundInSlice {
-			return fmt.Errorf("retreatOp: target item LV %d for delete op LV %d found in ItemsByLV but not in Items slice", targetLV, lv)
+	}
+	return -1, fmt.Errorf("item for LV %d not found in Items", lv)
+}
+
+// insertItem splices item into Items at idx. ItemsByLV points into the Items
+// backing array, so every pointer that may have moved is refreshed.
+func (ctx *EditContext) insertItem(idx int, item Item) {
+	oldCap := cap(ctx.Items)
+	ctx.Items = append(ctx.Items, Item{})
+	copy(ctx.Items[idx+1:], ctx.Items[idx:])
+	ctx.Items[idx] = item
+
+	start := idx
+	if cap(ctx.Items) != oldCap {
+		start = 0
+	}
+	for i := start; i < len(ctx.Items); i++ {
+		ctx.ItemsByLV[ctx.Items[i].OpID] = &ctx.Items[i]
+	}
+}
+
+// advanceOp re-applies an already integrated operation to the current version.
+func (ctx *EditContext) advanceOp(lv causalgraph.LV) {
+	if ctx.advanceMoved(lv, 1) {
+		return
+	}
+	if targetLV, ok := ctx.DelTargets[lv]; ok {
+		if item, found := ctx.ItemsByLV[targetLV]; found {
+			item.CurState++
 		}
-		delete(w.Ctx.DelTargets, lv)
+		return
+	}
+	if item, ok := ctx.ItemsByLV[lv]; ok {
+		item.CurState++
 	}
-	return nil
 }
 
-// advance moves the EditContext forward from its current version to targetLV.
-func (w *Walker[T]) advance(targetLV causalgraph.LV) error {
-	opsToApply := []causalgraph.LV{}
-	err := causalgraph.IterVersionsBetween(&w.Log.CG, w.Ctx.CurVersion, targetLV,
-		func(v causalgraph.LV, isParentOfPrev bool, isMerge bool) (stop bool, err error) {
-			opsToApply = append(opsToApply, v)
-			return false, nil
-		})
+// retreatOp un-applies an already integrated operation from the current version.
+func (ctx *EditContext) retreatOp(lv causalgraph.LV) {
+	if ctx.advanceMoved(lv, -1) {
+		return
+	}
+	if targetLV, ok := ctx.DelTargets[lv]; ok {
+		if item, found := ctx.ItemsByLV[targetLV]; found {
+			item.CurState--
+		}
+		return
+	}
+	if item, ok := ctx.ItemsByLV[lv]; ok {
+		item.CurState--
+	}
+}
+
+// moveTo retreats and advances already integrated operations until the
+// current version of the context is target.
+func (ctx *EditContext) moveTo(cg *causalgraph.CausalGraph, target []causalgraph.LV) error {
+	if frontiersEqual(ctx.CurVersion, target) {
+		return nil
+	}
+	aOnly, bOnly, err := causalgraph.DiffVersions(cg, ctx.CurVersion, target)
 	if err != nil {
-		return fmt.Errorf("advance: error iterating: %w", err)
+		return fmt.Errorf("moveTo: %w", err)
+	}
+	for i := len(aOnly) - 1; i >= 0; i-- {
+		for lv := aOnly[i].End - 1; lv >= aOnly[i].Start; lv-- {
+			ctx.retreatOp(lv)
+		}
 	}
-	for i := len(opsToApply) - 1; i >= 0; i-- {
-		if err := w.applyOp(opsToApply[i]); err != nil {
-			return fmt.Errorf("advance: failed to apply op LV %d: %w", opsToApply[i], err)
+	for _, r := range bOnly {
+		for lv := r.Start; lv < r.End; lv++ {
+			ctx.advanceOp(lv)
 		}
 	}
-	w.Ctx.CurVersion = []causalgraph.LV{targetLV}
+	ctx.CurVersion = append([]causalgraph.LV{}, target...)
 	return nil
 }
 
-// retreat moves the EditContext backward from its current version to targetLV.
-func (w *Walker[T]) retreat(targetLV causalgraph.LV) error {
-	isAncestor, err := causalgraph.VersionContainsLV(&w.Log.CG, w.Ctx.CurVersion, targetLV)
-	if err != nil {
-		return fmt.Errorf("retreat: error checking ancestry for targetLV %d: %w", targetLV, err)
+// frontiersEqual reports whether two sorted frontiers name the same versions.
+func frontiersEqual(a, b []causalgraph.LV) bool {
+	if len(a) != len(b) {
+		return false
 	}
-	// If CurVersion is empty, targetLV must be a root-like LV (-1 or 0) or it's an invalid retreat.
-	if len(w.Ctx.CurVersion) == 0 {
-		if targetLV == causalgraph.LV(-1) || (targetLV == 0 && causalgraph.NextLV(&w.Log.CG) > 0) { // Allow retreat to 0 if graph is not empty
-			w.Ctx.CurVersion = []causalgraph.LV{targetLV}
-			return nil
-		} else if targetLV == 0 && causalgraph.NextLV(&w.Log.CG) == 0 { // Retreat to 0 on empty graph
-			w.Ctx.CurVersion = []causalgraph.LV{} // Or []LV{0} depending on convention for root of empty graph
-			return nil
+	for i := range a {
+		if a[i] != b[i] {
+			return false
 		}
-		return fmt.Errorf("retreat: cannot retreat from empty version to non-root LV %d", targetLV)
 	}
+	return true
+}
 
-	if !isAncestor && (len(w.Ctx.CurVersion) > 1 || w.Ctx.CurVersion[0] != targetLV) {
-		return fmt.Errorf("retreat: targetLV %d is not an ancestor of current version %v", targetLV, w.Ctx.CurVersion)
+// lvCmp orders two concurrent operations by their raw versions so that every
+// peer breaks ties between concurrent inserts the same way.
+func lvCmp(cg *causalgraph.CausalGraph, a, b causalgraph.LV) int {
+	rawA, _ := causalgraph.LVToRaw(cg, a)
+	rawB, _ := causalgraph.LVToRaw(cg, b)
+	if rawA.Agent < rawB.Agent {
+		return -1
 	}
+	if rawA.Agent > rawB.Agent {
+		return 1
+	}
+	return rawA.Seq - rawB.Seq
+}
 
-	if len(w.Ctx.CurVersion) != 1 {
-		if targetLV == causalgraph.LV(-1) && len(w.Ctx.CurVersion) > 1 {
-			*w.Ctx = *newEditCtx()
-			w.Ctx.CurVersion = []causalgraph.LV{targetLV} // Represent root as [-1]
-			return nil
-		}
-		// Fallthrough for more complex frontier retreats, currently simplified.
+// integrateOp moves ctx to the parents of the operation at lv, applies it and
+// leaves ctx at version [lv]. See applyOp for the returned position.
+func integrateOp[T any](ctx *EditContext, cg *causalgraph.CausalGraph, lv causalgraph.LV, op ListOp[T]) (int, error) {
+	_, _, parents, found := causalgraph.LVToRawWithParents(cg, lv)
+	if !found {
+		return -1, fmt.Errorf("integrateOp: LV %d not found in causal graph", lv)
+	}
+	if op.Type == ListOpTypeMove {
+		return -1, fmt.Errorf("integrateOp: LV %d is a move; use integrateMove", lv)
 	}
+	if err := ctx.moveTo(cg, parents); err != nil {
+		return -1, fmt.Errorf("integrateOp: LV %d: %w", lv, err)
+	}
+	endPos, err := applyOp(ctx, cg, lv, op)
+	if err != nil {
+		return -1, err
+	}
+	ctx.CurVersion = []causalgraph.LV{lv}
+	return endPos, nil
+}
+
+// applyOp applies a single operation (specified by its LV) to the EditContext.
+// ctx must be at the parents of the operation. It returns the position in the
+// merged document (every operation applied to ctx so far) at which content was
+// inserted or removed, or -1 if the merged document is unchanged.
+func applyOp[T any](ctx *EditContext, cg *causalgraph.CausalGraph, lv causalgraph.LV, op ListOp[T]) (int, error) {
+	switch op.Type {
+	case ListOpTypeInsert:
+		idx, endPos, err := ctx.findByCurPos(op.Pos)
+		if err != nil {
+			return -1, fmt.Errorf("applyOp: insert LV %d: %w", lv, err)
+		}
+		if idx >= 1 && ctx.Items[idx-1].CurState != Inserted {
+			return -1, fmt.Errorf("applyOp: insert LV %d: item to the left is not inserted", lv)
+		}
+		return integrate(ctx, cg, ctx.newItem(lv, idx), idx, endPos)
 
-	currentTip := w.Ctx.CurVersion[0] // Simplification: use first head
+	case ListOpTypeDelete:
+		idx, endPos, err := ctx.findByCurPos(op.Pos)
+		if err != nil {
+			return -1, fmt.Errorf("applyOp: delete LV %d: %w", lv, err)
+		}
+		// Skip forward to the next item which is visible in the current version.
+		for idx < len(ctx.Items) && ctx.Items[idx].CurState != Inserted {
+			if ctx.Items[idx].EndState == Inserted {
+				endPos++
+			}
+			idx++
+		}
+		if idx >= len(ctx.Items) {
+			return -1, fmt.Errorf("applyOp: delete LV %d: %w: position %d is past the end of the document", lv, ErrInvalidOp, op.Pos)
+		}
+		item := &ctx.Items[idx]
+		if elem := ctx.element(item.OpID); ctx.moved[elem] != nil {
+			return ctx.deleteMoved(lv, elem)
+		}
+		changedAt := -1
+		if item.EndState == Inserted {
+			changedAt = endPos
+		}
+		item.CurState = Deleted
+		item.EndState = Deleted
+		ctx.DelTargets[lv] = item.OpID
+		return changedAt, nil
+	}
+	return -1, fmt.Errorf("applyOp: LV %d: %w: unknown op type %q", lv, ErrInvalidOp, op.Type)
+}
 
-	if currentTip == targetLV {
-		w.Ctx.CurVersion = []causalgraph.LV{targetLV} // Ensure it's set correctly even if no ops change
-		return nil
+// newItem returns the item created by the insert or move at lv, which goes
+// at idx in the current version.
+func (ctx *EditContext) newItem(lv causalgraph.LV, idx int) Item {
+	originLeft := causalgraph.LV(-1)
+	if idx > 0 {
+		originLeft = ctx.Items[idx-1].OpID
 	}
 
-	err = causalgraph.IterVersionsBetween(&w.Log.CG, []causalgraph.LV{targetLV}, currentTip,
-		func(v causalgraph.LV, isParentOfPrev bool, isMerge bool) (stop bool, err error) {
-			if errRetreat := w.retreatOp(v); errRetreat != nil {
-				return true, fmt.Errorf("retreat: failed to retreat op LV %d: %w", v, errRetreat)
+	// The right parent is the next item which is not in the NotYetInserted
+	// state, provided it was inserted with the same origin.
+	rightParent := causalgraph.LV(-1)
+	for i := idx; i < len(ctx.Items); i++ {
+		next := &ctx.Items[i]
+		if next.CurState != NotYetInserted {
+			if next.OriginLeft == originLeft {
+				rightParent = next.OpID
 			}
-			return false, nil
-		})
-	if err != nil {
-		return fmt.Errorf("retreat: error during iteration: %w", err)
+			break
+		}
+	}
+
+	return Item{
+		OpID:        lv,
+		CurState:    Inserted,
+		EndState:    Inserted,
+		OriginLeft:  originLeft,
+		RightParent: rightParent,
 	}
-	w.Ctx.CurVersion = []causalgraph.LV{targetLV}
-	return nil
 }
 
-// merge updates the EditContext to reflect the state at targetVersion.
-func (w *Walker[T]) merge(targetVersion []causalgraph.LV) error {
-	if len(targetVersion) == 0 {
-		if len(w.Ctx.CurVersion) > 0 {
-			*w.Ctx = *newEditCtx()
-			w.Ctx.CurVersion = []causalgraph.LV{}
+// integrate places newItem among the concurrently inserted items following
+// idx, using the YjsMod / FugueMax ordering rules.
+func integrate(ctx *EditContext, cg *causalgraph.CausalGraph, newItem Item, idx, endPos int) (int, error) {
+	scanIdx := idx
+	scanEndPos := endPos
+
+	left := scanIdx - 1
+	right := len(ctx.Items)
+	if newItem.RightParent != -1 {
+		var err error
+		if right, err = ctx.findItemIdx(newItem.RightParent); err != nil {
+			return -1, fmt.Errorf("integrate: %w", err)
 		}
-		return nil
 	}
 
-	allVersions := make([]causalgraph.LV, 0, len(w.Ctx.CurVersion)+len(targetVersion))
-	allVersions = append(allVersions, w.Ctx.CurVersion...)
-	allVersions = append(allVersions, targetVersion...)
+	scanning := false
+	for scanIdx < right {
+		other := &ctx.Items[scanIdx]
+		if other.CurState != NotYetInserted {
+			break
+		}
 
-	commonAncestors, err := causalgraph.FindDominators(&w.Log.CG, allVersions)
-	if err != nil {
-		return fmt.Errorf("merge: failed to find common ancestors: %w", err)
-	}
-
-	if len(w.Ctx.CurVersion) > 0 {
-		if len(commonAncestors) == 0 {
-			*w.Ctx = *newEditCtx()
-			w.Ctx.CurVersion = []causalgraph.LV{}
-		} else {
-			// Simplified retreat to the first common ancestor.
-			if errRetreat := w.retreat(commonAncestors[0]); errRetreat != nil {
-				fmt.Printf("Warning: merge retreat failed: %v. Resetting context and advancing from root to common ancestors.\n", errRetreat)
-				*w.Ctx = *newEditCtx()
-				// Advance from root to each common ancestor
-				for _, caLV := range commonAncestors { // Use a different variable name here
-					// Need to advance one by one if commonAncestors itself is a frontier.
-					// This simplified advance assumes advancing to a single point, then another.
-					// A proper merge would advance along multiple paths if commonAncestors is a frontier.
-					// For now, let's assume advance can handle building up to a frontier.
-					// This part needs careful thought for frontier-to-frontier state changes.
-					// The current advance sets CurVersion to a single LV.
-					// So, we effectively advance to commonAncestors[0], then commonAncestors[1], etc.
-					// This is not a true "advance to frontier" but rather serial advance.
-					if errAdvance := w.advance(caLV); errAdvance != nil { // This will set CurVersion to [caLV]
-						return fmt.Errorf("merge: fallback advance to common ancestor %d failed: %w", caLV, errAdvance)
-					}
-				}
-				w.Ctx.CurVersion = commonAncestors // After all advances, set CurVersion to the full frontier
-			} else {
-				w.Ctx.CurVersion = commonAncestors
+		oleft := -1
+		if other.OriginLeft != -1 {
+			var err error
+			if oleft, err = ctx.findItemIdx(other.OriginLeft); err != nil {
+				return -1, fmt.Errorf("integrate: %w", err)
 			}
 		}
-	} else {
-		w.Ctx.CurVersion = commonAncestors
+		oright := len(ctx.Items)
+		if other.RightParent != -1 {
+			var err error
+			if oright, err = ctx.findItemIdx(other.RightParent); err != nil {
+				return -1, fmt.Errorf("integrate: %w", err)
+			}
+		}
+
+		if oleft < left || (oleft == left && oright == right && lvCmp(cg, newItem.OpID, other.OpID) < 0) {
+			break
+		}
+		if oleft == left {
+			scanning = oright < right
+		}
+
+		if other.EndState == Inserted {
+			scanEndPos++
+		}
+		scanIdx++
+
+		if !scanning {
+			idx = scanIdx
+			endPos = scanEndPos
+		}
+	}
+
+	ctx.insertItem(idx, newItem)
+	return endPos, nil
+}
+
+// applyToRope returns content updated for op, which changed the merged
+// document at endPos as reported by applyOp.
+func applyToRope[T any](content Rope[T], op ListOp[T], endPos int) Rope[T] {
+	if endPos < 0 {
+		return content
+	}
+	if op.Type == ListOpTypeInsert {
+		return content.Insert(endPos, op.Content)
 	}
+	return content.Delete(endPos)
+}
 
-	for _, tv := range targetVersion {
-		isCovered, err := causalgraph.VersionContainsLV(&w.Log.CG, w.Ctx.CurVersion, tv)
+// apply integrates the logged operation at lv into the walker's context.
+func (w *Walker[T]) apply(lv causalgraph.LV) error {
+	if int(lv) >= len(w.Log.Ops) {
+		return fmt.Errorf("apply: LV %d is out of bounds for op log of length %d", lv, len(w.Log.Ops))
+	}
+	op := w.Log.Ops[lv]
+	if op.Type == ListOpTypeMove {
+		from, to, err := integrateMove(w.Ctx, &w.Log.CG, lv, op)
 		if err != nil {
-			return fmt.Errorf("merge: error checking coverage for target head %d: %w", tv, err)
+			return err
 		}
-		if isCovered {
-			continue
+		if len(w.observers) > 0 && from >= 0 {
+			// Observers see a move as a delete followed by an insert.
+			w.changes = append(w.changes,
+				ListOp[T]{Type: ListOpTypeDelete, Pos: from},
+				ListOp[T]{Type: ListOpTypeInsert, Pos: to, Content: w.content.At(from)})
 		}
+		w.content = moveInRope(w.content, from, to)
+		return nil
+	}
+	endPos, err := integrateOp(w.Ctx, &w.Log.CG, lv, op)
+	if err != nil {
+		return err
+	}
+	w.content = applyToRope(w.content, op, endPos)
+	if len(w.observers) > 0 && endPos >= 0 {
+		w.changes = append(w.changes, ListOp[T]{Type: op.Type, Pos: endPos, Content: op.Content})
+	}
+	return nil
+}
+
+// opIter calls fn with every operation in [start, end), in order.
+type opIter[T any] func(start, end causalgraph.LV, fn func(lv causalgraph.LV, op ListOp[T]) error) error
 
-		opsToApply := []causalgraph.LV{}
-		iterErr := causalgraph.IterVersionsBetween(&w.Log.CG, w.Ctx.CurVersion, tv,
-			func(v causalgraph.LV, isParentOfPrev bool, isMerge bool) (stop bool, err error) {
-				opsToApply = append(opsToApply, v)
-				return false, nil
-			})
-		if iterErr != nil {
-			return fmt.Errorf("merge: error iterating from %v to target head %d: %w", w.Ctx.CurVersion, tv, iterErr)
+// eachOp is the opIter for the operations stored in a ListOpLog.
+func (l *ListOpLog[T]) eachOp(start, end causalgraph.LV, fn func(lv causalgraph.LV, op ListOp[T]) error) error {
+	if int(end) > len(l.Ops) {
+		return fmt.Errorf("LV %d is out of bounds for op log of length %d", end-1, len(l.Ops))
+	}
+	for lv := start; lv < end; lv++ {
+		if err := fn(lv, l.Ops[lv]); err != nil {
+			return err
 		}
-		for i := len(opsToApply) - 1; i >= 0; i-- {
-			opToApplyLV := opsToApply[i]
-			if errApply := w.applyOp(opToApplyLV); errApply != nil {
-				return fmt.Errorf("merge: failed to apply op LV %d: %w", opToApplyLV, errApply)
+	}
+	return nil
+}
+
+// replay builds a fresh EditContext containing every operation in the history
+// of version, with its current version set to version. If content is not nil,
+// it is updated to hold the document at version.
+func replay[T any](cg *causalgraph.CausalGraph, version []causalgraph.LV, ops opIter[T], content *Rope[T]) (*EditContext, error) {
+	_, history, err := causalgraph.DiffVersions(cg, nil, version)
+	if err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+	ctx := newEditCtx()
+	for _, r := range history {
+		err := ops(r.Start, r.End, func(lv causalgraph.LV, op ListOp[T]) error {
+			if op.Type == ListOpTypeMove {
+				from, to, err := integrateMove(ctx, cg, lv, op)
+				if err != nil {
+					return err
+				}
+				if content != nil {
+					*content = moveInRope(*content, from, to)
+				}
+				return nil
+			}
+			endPos, err := integrateOp(ctx, cg, lv, op)
+			if err != nil {
+				return err
 			}
+			if content != nil {
+				*content = applyToRope(*content, op, endPos)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("replay: %w", err)
 		}
-		// After applying ops towards tv, the context's current version effectively includes tv.
-		// We need to manage w.Ctx.CurVersion carefully if it's a frontier.
-		// A simple approach: after processing one tv, update CurVersion to include it,
-		// then subsequent tv's are diffed against this new CurVersion.
-		// This is complex. A more robust way is to collect all diffs from commonAncestors to each targetVersion head,
-		// then apply the union of these diffs.
-		// For now, the loop implies serial application towards each target head.
-		// The final w.Ctx.CurVersion = targetVersion will set it correctly.
 	}
-	w.Ctx.CurVersion = targetVersion
-	return nil
+	if err := ctx.moveTo(cg, version); err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+	return ctx, nil
+}
+
+// replay builds a fresh EditContext for version from the walker's log.
+func (w *Walker[T]) replay(version []causalgraph.LV) (*EditContext, error) {
+	return replay(&w.Log.CG, version, w.Log.eachOp, nil)
 }
 
 // Checkout computes and returns the document snapshot at a given targetVersion.
 func (w *Walker[T]) Checkout(targetVersion []causalgraph.LV) (*Branch[T], error) {
-	// Operate on a temporary walker with a fresh context to avoid mutating the main walker's state.
-	tempWalker := &Walker[T]{
-		Log: w.Log,        // Share the log (read-only for this operation)
-		Ctx: newEditCtx(), // Start with a fresh context
-	}
-
-	err := tempWalker.merge(targetVersion)
+	// Replay into a fresh context to avoid mutating the main walker's state.
+	ctx, err := w.replay(targetVersion)
 	if err != nil {
-		return nil, fmt.Errorf("checkout: failed to merge to targetVersion %v on temp context: %w", targetVersion, err)
+		return nil, fmt.Errorf("checkout: failed to replay to targetVersion %v: %w", targetVersion, err)
 	}
 
 	snapshot := make([]T, 0)
-	for _, item := range tempWalker.Ctx.Items {
+	for _, item := range ctx.Items {
 		if item.CurState == Inserted {
-			opIndex := int(item.OpID)
-			if opIndex < len(tempWalker.Log.Ops) && tempWalker.Log.Ops[opIndex].Type == ListOpTypeInsert {
-				snapshot = append(snapshot, tempWalker.Log.Ops[opIndex].Content)
-			} else {
-				return nil, fmt.Errorf("checkout: inconsistent item state for OpID %d in temp context (op log len %d)", item.OpID, len(tempWalker.Log.Ops))
-			}
+			snapshot = append(snapshot, w.Log.Ops[ctx.element(item.OpID)].Content)
 		}
 	}
 
 	return &Branch[T]{
 		Snapshot: snapshot,
-		Version:  targetVersion,
+		Version:  append([]causalgraph.LV{}, targetVersion...),
 	}, nil
 }
 
@@ -426,21 +607,29 @@ func (w *Walker[T]) GetCG() *causalgraph.CausalGraph {
 	return &w.Log.CG
 }
 
-// GetActiveItems returns the current snapshot of content based on Ctx.Items.
-// This reflects the state at w.Ctx.CurVersion.
+// Len returns the number of elements in the current document.
+func (w *Walker[T]) Len() int {
+	return w.content.Len()
+}
+
+// GetActiveItems returns a copy of the current document content.
+// This reflects the state at w.Ctx.CurVersion, which is kept at the graph heads.
 func (w *Walker[T]) GetActiveItems() []T {
-	snapshot := make([]T, 0)
-	for _, item := range w.Ctx.Items {
-		if item.CurState == Inserted {
-			opIndex := int(item.OpID)
-			if opIndex < len(w.Log.Ops) && w.Log.Ops[opIndex].Type == ListOpTypeInsert {
-				snapshot = append(snapshot, w.Log.Ops[opIndex].Content)
-			} else {
-				fmt.Printf("Warning: GetActiveItems found an item with OpID %d marked Inserted but Op is missing or not an Insert (op log len %d)\n", item.OpID, len(w.Log.Ops))
-			}
-		}
+	return w.content.Slice()
+}
+
+// View returns an immutable snapshot of the current document. It takes
+// constant time and shares structure with the walker's live content.
+func (w *Walker[T]) View() View[T] {
+	return View[T]{Content: w.content, Version: w.GetVersion()}
+}
+
+// Branch copies the view's content into a Branch.
+func (v View[T]) Branch() *Branch[T] {
+	return &Branch[T]{
+		Snapshot: v.Content.Slice(),
+		Version:  append([]causalgraph.LV{}, v.Version...),
 	}
-	return snapshot
 }
 
 // TODO: Port utility functions opToPretty, opToCompact
@@ -1,6 +1,7 @@
 package egwalker
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 
@@ -148,10 +149,161 @@ func TestWalker_LocalDelete_Simple(t *testing.T) {
 	}
 }
 
+// syncWalkers delivers every span known to src to dst.
+func syncWalkers[T any](t *testing.T, dst, src *Walker[T]) {
+	t.Helper()
+	spans, err := src.Spans()
+	if err != nil {
+		t.Fatalf("Spans failed: %v", err)
+	}
+	for _, span := range spans {
+		if err := dst.ApplyRemote(span); err != nil {
+			t.Fatalf("ApplyRemote(%+v) failed: %v", span.ID, err)
+		}
+	}
+}
+
+func TestWalker_ConcurrentInserts_Converge(t *testing.T) {
+	a := NewWalker[string]()
+	b := NewWalker[string]()
+
+	for i, s := range []string{"a", "b", "c"} {
+		if _, err := a.LocalInsert("alice", i, s); err != nil {
+			t.Fatalf("alice insert failed: %v", err)
+		}
+	}
+	syncWalkers(t, b, a)
+
+	// Both peers insert at the same position concurrently.
+	if _, err := a.LocalInsert("alice", 1, "X"); err != nil {
+		t.Fatalf("alice insert failed: %v", err)
+	}
+	if _, err := b.LocalInsert("bob", 1, "Y"); err != nil {
+		t.Fatalf("bob insert failed: %v", err)
+	}
+	if _, err := b.LocalDelete("bob", 3); err != nil { // deletes "c"
+		t.Fatalf("bob delete failed: %v", err)
+	}
+
+	syncWalkers(t, a, b)
+	syncWalkers(t, b, a)
+
+	want := []string{"a", "X", "Y", "b"}
+	if got := a.GetActiveItems(); !reflect.DeepEqual(got, want) {
+		t.Errorf("alice: got %v, want %v", got, want)
+	}
+	if got := b.GetActiveItems(); !reflect.DeepEqual(got, want) {
+		t.Errorf("bob: got %v, want %v", got, want)
+	}
+	compareLVSlices(t, a.GetVersion(), a.GetCG().Heads)
+}
+
+func TestWalker_ApplyRemote_Duplicate(t *testing.T) {
+	a := NewWalker[string]()
+	a.LocalInsert("alice", 0, "x")
+	a.LocalInsert("alice", 1, "y")
+
+	b := NewWalker[string]()
+	syncWalkers(t, b, a)
+	syncWalkers(t, b, a) // Delivering the same spans again is a no-op.
+
+	if got := len(b.GetOps()); got != 2 {
+		t.Errorf("expected 2 ops after duplicate delivery, got %d", got)
+	}
+	if got, want := b.GetActiveItems(), []string{"x", "y"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestWalker_ApplyRemote_InvalidPos(t *testing.T) {
+	w := NewWalker[string]()
+	w.LocalInsert("alice", 0, "a")
+	w.LocalInsert("alice", 1, "b")
+	heads := append([]causalgraph.LV{}, w.GetCG().Heads...)
+
+	// The insert is valid; the delete is past the end of the document.
+	span := RemoteSpan[string]{
+		ID:      causalgraph.RawVersion{Agent: "mallory", Seq: 0},
+		Parents: []causalgraph.RawVersion{{Agent: "alice", Seq: 1}},
+		Ops: []ListOp[string]{
+			{Type: ListOpTypeInsert, Pos: 2, Content: "c"},
+			{Type: ListOpTypeDelete, Pos: 5},
+		},
+	}
+	if err := w.ApplyRemote(span); !errors.Is(err, ErrInvalidOp) {
+		t.Fatalf("expected ErrInvalidOp, got %v", err)
+	}
+	compareLVSlices(t, w.GetCG().Heads, heads)
+	if got := len(w.GetOps()); got != 2 {
+		t.Errorf("expected the rejected ops to be dropped, got %d ops", got)
+	}
+	if got, want := w.GetActiveItems(), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := w.Checkout(w.GetCG().Heads); err != nil {
+		t.Errorf("Checkout failed after a rejected span: %v", err)
+	}
+
+	// The span's sequence numbers are still free.
+	span.Ops = span.Ops[:1]
+	if err := w.ApplyRemote(span); err != nil {
+		t.Fatalf("ApplyRemote failed: %v", err)
+	}
+	if got, want := w.GetActiveItems(), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestWalker_Checkout(t *testing.T) {
+	w := NewWalker[string]()
+	w.LocalInsert("alice", 0, "a")
+	lvB, _ := w.LocalInsert("alice", 1, "b")
+	w.LocalDelete("alice", 0)
+	w.LocalInsert("alice", 1, "c")
+
+	tests := []struct {
+		name    string
+		version []causalgraph.LV
+		want    []string
+	}{
+		{"Root", []causalgraph.LV{}, []string{}},
+		{"AfterSecondInsert", []causalgraph.LV{lvB}, []string{"a", "b"}},
+		{"Heads", w.GetVersion(), []string{"b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			branch, err := w.Checkout(tt.version)
+			if err != nil {
+				t.Fatalf("Checkout failed: %v", err)
+			}
+			if !reflect.DeepEqual(branch.Snapshot, tt.want) {
+				t.Errorf("got %v, want %v", branch.Snapshot, tt.want)
+			}
+			compareLVSlices(t, branch.Version, tt.version)
+		})
+	}
+
+	if _, err := w.Checkout([]causalgraph.LV{100}); err == nil {
+		t.Error("expected error for checkout of unknown version")
+	}
+	// Checkout must not disturb the walker's own state.
+	if got, want := w.GetActiveItems(), []string{"b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("walker state changed by checkout: got %v, want %v", got, want)
+	}
+}
+
+func TestWalker_LocalOps_OutOfBounds(t *testing.T) {
+	w := NewWalker[string]()
+	if _, err := w.LocalInsert("alice", 1, "x"); err == nil {
+		t.Error("expected error inserting past the end of an empty document")
+	}
+	if _, err := w.LocalDelete("alice", 0); err == nil {
+		t.Error("expected error deleting from an empty document")
+	}
+	if len(w.GetOps()) != 0 || w.GetCG().NextLV != 0 {
+		t.Errorf("rejected ops must not be logged: %d ops, NextLV %d", len(w.GetOps()), w.GetCG().NextLV)
+	}
+}
+
 // TODO: Add more tests:
-// - Integrate remote operations (rawParents != nil)
-// - More complex sequences of local inserts and deletes
-// - Merge scenarios (once merge and applyOp are more complete)
-// - Checkout scenarios
-// - Advance/Retreat
 // - Edge cases for all functions
@@ -0,0 +1,38 @@
+package egwalker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by this package wrap ErrInvalidOp, ErrPosOutOfRange or one
+// of the errors of the causalgraph package, such as
+// causalgraph.ErrUnknownParent for a span received before its parents, so
+// callers can tell the cases apart with errors.Is and errors.As.
+
+// ErrInvalidOp is wrapped by errors for operations which are malformed, or
+// which do not make sense in their history, such as a remote operation on an
+// object its span has not seen. Peers never generate them, so receiving one
+// means the sender is broken or hostile.
+var ErrInvalidOp = errors.New("invalid operation")
+
+// ErrPosOutOfRange is returned when a position passed to a local edit is
+// outside the document, list or text it applies to, whose length is Len.
+type ErrPosOutOfRange struct {
+	Pos int
+	Len int
+}
+
+func (e *ErrPosOutOfRange) Error() string {
+	return fmt.Sprintf("position %d is out of bounds for length %d", e.Pos, e.Len)
+}
+
+// outOfRange returns the position to report in an ErrPosOutOfRange for the
+// range of length elements at pos: pos if it is negative, and otherwise the
+// end of the range.
+func outOfRange(pos, length int) int {
+	if pos < 0 {
+		return pos
+	}
+	return pos + length
+}
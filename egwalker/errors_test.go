@@ -0,0 +1,75 @@
+package egwalker
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+func TestErrors_ApplyRemote(t *testing.T) {
+	a := newList(t, "alice", "a", "b")
+	a.LocalInsert("bob", 2, "c")
+	spans, err := a.Spans()
+	if err != nil {
+		t.Fatalf("Spans failed: %v", err)
+	}
+
+	// Bob's span arrives before the alice span it depends on.
+	b := NewWalker[string]()
+	err = b.ApplyRemote(spans[len(spans)-1])
+	var unknown *causalgraph.ErrUnknownParent
+	if !errors.As(err, &unknown) || unknown.Raw != (causalgraph.RawVersion{Agent: "alice", Seq: 1}) {
+		t.Fatalf("expected ErrUnknownParent for alice:1, got %v", err)
+	}
+	if !errors.Is(err, causalgraph.ErrNotFound) {
+		t.Errorf("expected %v to match causalgraph.ErrNotFound", err)
+	}
+
+	// A later span by the same agent leaves a gap.
+	err = b.ApplyRemote(RemoteSpan[string]{
+		ID:      causalgraph.RawVersion{Agent: "carol", Seq: 3},
+		Parents: []causalgraph.RawVersion{},
+		Ops:     []ListOp[string]{{Type: ListOpTypeInsert, Content: "x"}},
+	})
+	var gap *causalgraph.ErrSeqGap
+	if !errors.As(err, &gap) || gap.Agent != "carol" || gap.Expected != 0 || gap.Got != 3 {
+		t.Errorf("expected ErrSeqGap for carol, got %v", err)
+	}
+
+	d := NewTextDoc()
+	err = d.ApplyRemote(TextSpan{
+		ID:      causalgraph.RawVersion{Agent: "mallory", Seq: 0},
+		Parents: []causalgraph.RawVersion{},
+		Ops:     []TextOp{{Type: ListOpTypeInsert, Len: 5, Content: "hi"}},
+	})
+	if !errors.Is(err, ErrInvalidOp) {
+		t.Errorf("expected ErrInvalidOp for a malformed run, got %v", err)
+	}
+}
+
+func TestErrors_Local(t *testing.T) {
+	w := newList(t, "alice", "a", "b")
+	_, err := w.LocalInsert("alice", 3, "c")
+	var outOfRange *ErrPosOutOfRange
+	if !errors.As(err, &outOfRange) || outOfRange.Pos != 3 || outOfRange.Len != 2 {
+		t.Errorf("LocalInsert: expected ErrPosOutOfRange{3, 2}, got %v", err)
+	}
+	if want := "insert: position 3 is out of bounds for length 2"; err == nil || !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("LocalInsert: got %v, want it to end with %q", err, want)
+	}
+
+	d := NewTextDoc()
+	d.Insert("alice", 0, "hello")
+	_, err = d.Delete("alice", 3, 4)
+	if !errors.As(err, &outOfRange) || outOfRange.Pos != 7 || outOfRange.Len != 5 {
+		t.Errorf("Delete: expected ErrPosOutOfRange{7, 5}, got %v", err)
+	}
+
+	_, err = w.Checkout([]causalgraph.LV{5})
+	var lvOutOfRange *causalgraph.ErrLVOutOfRange
+	if !errors.As(err, &lvOutOfRange) || lvOutOfRange.LV != 5 {
+		t.Errorf("Checkout: expected ErrLVOutOfRange for LV 5, got %v", err)
+	}
+}
@@ -0,0 +1,734 @@
+package egwalker
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// JSONKind is the kind of a value written to a JSONDoc.
+type JSONKind int
+
+const (
+	// JSONKindScalar is a value stored as is, such as a number or a string.
+	JSONKindScalar JSONKind = iota
+	// JSONKindMap is a map from string keys to values.
+	JSONKindMap
+	// JSONKindList is a list of values.
+	JSONKindList
+	// JSONKindText is a string whose characters can be edited concurrently.
+	JSONKindText
+)
+
+// JSONText is a value which creates a text field when written to a JSONDoc.
+// Unlike a string, which is replaced as a whole, the text can then be edited
+// with InsertText and DeleteText.
+type JSONText string
+
+// JSONOpType is the type of a JSONOp.
+type JSONOpType string
+
+const (
+	// JSONOpSet sets a key of a map.
+	JSONOpSet JSONOpType = "set"
+	// JSONOpInsert inserts a value into a list or a character into a text.
+	JSONOpInsert JSONOpType = "ins"
+	// JSONOpDelete deletes a key of a map, or an element of a list or text.
+	JSONOpDelete JSONOpType = "del"
+)
+
+// JSONOp is a single operation on a JSONDoc. Each map, list and text is
+// identified by the operation which created it, so concurrent edits to the
+// same object find it whatever happened to the path leading to it.
+type JSONOp struct {
+	// Container is the ID of the operation which created the map, list or
+	// text the operation changes, or nil for the root map.
+	Container *causalgraph.RawVersion
+	Type      JSONOpType
+	Key       string // Map key, for maps.
+	Pos       int    // Position, for lists and texts.
+	// Kind is the kind of value set or inserted. A value of any kind but
+	// JSONKindScalar is a new, empty object identified by this operation.
+	Kind JSONKind
+	// Value is the scalar set or inserted, or the rune inserted into a text.
+	Value any
+}
+
+// JSONSpan is a run of consecutive JSON operations by a single agent, in the
+// form exchanged between peers. See RemoteSpan.
+type JSONSpan struct {
+	ID      causalgraph.RawVersion
+	Parents []causalgraph.RawVersion
+	Ops     []JSONOp
+}
+
+// jsonRoot is the LV standing for the root map of a JSONDoc.
+const jsonRoot causalgraph.LV = -1
+
+// jsonObject is a map, list or text in a JSONDoc.
+type jsonObject struct {
+	kind JSONKind
+	// keys lists, for a map, the LVs of the writes to each key in increasing
+	// order, and heads those which no other write to the key has seen.
+	keys, heads map[string][]causalgraph.LV
+	// lvs lists, for a list or text, the LVs of its operations in increasing
+	// order. ctx and content are its merge state and current elements, as the
+	// LVs of the operations which inserted them.
+	lvs     []causalgraph.LV
+	ctx     *EditContext
+	content Rope[causalgraph.LV]
+}
+
+func newJSONObject(kind JSONKind) *jsonObject {
+	if kind == JSONKindMap {
+		return &jsonObject{kind: kind, keys: make(map[string][]causalgraph.LV), heads: make(map[string][]causalgraph.LV)}
+	}
+	return &jsonObject{kind: kind, ctx: newEditCtx()}
+}
+
+// JSONDoc is a collaborative document holding a tree of maps, lists and
+// texts, all versioned by one causal graph.
+//
+// Values are addressed by paths of map keys (strings) and list indexes
+// (ints) from the root map. Maps resolve concurrent writes to a key as
+// LWWMap does; lists and texts are merged by the same engine as Walker. A
+// write which replaces an object also hides the edits made to it
+// concurrently.
+type JSONDoc struct {
+	log     *sharedLog[JSONOp]
+	objects map[causalgraph.LV]*jsonObject
+}
+
+// NewJSONDoc creates a document holding an empty root map.
+func NewJSONDoc() *JSONDoc {
+	return &JSONDoc{
+		log:     newSharedLog[JSONOp](causalgraph.CreateCG()),
+		objects: map[causalgraph.LV]*jsonObject{jsonRoot: newJSONObject(JSONKindMap)},
+	}
+}
+
+// Set sets the map key at the end of path to value on behalf of agent. A
+// map[string]any, []any or JSONText value is written as a new map, list or
+// text with the same contents; any other value is stored as is. It returns
+// the LVs of the operations made.
+func (d *JSONDoc) Set(agent string, path []any, value any) (causalgraph.LVRange, error) {
+	start := d.log.cg.NextLV
+	parent, key, err := d.resolveParent(path)
+	if err != nil {
+		return causalgraph.LVRange{}, fmt.Errorf("set: %w", err)
+	}
+	k, ok := key.(string)
+	if d.objects[parent].kind != JSONKindMap || !ok {
+		return causalgraph.LVRange{}, fmt.Errorf("set: %v is not a map key", path)
+	}
+	if err := d.write(agent, parent, JSONOp{Type: JSONOpSet, Key: k}, value); err != nil {
+		return causalgraph.LVRange{}, fmt.Errorf("set: %w", err)
+	}
+	return causalgraph.LVRange{Start: start, End: d.log.cg.NextLV}, nil
+}
+
+// Insert inserts value into the list at the index at the end of path on
+// behalf of agent, converting it as Set does.
+func (d *JSONDoc) Insert(agent string, path []any, value any) (causalgraph.LVRange, error) {
+	start := d.log.cg.NextLV
+	parent, key, err := d.resolveParent(path)
+	if err != nil {
+		return causalgraph.LVRange{}, fmt.Errorf("insert: %w", err)
+	}
+	obj := d.objects[parent]
+	pos, ok := key.(int)
+	if obj.kind != JSONKindList || !ok {
+		return causalgraph.LVRange{}, fmt.Errorf("insert: %v is not a list index", path)
+	}
+	if pos < 0 || pos > obj.content.Len() {
+		return causalgraph.LVRange{}, fmt.Errorf("insert: %w", &ErrPosOutOfRange{Pos: pos, Len: obj.content.Len()})
+	}
+	if err := d.write(agent, parent, JSONOp{Type: JSONOpInsert, Pos: pos}, value); err != nil {
+		return causalgraph.LVRange{}, fmt.Errorf("insert: %w", err)
+	}
+	return causalgraph.LVRange{Start: start, End: d.log.cg.NextLV}, nil
+}
+
+// Delete deletes the map key or list element at the end of path on behalf
+// of agent.
+func (d *JSONDoc) Delete(agent string, path []any) (causalgraph.LV, error) {
+	parent, key, err := d.resolveParent(path)
+	if err != nil {
+		return -1, fmt.Errorf("delete: %w", err)
+	}
+	obj := d.objects[parent]
+	op := JSONOp{Type: JSONOpDelete}
+	switch k := key.(type) {
+	case string:
+		if obj.kind != JSONKindMap {
+			return -1, fmt.Errorf("delete: %v is not a map key", path)
+		}
+		op.Key = k
+	case int:
+		if obj.kind != JSONKindList {
+			return -1, fmt.Errorf("delete: %v is not a list index", path)
+		}
+		if k < 0 || k >= obj.content.Len() {
+			return -1, fmt.Errorf("delete: %w", &ErrPosOutOfRange{Pos: k, Len: obj.content.Len()})
+		}
+		op.Pos = k
+	default:
+		return -1, fmt.Errorf("delete: path element %v is neither a string nor an int", key)
+	}
+	r, err := d.local(agent, parent, op)
+	if err != nil {
+		return -1, fmt.Errorf("delete: %w", err)
+	}
+	return r.Start, nil
+}
+
+// InsertText inserts text at pos in the text at path on behalf of agent.
+// Positions count Unicode code points.
+func (d *JSONDoc) InsertText(agent string, path []any, pos int, text string) (causalgraph.LVRange, error) {
+	start := d.log.cg.NextLV
+	target, err := d.resolveText(path)
+	if err != nil {
+		return causalgraph.LVRange{}, fmt.Errorf("insertText: %w", err)
+	}
+	if n := d.objects[target].content.Len(); pos < 0 || pos > n {
+		return causalgraph.LVRange{}, fmt.Errorf("insertText: %w", &ErrPosOutOfRange{Pos: pos, Len: n})
+	}
+	if _, err := d.local(agent, target, textInserts(pos, text)...); err != nil {
+		return causalgraph.LVRange{}, fmt.Errorf("insertText: %w", err)
+	}
+	return causalgraph.LVRange{Start: start, End: d.log.cg.NextLV}, nil
+}
+
+// DeleteText deletes length characters starting at pos from the text at
+// path on behalf of agent.
+func (d *JSONDoc) DeleteText(agent string, path []any, pos, length int) (causalgraph.LVRange, error) {
+	start := d.log.cg.NextLV
+	target, err := d.resolveText(path)
+	if err != nil {
+		return causalgraph.LVRange{}, fmt.Errorf("deleteText: %w", err)
+	}
+	if n := d.objects[target].content.Len(); pos < 0 || length < 0 || pos+length > n {
+		return causalgraph.LVRange{}, fmt.Errorf("deleteText: range %d+%d: %w", pos, length, &ErrPosOutOfRange{Pos: outOfRange(pos, length), Len: n})
+	}
+	ops := make([]JSONOp, length)
+	for i := range ops {
+		ops[i] = JSONOp{Type: JSONOpDelete, Pos: pos}
+	}
+	if _, err := d.local(agent, target, ops...); err != nil {
+		return causalgraph.LVRange{}, fmt.Errorf("deleteText: %w", err)
+	}
+	return causalgraph.LVRange{Start: start, End: d.log.cg.NextLV}, nil
+}
+
+// textInserts returns the operations inserting text at pos in a text.
+func textInserts(pos int, text string) []JSONOp {
+	var ops []JSONOp
+	for _, c := range text {
+		ops = append(ops, JSONOp{Type: JSONOpInsert, Pos: pos, Value: c})
+		pos++
+	}
+	return ops
+}
+
+// write logs op, which sets or inserts value into parent, followed by the
+// operations filling the object value creates, if any.
+func (d *JSONDoc) write(agent string, parent causalgraph.LV, op JSONOp, value any) error {
+	switch value.(type) {
+	case map[string]any:
+		op.Kind = JSONKindMap
+	case []any:
+		op.Kind = JSONKindList
+	case JSONText:
+		op.Kind = JSONKindText
+	default:
+		op.Value = value
+	}
+	r, err := d.local(agent, parent, op)
+	if err != nil {
+		return err
+	}
+	lv := r.Start
+	switch v := value.(type) {
+	case map[string]any:
+		// Sorted so the same value always makes the same operations.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			if err := d.write(agent, lv, JSONOp{Type: JSONOpSet, Key: k}, v[k]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for i, elem := range v {
+			if err := d.write(agent, lv, JSONOp{Type: JSONOpInsert, Pos: i}, elem); err != nil {
+				return err
+			}
+		}
+	case JSONText:
+		if _, err := d.local(agent, lv, textInserts(0, string(v))...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// local logs ops on the object created by target as one span with the
+// graph heads as parents, and applies them. An empty ops logs nothing.
+func (d *JSONDoc) local(agent string, target causalgraph.LV, ops ...JSONOp) (causalgraph.LVRange, error) {
+	if len(ops) == 0 {
+		return causalgraph.LVRange{Start: d.log.cg.NextLV, End: d.log.cg.NextLV}, nil
+	}
+	if target != jsonRoot {
+		raw, _ := causalgraph.LVToRaw(d.log.cg, target)
+		for i := range ops {
+			ops[i].Container = &raw
+		}
+	}
+	r, err := d.log.localSpan(agent, nil, ops)
+	if err != nil {
+		return causalgraph.LVRange{}, err
+	}
+	for lv := r.Start; lv < r.End; lv++ {
+		// The span has seen every operation before it.
+		if err := d.integrate(lv, true); err != nil {
+			return causalgraph.LVRange{}, err
+		}
+	}
+	return r, nil
+}
+
+// apply adds the logged operation lv, received from another peer, to the
+// document.
+func (d *JSONDoc) apply(lv causalgraph.LV) error {
+	return d.integrate(lv, false)
+}
+
+// integrate adds the logged operation lv to the object it changes, and
+// creates the object it writes, if any. seenAll is set if lv has seen every
+// operation integrated before it, so it supersedes earlier writes to its
+// key without comparing their histories.
+func (d *JSONDoc) integrate(lv causalgraph.LV, seenAll bool) error {
+	op := d.log.ops[lv]
+	target, err := d.containerLV(op.Container)
+	if err != nil {
+		return err
+	}
+	obj := d.objects[target]
+	if obj.kind == JSONKindMap {
+		obj.keys[op.Key] = append(obj.keys[op.Key], lv)
+		heads := []causalgraph.LV{lv}
+		if !seenAll && len(obj.heads[op.Key]) > 0 {
+			if heads, err = causalgraph.FindMaximal(d.log.cg, append(obj.heads[op.Key], lv)); err != nil {
+				return err
+			}
+		}
+		obj.heads[op.Key] = heads
+	} else {
+		obj.lvs = append(obj.lvs, lv)
+		listOp := jsonListOp(lv, op)
+		endPos, err := integrateOp(obj.ctx, d.log.cg, lv, listOp)
+		if err != nil {
+			return err
+		}
+		obj.content = applyToRope(obj.content, listOp, endPos)
+	}
+	if op.Type != JSONOpDelete && op.Kind != JSONKindScalar {
+		d.objects[lv] = newJSONObject(op.Kind)
+	}
+	return nil
+}
+
+// jsonListOp returns the list operation of op, an operation on a list or
+// text, with the LV of the insert as its content.
+func jsonListOp(lv causalgraph.LV, op JSONOp) ListOp[causalgraph.LV] {
+	if op.Type == JSONOpDelete {
+		return ListOp[causalgraph.LV]{Type: ListOpTypeDelete, Pos: op.Pos}
+	}
+	return ListOp[causalgraph.LV]{Type: ListOpTypeInsert, Pos: op.Pos, Content: lv}
+}
+
+// containerLV returns the LV of the object identified by id.
+func (d *JSONDoc) containerLV(id *causalgraph.RawVersion) (causalgraph.LV, error) {
+	if id == nil {
+		return jsonRoot, nil
+	}
+	lv, err := causalgraph.RawToLV(d.log.cg, id.Agent, id.Seq)
+	if err != nil {
+		return -1, err
+	}
+	if _, ok := d.objects[lv]; !ok {
+		return -1, fmt.Errorf("%s:%d did not create an object", id.Agent, id.Seq)
+	}
+	return lv, nil
+}
+
+// ApplyRemote integrates a span of operations received from another peer.
+// Operations the document already knows are skipped.
+func (d *JSONDoc) ApplyRemote(span JSONSpan) error {
+	if err := d.checkRemote(span); err != nil {
+		return fmt.Errorf("applyRemote: %w", err)
+	}
+	if err := d.log.integrateRemote(span.ID, span.Parents, span.Ops, d.apply, d.rebuild); err != nil {
+		return fmt.Errorf("applyRemote: %w", err)
+	}
+	return nil
+}
+
+// rebuild recomputes the objects of the document from its log.
+func (d *JSONDoc) rebuild() error {
+	d.objects = map[causalgraph.LV]*jsonObject{jsonRoot: newJSONObject(JSONKindMap)}
+	for _, lv := range d.log.lvs() {
+		if err := d.apply(lv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRemote checks that every operation of span changes an object of the
+// right kind which the operation has seen, at a position which is not
+// negative.
+func (d *JSONDoc) checkRemote(span JSONSpan) error {
+	parents := make([]causalgraph.LV, 0, len(span.Parents))
+	for _, p := range span.Parents {
+		lv, err := causalgraph.RawToLV(d.log.cg, p.Agent, p.Seq)
+		if err != nil {
+			return err
+		}
+		parents = append(parents, lv)
+	}
+	for i, op := range span.Ops {
+		var kind JSONKind
+		switch {
+		case op.Container == nil:
+			kind = JSONKindMap
+		case op.Container.Agent == span.ID.Agent && op.Container.Seq >= span.ID.Seq && op.Container.Seq < span.ID.Seq+i:
+			// Created earlier in the span.
+			created := span.Ops[op.Container.Seq-span.ID.Seq]
+			if created.Type == JSONOpDelete || created.Kind == JSONKindScalar {
+				return fmt.Errorf("op %d: %w: %s:%d did not create an object", i, ErrInvalidOp, op.Container.Agent, op.Container.Seq)
+			}
+			kind = created.Kind
+		default:
+			lv, err := d.containerLV(op.Container)
+			if err != nil {
+				return fmt.Errorf("op %d: %w", i, err)
+			}
+			seen, err := causalgraph.VersionContainsLV(d.log.cg, parents, lv)
+			if err != nil {
+				return fmt.Errorf("op %d: %w", i, err)
+			}
+			if !seen {
+				return fmt.Errorf("op %d: %w: object %s:%d is not in the span's history", i, ErrInvalidOp, op.Container.Agent, op.Container.Seq)
+			}
+			kind = d.objects[lv].kind
+		}
+		switch {
+		case op.Kind < JSONKindScalar || op.Kind > JSONKindText:
+			return fmt.Errorf("op %d: %w: unknown kind %s", i, ErrInvalidOp, op.Kind)
+		case kind == JSONKindMap && op.Type == JSONOpInsert,
+			kind != JSONKindMap && op.Type == JSONOpSet,
+			kind == JSONKindText && (op.Kind != JSONKindScalar || op.Type == JSONOpInsert && !isRune(op.Value)):
+			return fmt.Errorf("op %d: %w: invalid %q operation on a %s", i, ErrInvalidOp, op.Type, kind)
+		case op.Type != JSONOpSet && op.Type != JSONOpInsert && op.Type != JSONOpDelete:
+			return fmt.Errorf("op %d: %w: unknown operation type %q", i, ErrInvalidOp, op.Type)
+		case kind != JSONKindMap && op.Pos < 0:
+			// Positions past the end are caught when the op is applied.
+			return fmt.Errorf("op %d: %w: position %d is negative", i, ErrInvalidOp, op.Pos)
+		}
+	}
+	return nil
+}
+
+func isRune(v any) bool {
+	_, ok := v.(rune)
+	return ok
+}
+
+func (k JSONKind) String() string {
+	switch k {
+	case JSONKindScalar:
+		return "scalar"
+	case JSONKindMap:
+		return "map"
+	case JSONKindList:
+		return "list"
+	case JSONKindText:
+		return "text"
+	}
+	return fmt.Sprintf("JSONKind(%d)", int(k))
+}
+
+// Spans returns the document's whole history as spans which can be passed
+// to ApplyRemote on another JSONDoc.
+func (d *JSONDoc) Spans() ([]JSONSpan, error) {
+	var spans []JSONSpan
+	err := d.log.eachSpan(func(id causalgraph.RawVersion, parents []causalgraph.RawVersion, ops []JSONOp) {
+		spans = append(spans, JSONSpan{ID: id, Parents: parents, Ops: ops})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("spans: %w", err)
+	}
+	return spans, nil
+}
+
+// resolveParent returns the object holding the value at path, along with
+// the last element of path.
+func (d *JSONDoc) resolveParent(path []any) (causalgraph.LV, any, error) {
+	if len(path) == 0 {
+		return -1, nil, fmt.Errorf("empty path")
+	}
+	parent, err := d.resolve(path[:len(path)-1])
+	if err != nil {
+		return -1, nil, err
+	}
+	return parent, path[len(path)-1], nil
+}
+
+// resolveText returns the text object at path.
+func (d *JSONDoc) resolveText(path []any) (causalgraph.LV, error) {
+	target, err := d.resolve(path)
+	if err != nil {
+		return -1, err
+	}
+	if d.objects[target].kind != JSONKindText {
+		return -1, fmt.Errorf("%v is a %s, not a text", path, d.objects[target].kind)
+	}
+	return target, nil
+}
+
+// resolve returns the object at path in the current document.
+func (d *JSONDoc) resolve(path []any) (causalgraph.LV, error) {
+	v, err := d.view(d.log.cg.Heads)
+	if err != nil {
+		return -1, err
+	}
+	target := jsonRoot
+	for i, key := range path {
+		lv, err := v.child(target, key)
+		if err != nil {
+			return -1, fmt.Errorf("%v: %w", path[:i+1], err)
+		}
+		if _, ok := d.objects[lv]; !ok {
+			return -1, fmt.Errorf("%v is not a map, list or text", path[:i+1])
+		}
+		target = lv
+	}
+	return target, nil
+}
+
+// Get returns the current value at path, converted as by Checkout.
+func (d *JSONDoc) Get(path []any) (any, error) {
+	v, err := d.view(d.log.cg.Heads)
+	if err != nil {
+		return nil, fmt.Errorf("get: %w", err)
+	}
+	var value any
+	if len(path) == 0 {
+		value, err = v.object(jsonRoot)
+	} else {
+		var parent causalgraph.LV
+		if parent, err = d.resolve(path[:len(path)-1]); err == nil {
+			var lv causalgraph.LV
+			if lv, err = v.child(parent, path[len(path)-1]); err == nil {
+				value, err = v.value(lv)
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get: %w", err)
+	}
+	return value, nil
+}
+
+// Checkout returns the document at version as a tree of map[string]any,
+// []any and scalar values, with each text as a string.
+func (d *JSONDoc) Checkout(version []causalgraph.LV) (map[string]any, error) {
+	v, err := d.view(version)
+	if err != nil {
+		return nil, fmt.Errorf("checkout: %w", err)
+	}
+	root, err := v.object(jsonRoot)
+	if err != nil {
+		return nil, fmt.Errorf("checkout: %w", err)
+	}
+	return root.(map[string]any), nil
+}
+
+// Snapshot returns the current document. See Checkout.
+func (d *JSONDoc) Snapshot() (map[string]any, error) {
+	return d.Checkout(d.log.cg.Heads)
+}
+
+// Version returns the current version of the document.
+func (d *JSONDoc) Version() []causalgraph.LV {
+	return append([]causalgraph.LV{}, d.log.cg.Heads...)
+}
+
+// GetCG returns the document's causal graph.
+func (d *JSONDoc) GetCG() *causalgraph.CausalGraph {
+	return d.log.cg
+}
+
+// jsonView reads a JSONDoc at a version.
+type jsonView struct {
+	d       *JSONDoc
+	history []causalgraph.LVRange // Only computed if live is not set.
+	version []causalgraph.LV
+	// live is set if version is the current version, so objects can be read
+	// from the state kept up to date as operations are applied rather than
+	// from the history.
+	live bool
+	// elems caches the elements of the lists and texts read so far.
+	elems map[causalgraph.LV][]causalgraph.LV
+}
+
+func (d *JSONDoc) view(version []causalgraph.LV) (*jsonView, error) {
+	v := &jsonView{
+		d:       d,
+		version: version,
+		live:    frontiersEqual(version, d.log.cg.Heads),
+		elems:   make(map[causalgraph.LV][]causalgraph.LV),
+	}
+	if !v.live {
+		var err error
+		if v.history, err = d.log.history(version); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// child returns the LV of the operation which wrote the value at key in the
+// object target.
+func (v *jsonView) child(target causalgraph.LV, key any) (causalgraph.LV, error) {
+	obj := v.d.objects[target]
+	switch k := key.(type) {
+	case string:
+		if obj.kind != JSONKindMap {
+			return -1, fmt.Errorf("key %q in a %s", k, obj.kind)
+		}
+		lv, err := v.winner(obj, k)
+		if err != nil {
+			return -1, err
+		}
+		if lv < 0 {
+			return -1, fmt.Errorf("no key %q", k)
+		}
+		return lv, nil
+	case int:
+		if obj.kind != JSONKindList {
+			return -1, fmt.Errorf("index %d in a %s", k, obj.kind)
+		}
+		elems, err := v.elements(target)
+		if err != nil {
+			return -1, err
+		}
+		if k < 0 || k >= len(elems) {
+			return -1, fmt.Errorf("index: %w", &ErrPosOutOfRange{Pos: k, Len: len(elems)})
+		}
+		return elems[k], nil
+	}
+	return -1, fmt.Errorf("path element %v is neither a string nor an int", key)
+}
+
+// winner returns the LV of the write which sets key of the map obj, or -1
+// if the key is unset.
+func (v *jsonView) winner(obj *jsonObject, key string) (causalgraph.LV, error) {
+	latest := obj.heads[key]
+	if !v.live {
+		var err error
+		if latest, err = v.d.log.latest(obj.keys[key], v.history); err != nil {
+			return -1, err
+		}
+	}
+	if len(latest) == 0 {
+		return -1, nil
+	}
+	lv := slices.MaxFunc(latest, func(a, b causalgraph.LV) int { return lvCmp(v.d.log.cg, a, b) })
+	if v.d.log.ops[lv].Type == JSONOpDelete {
+		return -1, nil
+	}
+	return lv, nil
+}
+
+// elements returns the LVs of the inserts of the elements of the list or
+// text target.
+func (v *jsonView) elements(target causalgraph.LV) ([]causalgraph.LV, error) {
+	if elems, ok := v.elems[target]; ok {
+		return elems, nil
+	}
+	obj := v.d.objects[target]
+	content := obj.content
+	if !v.live {
+		content = Rope[causalgraph.LV]{}
+		each := func(start, end causalgraph.LV, fn func(lv causalgraph.LV, op ListOp[causalgraph.LV]) error) error {
+			i := sort.Search(len(obj.lvs), func(i int) bool { return obj.lvs[i] >= start })
+			for ; i < len(obj.lvs) && obj.lvs[i] < end; i++ {
+				lv := obj.lvs[i]
+				if err := fn(lv, jsonListOp(lv, v.d.log.ops[lv])); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if _, err := replay(v.d.log.cg, v.version, each, &content); err != nil {
+			return nil, err
+		}
+	}
+	elems := content.Slice()
+	v.elems[target] = elems
+	return elems, nil
+}
+
+// value returns the value written by the operation lv.
+func (v *jsonView) value(lv causalgraph.LV) (any, error) {
+	if _, ok := v.d.objects[lv]; ok {
+		return v.object(lv)
+	}
+	return v.d.log.ops[lv].Value, nil
+}
+
+// object returns the contents of the object target.
+func (v *jsonView) object(target causalgraph.LV) (any, error) {
+	obj := v.d.objects[target]
+	if obj.kind == JSONKindMap {
+		result := make(map[string]any)
+		for key := range obj.keys {
+			lv, err := v.winner(obj, key)
+			if err != nil {
+				return nil, err
+			}
+			if lv < 0 {
+				continue
+			}
+			if result[key], err = v.value(lv); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+	elems, err := v.elements(target)
+	if err != nil {
+		return nil, err
+	}
+	if obj.kind == JSONKindText {
+		text := make([]rune, len(elems))
+		for i, lv := range elems {
+			text[i] = v.d.log.ops[lv].Value.(rune)
+		}
+		return string(text), nil
+	}
+	list := make([]any, len(elems))
+	for i, lv := range elems {
+		if list[i], err = v.value(lv); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,266 @@
+package egwalker
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+func syncJSONDocs(t *testing.T, dst, src *JSONDoc) {
+	t.Helper()
+	spans, err := src.Spans()
+	if err != nil {
+		t.Fatalf("Spans failed: %v", err)
+	}
+	for _, span := range spans {
+		if err := dst.ApplyRemote(span); err != nil {
+			t.Fatalf("ApplyRemote(%+v) failed: %v", span.ID, err)
+		}
+	}
+}
+
+func jsonSnapshot(t *testing.T, d *JSONDoc) map[string]any {
+	t.Helper()
+	snap, err := d.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	return snap
+}
+
+func TestJSONDoc_Nested(t *testing.T) {
+	d := NewJSONDoc()
+	if _, err := d.Set("alice", []any{"title"}, JSONText("Groceries")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	todos := []any{
+		map[string]any{"name": JSONText("milk"), "done": false},
+		map[string]any{"name": JSONText("eggs"), "done": true},
+	}
+	if _, err := d.Set("alice", []any{"todos"}, todos); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, err := d.InsertText("alice", []any{"todos", 0, "name"}, 0, "oat "); err != nil {
+		t.Fatalf("InsertText failed: %v", err)
+	}
+	if _, err := d.DeleteText("alice", []any{"title"}, 0, 3); err != nil {
+		t.Fatalf("DeleteText failed: %v", err)
+	}
+	if _, err := d.Insert("alice", []any{"todos", 2}, map[string]any{"name": JSONText("bread")}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if _, err := d.Delete("alice", []any{"todos", 1}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	want := map[string]any{
+		"title": "ceries",
+		"todos": []any{
+			map[string]any{"name": "oat milk", "done": false},
+			map[string]any{"name": "bread"},
+		},
+	}
+	if got := jsonSnapshot(t, d); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if got, err := d.Get([]any{"todos", 0, "name"}); err != nil || got != "oat milk" {
+		t.Errorf("Get = %v, %v", got, err)
+	}
+
+	// A copy built from the spans checks out the same at every version.
+	other := NewJSONDoc()
+	syncJSONDocs(t, other, d)
+	if got := jsonSnapshot(t, other); !reflect.DeepEqual(got, want) {
+		t.Errorf("copy: got %#v, want %#v", got, want)
+	}
+	got, err := other.Checkout([]causalgraph.LV{9})
+	if err != nil {
+		t.Fatalf("Checkout failed: %v", err)
+	}
+	if want := map[string]any{"title": "Groceries"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("checkout: got %#v, want %#v", got, want)
+	}
+}
+
+func TestJSONDoc_Concurrent(t *testing.T) {
+	a, b := NewJSONDoc(), NewJSONDoc()
+	a.Set("alice", []any{"todos"}, []any{map[string]any{"name": JSONText("milk")}})
+	syncJSONDocs(t, b, a)
+
+	// Alice edits the first todo while Bob adds another before it and sets
+	// a new key.
+	a.InsertText("alice", []any{"todos", 0, "name"}, 4, "!")
+	a.Set("alice", []any{"todos", 0, "done"}, true)
+	b.Insert("bob", []any{"todos", 0}, map[string]any{"name": JSONText("tea")})
+	b.Set("bob", []any{"owner"}, "bob")
+	syncJSONDocs(t, a, b)
+	syncJSONDocs(t, b, a)
+
+	want := map[string]any{
+		"owner": "bob",
+		"todos": []any{
+			map[string]any{"name": "tea"},
+			map[string]any{"name": "milk!", "done": true},
+		},
+	}
+	for _, d := range []*JSONDoc{a, b} {
+		if got := jsonSnapshot(t, d); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %#v, want %#v", got, want)
+		}
+	}
+
+	// Edits to an object deleted concurrently are lost with it.
+	a.InsertText("alice", []any{"todos", 0, "name"}, 0, "green ")
+	b.Delete("bob", []any{"todos", 0})
+	syncJSONDocs(t, a, b)
+	syncJSONDocs(t, b, a)
+	want["todos"] = want["todos"].([]any)[1:]
+	for _, d := range []*JSONDoc{a, b} {
+		if got := jsonSnapshot(t, d); !reflect.DeepEqual(got, want) {
+			t.Errorf("after delete: got %#v, want %#v", got, want)
+		}
+	}
+}
+
+func TestJSONDoc_Errors(t *testing.T) {
+	d := NewJSONDoc()
+	d.Set("alice", []any{"n"}, 1)
+	d.Set("alice", []any{"list"}, []any{})
+	d.Set("alice", []any{"text"}, JSONText("hi"))
+
+	if _, err := d.Set("alice", []any{"n", "x"}, 2); err == nil {
+		t.Error("expected an error for a path through a scalar")
+	}
+	if _, err := d.Set("alice", []any{"list", 0}, 2); err == nil {
+		t.Error("expected an error setting a list index")
+	}
+	if _, err := d.Insert("alice", []any{"list", 1}, 2); err == nil {
+		t.Error("expected an error inserting out of bounds")
+	}
+	if _, err := d.InsertText("alice", []any{"list"}, 0, "x"); err == nil {
+		t.Error("expected an error inserting text into a list")
+	}
+	if _, err := d.Get([]any{"missing"}); err == nil {
+		t.Error("expected an error getting a missing key")
+	}
+
+	textID, _ := causalgraph.LVToRaw(d.GetCG(), 2)
+	bad := JSONSpan{
+		ID:      causalgraph.RawVersion{Agent: "bob", Seq: 0},
+		Parents: []causalgraph.RawVersion{{Agent: "alice", Seq: 4}},
+		Ops:     []JSONOp{{Container: &textID, Type: JSONOpSet, Key: "k", Value: 1}},
+	}
+	if err := d.ApplyRemote(bad); err == nil {
+		t.Error("expected an error setting a key of a text")
+	}
+	unseen := JSONSpan{
+		ID:      causalgraph.RawVersion{Agent: "bob", Seq: 0},
+		Parents: []causalgraph.RawVersion{},
+		Ops:     []JSONOp{{Container: &textID, Type: JSONOpInsert, Value: 'x'}},
+	}
+	if err := d.ApplyRemote(unseen); err == nil {
+		t.Error("expected an error editing a text outside the span's history")
+	}
+	if n := causalgraph.NextSeqForAgent(d.GetCG(), "bob"); n != 0 {
+		t.Errorf("rejected spans were added to the graph (next seq %d)", n)
+	}
+}
+
+func TestJSONDoc_ApplyRemote_InvalidPos(t *testing.T) {
+	d := NewJSONDoc()
+	d.Set("alice", []any{"list"}, []any{"x"})
+	listID, _ := causalgraph.LVToRaw(d.GetCG(), 0)
+	heads := append([]causalgraph.LV{}, d.Version()...)
+	parents := []causalgraph.RawVersion{{Agent: "alice", Seq: 1}}
+
+	for _, pos := range []int{5, -1} {
+		span := JSONSpan{
+			ID:      causalgraph.RawVersion{Agent: "bob", Seq: 0},
+			Parents: parents,
+			Ops: []JSONOp{
+				{Container: &listID, Type: JSONOpInsert, Pos: 1, Value: "y"},
+				{Container: &listID, Type: JSONOpInsert, Pos: pos, Value: "z"},
+			},
+		}
+		if err := d.ApplyRemote(span); !errors.Is(err, ErrInvalidOp) {
+			t.Fatalf("Pos %d: expected ErrInvalidOp, got %v", pos, err)
+		}
+		compareLVSlices(t, d.Version(), heads)
+		if got, want := jsonSnapshot(t, d), map[string]any{"list": []any{"x"}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Pos %d: got %v, want %v", pos, got, want)
+		}
+	}
+
+	span := JSONSpan{
+		ID:      causalgraph.RawVersion{Agent: "bob", Seq: 0},
+		Parents: parents,
+		Ops:     []JSONOp{{Container: &listID, Type: JSONOpInsert, Pos: 1, Value: "y"}},
+	}
+	if err := d.ApplyRemote(span); err != nil {
+		t.Fatalf("ApplyRemote failed: %v", err)
+	}
+	if got, want := jsonSnapshot(t, d), map[string]any{"list": []any{"x", "y"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestJSONDoc_ConcurrentSet(t *testing.T) {
+	a, b := NewJSONDoc(), NewJSONDoc()
+	a.Set("alice", []any{"k"}, "a1")
+	syncJSONDocs(t, b, a)
+
+	// Concurrent writes to a key: the greatest agent wins on both peers,
+	// and a later write supersedes both.
+	a.Set("alice", []any{"k"}, "a2")
+	b.Set("bob", []any{"k"}, "b1")
+	syncJSONDocs(t, a, b)
+	syncJSONDocs(t, b, a)
+	merged := a.Version()
+	for _, d := range []*JSONDoc{a, b} {
+		if got, err := d.Get([]any{"k"}); err != nil || got != "b1" {
+			t.Errorf("after merge: got %v (%v), want b1", got, err)
+		}
+	}
+	a.Set("alice", []any{"k"}, "a3")
+	syncJSONDocs(t, b, a)
+	for _, d := range []*JSONDoc{a, b} {
+		if got, err := d.Get([]any{"k"}); err != nil || got != "a3" {
+			t.Errorf("after a later write: got %v (%v), want a3", got, err)
+		}
+	}
+
+	// Older versions are read from the history.
+	old, err := a.Checkout(merged)
+	if err != nil {
+		t.Fatalf("Checkout failed: %v", err)
+	}
+	if old["k"] != "b1" {
+		t.Errorf("Checkout(%v): got %v, want b1", merged, old["k"])
+	}
+}
+
+func TestJSONDoc_TextSpans(t *testing.T) {
+	d := NewJSONDoc()
+	if _, err := d.Set("alice", []any{"t"}, JSONText("hi")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	entries := len(d.GetCG().Entries)
+	r, err := d.InsertText("alice", []any{"t"}, 2, " there")
+	if err != nil {
+		t.Fatalf("InsertText failed: %v", err)
+	}
+	if n := r.End - r.Start; n != 6 {
+		t.Errorf("InsertText returned %d LVs, want 6", n)
+	}
+	if _, err := d.DeleteText("alice", []any{"t"}, 0, 3); err != nil {
+		t.Fatalf("DeleteText failed: %v", err)
+	}
+	if n := len(d.GetCG().Entries) - entries; n != 2 {
+		t.Errorf("InsertText and DeleteText added %d graph entries, want one each", n)
+	}
+	if got, err := d.Get([]any{"t"}); err != nil || got != "there" {
+		t.Errorf("got %q (%v), want %q", got, err, "there")
+	}
+}
@@ -0,0 +1,200 @@
+package egwalker
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// MapOp sets a key of an LWWMap to Value, or deletes it if Delete is set.
+type MapOp[V any] struct {
+	Key    string
+	Value  V
+	Delete bool
+}
+
+// MapSpan is a run of consecutive map operations by a single agent, in the
+// form exchanged between peers. See RemoteSpan.
+type MapSpan[V any] struct {
+	ID      causalgraph.RawVersion
+	Parents []causalgraph.RawVersion
+	Ops     []MapOp[V]
+}
+
+// LWWMap is a last-writer-wins map whose operations are versioned by a causal
+// graph, which may be shared with other data such as a TextDoc's, so map and
+// list edits are ordered against each other.
+//
+// A key's value at a version is set by the writes to it in the version's
+// history which no other write to it has seen. If there are several, they
+// were made concurrently, and the one by the greatest agent ID (then sequence
+// number) wins, so every peer resolves the conflict the same way.
+//
+// When the graph is shared, spans from all the data using it must be applied
+// on other peers in the order of their LVs on the sending peer, so each
+// span's parents are known when it arrives.
+type LWWMap[V any] struct {
+	log *sharedLog[MapOp[V]]
+	// keys lists the LVs of the writes to each key, in increasing order, and
+	// heads those which no other write to the key has seen.
+	keys, heads map[string][]causalgraph.LV
+}
+
+// NewLWWMap creates an empty map versioned by cg. Pass the graph of another
+// document, such as TextDoc.GetCG(), to share it.
+func NewLWWMap[V any](cg *causalgraph.CausalGraph) *LWWMap[V] {
+	return &LWWMap[V]{
+		log:   newSharedLog[MapOp[V]](cg),
+		keys:  make(map[string][]causalgraph.LV),
+		heads: make(map[string][]causalgraph.LV),
+	}
+}
+
+// Set sets key to value on behalf of agent.
+func (m *LWWMap[V]) Set(agent, key string, value V) (causalgraph.LV, error) {
+	lv, err := m.local(agent, MapOp[V]{Key: key, Value: value})
+	if err != nil {
+		return -1, fmt.Errorf("set: %w", err)
+	}
+	return lv, nil
+}
+
+// Delete deletes key on behalf of agent.
+func (m *LWWMap[V]) Delete(agent, key string) (causalgraph.LV, error) {
+	lv, err := m.local(agent, MapOp[V]{Key: key, Delete: true})
+	if err != nil {
+		return -1, fmt.Errorf("delete: %w", err)
+	}
+	return lv, nil
+}
+
+// local logs op with the graph heads as parents.
+func (m *LWWMap[V]) local(agent string, op MapOp[V]) (causalgraph.LV, error) {
+	lv, err := m.log.local(agent, nil, op)
+	if err != nil {
+		return -1, err
+	}
+	// The write has seen every operation before it.
+	if err := m.integrate(lv, true); err != nil {
+		return -1, err
+	}
+	return lv, nil
+}
+
+// ApplyRemote integrates a span of map operations received from another peer.
+// Operations the graph already knows are skipped.
+func (m *LWWMap[V]) ApplyRemote(span MapSpan[V]) error {
+	if err := m.log.integrateRemote(span.ID, span.Parents, span.Ops, m.apply, m.rebuild); err != nil {
+		return fmt.Errorf("applyRemote: %w", err)
+	}
+	return nil
+}
+
+// apply adds the logged write lv, received from another peer, to its key.
+func (m *LWWMap[V]) apply(lv causalgraph.LV) error {
+	return m.integrate(lv, false)
+}
+
+// integrate adds the logged write lv to its key. seenAll is set if lv has
+// seen every write integrated before it, so it supersedes earlier writes to
+// its key without comparing their histories.
+func (m *LWWMap[V]) integrate(lv causalgraph.LV, seenAll bool) error {
+	key := m.log.ops[lv].Key
+	m.keys[key] = append(m.keys[key], lv)
+	heads := []causalgraph.LV{lv}
+	if !seenAll && len(m.heads[key]) > 0 {
+		var err error
+		if heads, err = causalgraph.FindMaximal(m.log.cg, append(slices.Clone(m.heads[key]), lv)); err != nil {
+			return err
+		}
+	}
+	m.heads[key] = heads
+	return nil
+}
+
+// rebuild recomputes the writes to each key from the log.
+func (m *LWWMap[V]) rebuild() error {
+	m.keys = make(map[string][]causalgraph.LV)
+	m.heads = make(map[string][]causalgraph.LV)
+	for _, lv := range m.log.lvs() {
+		if err := m.apply(lv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Spans returns the map's whole history as spans which can be passed to
+// ApplyRemote on another LWWMap. Entries of the graph belonging to other
+// data are left out.
+func (m *LWWMap[V]) Spans() ([]MapSpan[V], error) {
+	var spans []MapSpan[V]
+	err := m.log.eachSpan(func(id causalgraph.RawVersion, parents []causalgraph.RawVersion, ops []MapOp[V]) {
+		spans = append(spans, MapSpan[V]{ID: id, Parents: parents, Ops: ops})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("spans: %w", err)
+	}
+	return spans, nil
+}
+
+// winner returns the LV of the write to key which determines its value in
+// the version with the given history, or -1 if no write is in it. A nil
+// history stands for the current version.
+func (m *LWWMap[V]) winner(key string, history []causalgraph.LVRange) (causalgraph.LV, error) {
+	latest := m.heads[key]
+	if history != nil {
+		var err error
+		if latest, err = m.log.latest(m.keys[key], history); err != nil {
+			return -1, err
+		}
+	}
+	if len(latest) == 0 {
+		return -1, nil
+	}
+	return slices.MaxFunc(latest, func(a, b causalgraph.LV) int { return lvCmp(m.log.cg, a, b) }), nil
+}
+
+// Get returns the current value of key, and whether it is set.
+func (m *LWWMap[V]) Get(key string) (V, bool, error) {
+	var zero V
+	lv, err := m.winner(key, nil)
+	if err != nil {
+		return zero, false, fmt.Errorf("get: %w", err)
+	}
+	if lv < 0 || m.log.ops[lv].Delete {
+		return zero, false, nil
+	}
+	return m.log.ops[lv].Value, true, nil
+}
+
+// Checkout returns the contents of the map at version.
+func (m *LWWMap[V]) Checkout(version []causalgraph.LV) (map[string]V, error) {
+	var history []causalgraph.LVRange
+	if !slices.Equal(version, m.log.cg.Heads) {
+		var err error
+		if history, err = m.log.history(version); err != nil {
+			return nil, fmt.Errorf("checkout: %w", err)
+		}
+		if history == nil {
+			history = []causalgraph.LVRange{} // The empty version.
+		}
+	}
+	result := make(map[string]V)
+	for key := range m.keys {
+		lv, err := m.winner(key, history)
+		if err != nil {
+			return nil, fmt.Errorf("checkout: %w", err)
+		}
+		if lv >= 0 && !m.log.ops[lv].Delete {
+			result[key] = m.log.ops[lv].Value
+		}
+	}
+	return result, nil
+}
+
+// Snapshot returns the current contents of the map.
+func (m *LWWMap[V]) Snapshot() (map[string]V, error) {
+	return m.Checkout(m.log.cg.Heads)
+}
@@ -0,0 +1,158 @@
+package egwalker
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// docWithMeta is a text document with a metadata map sharing its causal graph.
+type docWithMeta struct {
+	text *TextDoc
+	meta *LWWMap[string]
+}
+
+func newDocWithMeta() *docWithMeta {
+	text := NewTextDoc()
+	return &docWithMeta{text: text, meta: NewLWWMap[string](text.GetCG())}
+}
+
+// syncDocWithMeta delivers src's text and map spans to dst in the order of
+// their LVs in src, so every span's parents arrive first.
+func syncDocWithMeta(t *testing.T, dst, src *docWithMeta) {
+	t.Helper()
+	type pending struct {
+		lv    causalgraph.LV
+		apply func() error
+	}
+	var all []pending
+	textSpans, err := src.text.Spans()
+	if err != nil {
+		t.Fatalf("Spans failed: %v", err)
+	}
+	for _, span := range textSpans {
+		lv, _ := causalgraph.RawToLV(src.text.GetCG(), span.ID.Agent, span.ID.Seq)
+		all = append(all, pending{lv, func() error { return dst.text.ApplyRemote(span) }})
+	}
+	mapSpans, err := src.meta.Spans()
+	if err != nil {
+		t.Fatalf("Spans failed: %v", err)
+	}
+	for _, span := range mapSpans {
+		lv, _ := causalgraph.RawToLV(src.text.GetCG(), span.ID.Agent, span.ID.Seq)
+		all = append(all, pending{lv, func() error { return dst.meta.ApplyRemote(span) }})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].lv < all[j].lv })
+	for _, p := range all {
+		if err := p.apply(); err != nil {
+			t.Fatalf("ApplyRemote failed: %v", err)
+		}
+	}
+}
+
+func TestLWWMap_SharedGraph(t *testing.T) {
+	a := newDocWithMeta()
+	if _, err := a.text.Insert("alice", 0, "Draft"); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if _, err := a.meta.Set("alice", "title", "Notes"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	a.meta.Set("alice", "owner", "alice")
+	base := a.text.GetCG().Heads
+	if _, err := a.text.Insert("alice", 5, " one"); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	b := newDocWithMeta()
+	syncDocWithMeta(t, b, a)
+	if got := b.text.String(); got != "Draft one" {
+		t.Errorf("text: got %q", got)
+	}
+
+	// Concurrent writes to the same key resolve by agent; a later write wins
+	// over an earlier one regardless of agent.
+	a.meta.Set("alice", "title", "Alice's title")
+	b.meta.Set("bob", "title", "Bob's title")
+	b.meta.Delete("bob", "owner")
+	syncDocWithMeta(t, a, b)
+	syncDocWithMeta(t, b, a)
+
+	want := map[string]string{"title": "Bob's title"}
+	for name, d := range map[string]*docWithMeta{"alice": a, "bob": b} {
+		got, err := d.meta.Snapshot()
+		if err != nil {
+			t.Fatalf("%s: Snapshot failed: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+	}
+	a.meta.Set("alice", "title", "Final")
+	if got, ok, err := a.meta.Get("title"); err != nil || !ok || got != "Final" {
+		t.Errorf("after causal overwrite: got %q, %t, %v", got, ok, err)
+	}
+	if _, ok, err := a.meta.Get("owner"); err != nil || ok {
+		t.Errorf("expected owner to be deleted, got %t, %v", ok, err)
+	}
+
+	// Checkout at an older frontier of the shared graph.
+	got, err := a.meta.Checkout(base)
+	if err != nil {
+		t.Fatalf("Checkout failed: %v", err)
+	}
+	if want := map[string]string{"title": "Notes", "owner": "alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("checkout: got %v, want %v", got, want)
+	}
+	if text, _ := a.text.Checkout(base); text != "Draft" {
+		t.Errorf("text checkout: got %q", text)
+	}
+	if _, err := a.meta.Checkout([]causalgraph.LV{1000}); err == nil {
+		t.Error("expected error for unknown version")
+	}
+}
+
+func TestLWWMap_HeadsMatchHistory(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	peers := []*docWithMeta{newDocWithMeta(), newDocWithMeta(), newDocWithMeta()}
+	keys := []string{"a", "b", "c"}
+	for i := 0; i < 200; i++ {
+		p := rng.IntN(len(peers))
+		agent := fmt.Sprintf("agent%d", p)
+		key := keys[rng.IntN(len(keys))]
+		switch rng.IntN(4) {
+		case 0:
+			peers[p].meta.Delete(agent, key)
+		case 1:
+			syncDocWithMeta(t, peers[p], peers[rng.IntN(len(peers))])
+		default:
+			peers[p].meta.Set(agent, key, fmt.Sprint(i))
+		}
+	}
+
+	// The heads kept for each key give the same winners as the history walk
+	// used for older versions.
+	for n, d := range peers {
+		history, err := d.meta.log.history(d.meta.log.cg.Heads)
+		if err != nil {
+			t.Fatalf("history failed: %v", err)
+		}
+		for _, key := range keys {
+			got, err := d.meta.winner(key, nil)
+			if err != nil {
+				t.Fatalf("winner failed: %v", err)
+			}
+			want, err := d.meta.winner(key, history)
+			if err != nil {
+				t.Fatalf("winner failed: %v", err)
+			}
+			if got != want {
+				t.Errorf("peer %d, key %q: heads give LV %d, history gives %d", n, key, got, want)
+			}
+		}
+	}
+}
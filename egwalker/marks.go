@@ -0,0 +1,551 @@
+package egwalker
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// MarkAnchor is a boundary of a mark. Following Peritext, it sits in the gap
+// just before or just after an item rather than at an index, so which side of
+// the boundary concurrently inserted content lands on is well defined.
+type MarkAnchor struct {
+	// Item is the insert operation of the item the boundary is attached to.
+	// It is ignored for edge anchors.
+	Item causalgraph.RawVersion
+	// After places the boundary in the gap after Item rather than before it.
+	After bool
+	// Edge makes the anchor a document boundary instead: the start of the
+	// document if After is set, otherwise the end.
+	Edge bool
+}
+
+// MarkOp is a formatting operation over a range of items.
+type MarkOp struct {
+	Start, End MarkAnchor
+	// Type names the mark, such as "bold" or "link". Marks of different types
+	// overlap freely; of marks of the same type covering an item, the one
+	// made last in causal order wins.
+	Type  string
+	Value string
+	// Remove makes the operation clear marks of Type over the range.
+	Remove bool
+}
+
+// RichOp is an operation in a RichDoc: a list operation, or a mark if Mark
+// is set.
+type RichOp[T any] struct {
+	ListOp[T]
+	Mark *MarkOp
+}
+
+// RichSpan is a run of consecutive operations by a single agent, in the form
+// exchanged between peers. See RemoteSpan.
+type RichSpan[T any] struct {
+	ID      causalgraph.RawVersion
+	Parents []causalgraph.RawVersion
+	Ops     []RichOp[T]
+}
+
+// MarkExpand says whether content typed at the edges of a marked range joins it.
+type MarkExpand int
+
+const (
+	// ExpandNone keeps the range fixed, as for links and comments.
+	ExpandNone MarkExpand = iota
+	// ExpandAfter grows the range with content typed at its end, as for bold
+	// and italic.
+	ExpandAfter
+	// ExpandBefore grows the range with content typed at its start.
+	ExpandBefore
+	// ExpandBoth grows the range at both ends.
+	ExpandBoth
+)
+
+// MarkRun is a maximal run of elements with the same set of marks.
+type MarkRun struct {
+	Start, End int
+	// Marks maps each mark type on the run to its value.
+	Marks map[string]string
+}
+
+// RichBranch is a checked-out snapshot of a RichDoc.
+type RichBranch[T any] struct {
+	Snapshot []T
+	// Runs lists the formatted parts of Snapshot in order. Elements without
+	// marks are not covered by any run.
+	Runs    []MarkRun
+	Version []causalgraph.LV
+}
+
+// RichDoc is a collaborative list with Peritext-style formatting marks.
+// List and mark operations share one causal graph, so marks are ordered and
+// exchanged with the edits around them. The list itself is merged by the same
+// engine as Walker, which only sees the list operations.
+type RichDoc[T any] struct {
+	CG causalgraph.CausalGraph
+	// ops holds every operation; the LV of each is its index.
+	ops []RichOp[T]
+	Ctx *EditContext
+	// content is the current list, updated incrementally as ops are applied.
+	content Rope[T]
+}
+
+// NewRichDoc creates a new, empty RichDoc.
+func NewRichDoc[T any]() *RichDoc[T] {
+	return &RichDoc[T]{CG: *causalgraph.CreateCG(), Ctx: newEditCtx()}
+}
+
+// eachListOp is the opIter for the list operations of d, skipping marks.
+func (d *RichDoc[T]) eachListOp(start, end causalgraph.LV, fn func(lv causalgraph.LV, op ListOp[T]) error) error {
+	if int(end) > len(d.ops) {
+		return fmt.Errorf("LV %d is out of bounds for op log of length %d", end-1, len(d.ops))
+	}
+	for lv := start; lv < end; lv++ {
+		if d.ops[lv].Mark != nil {
+			continue
+		}
+		if err := fn(lv, d.ops[lv].ListOp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LocalInsert inserts content at pos on behalf of agent.
+func (d *RichDoc[T]) LocalInsert(agent string, pos int, content T) (causalgraph.LV, error) {
+	if pos < 0 || pos > d.Len() {
+		return -1, fmt.Errorf("localInsert: %w", &ErrPosOutOfRange{Pos: pos, Len: d.Len()})
+	}
+	return d.local(agent, RichOp[T]{ListOp: ListOp[T]{Type: ListOpTypeInsert, Pos: pos, Content: content}})
+}
+
+// LocalDelete deletes the element at pos on behalf of agent.
+func (d *RichDoc[T]) LocalDelete(agent string, pos int) (causalgraph.LV, error) {
+	if pos < 0 || pos >= d.Len() {
+		return -1, fmt.Errorf("localDelete: %w", &ErrPosOutOfRange{Pos: pos, Len: d.Len()})
+	}
+	return d.local(agent, RichOp[T]{ListOp: ListOp[T]{Type: ListOpTypeDelete, Pos: pos}})
+}
+
+// AddMark marks the elements in [start, end) with a mark of type typ on behalf
+// of agent. expand says whether content later typed at the edges of the
+// range is marked too.
+func (d *RichDoc[T]) AddMark(agent string, start, end int, typ, value string, expand MarkExpand) (causalgraph.LV, error) {
+	op, err := d.markOp(start, end, typ, expand)
+	if err != nil {
+		return -1, fmt.Errorf("addMark: %w", err)
+	}
+	op.Value = value
+	return d.local(agent, RichOp[T]{Mark: op})
+}
+
+// RemoveMark clears marks of type typ from the elements in [start, end) on
+// behalf of agent. expand says whether content later typed at the edges of
+// the range is cleared too.
+func (d *RichDoc[T]) RemoveMark(agent string, start, end int, typ string, expand MarkExpand) (causalgraph.LV, error) {
+	op, err := d.markOp(start, end, typ, expand)
+	if err != nil {
+		return -1, fmt.Errorf("removeMark: %w", err)
+	}
+	op.Remove = true
+	return d.local(agent, RichOp[T]{Mark: op})
+}
+
+// markOp builds a mark over [start, end) in the current document, choosing
+// anchors which give the requested expansion: a boundary before the first
+// element does not grow when content is typed in front of it, while one after
+// the preceding element does, and likewise at the end.
+func (d *RichDoc[T]) markOp(start, end int, typ string, expand MarkExpand) (*MarkOp, error) {
+	if start < 0 || start >= end || end > d.Len() {
+		return nil, fmt.Errorf("range [%d, %d) is invalid for document of length %d", start, end, d.Len())
+	}
+	visible := make([]causalgraph.LV, 0, d.Len())
+	for _, item := range d.Ctx.Items {
+		if item.CurState == Inserted {
+			visible = append(visible, item.OpID)
+		}
+	}
+	anchor := func(lv causalgraph.LV, after bool) (MarkAnchor, error) {
+		raw, ok := causalgraph.LVToRaw(&d.CG, lv)
+		if !ok {
+			return MarkAnchor{}, &causalgraph.ErrLVOutOfRange{LV: lv, NextLV: d.CG.NextLV}
+		}
+		return MarkAnchor{Item: raw, After: after}, nil
+	}
+
+	op := &MarkOp{Type: typ}
+	var err error
+	switch {
+	case expand != ExpandBefore && expand != ExpandBoth:
+		op.Start, err = anchor(visible[start], false)
+	case start == 0:
+		op.Start = MarkAnchor{Edge: true, After: true}
+	default:
+		op.Start, err = anchor(visible[start-1], true)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case expand != ExpandAfter && expand != ExpandBoth:
+		op.End, err = anchor(visible[end-1], true)
+	case end == len(visible):
+		op.End = MarkAnchor{Edge: true}
+	default:
+		op.End, err = anchor(visible[end], false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// local adds a validated local operation to the log and applies it.
+func (d *RichDoc[T]) local(agent string, op RichOp[T]) (causalgraph.LV, error) {
+	parents, err := causalgraph.LVToRawList(&d.CG, d.CG.Heads)
+	if err != nil {
+		return -1, fmt.Errorf("failed to convert current version to raw parents: %w", err)
+	}
+	if parents == nil {
+		parents = []causalgraph.RawVersion{}
+	}
+	cgAgentID := causalgraph.AgentID(agent)
+	id := causalgraph.RawVersion{Agent: cgAgentID, Seq: causalgraph.NextSeqForAgent(&d.CG, cgAgentID)}
+	r, err := d.addSpan(id, parents, []RichOp[T]{op})
+	if err != nil {
+		return -1, err
+	}
+	return r.Start, nil
+}
+
+// addSpan logs ops as a span with the given ID and parents and applies them.
+// If anything fails part way, the log, causal graph and context are restored
+// to their state before the call.
+func (d *RichDoc[T]) addSpan(id causalgraph.RawVersion, parents []causalgraph.RawVersion, ops []RichOp[T]) (causalgraph.LVRange, error) {
+	oldOps, oldContent := len(d.ops), d.content
+	var r causalgraph.LVRange
+	err := rollbackOnError(&d.CG, id.Agent, func() error {
+		entry, err := causalgraph.AddRaw(&d.CG, id, len(ops), parents)
+		if err != nil {
+			return fmt.Errorf("failed to add %s:%d to causal graph: %w", id.Agent, id.Seq, err)
+		}
+		r = causalgraph.LVRange{Start: entry.Version, End: entry.VEnd}
+		d.ops = append(d.ops, ops...)
+		return d.apply(r)
+	}, func() error {
+		d.ops = d.ops[:oldOps]
+		d.content = oldContent
+		ctx, err := replay(&d.CG, d.CG.Heads, d.eachListOp, nil)
+		if err != nil {
+			return err
+		}
+		d.Ctx = ctx
+		return nil
+	})
+	return r, err
+}
+
+// apply integrates the list operations logged in r and moves the context
+// back to the graph heads. Marks need no integration; they are resolved
+// when read.
+func (d *RichDoc[T]) apply(r causalgraph.LVRange) error {
+	err := d.eachListOp(r.Start, r.End, func(lv causalgraph.LV, op ListOp[T]) error {
+		endPos, err := integrateOp(d.Ctx, &d.CG, lv, op)
+		if err != nil {
+			return err
+		}
+		d.content = applyToRope(d.content, op, endPos)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("ops integrated (LVs %d-%d) but failed to apply to context: %w", r.Start, r.End, err)
+	}
+	if err := d.Ctx.moveTo(&d.CG, d.CG.Heads); err != nil {
+		return fmt.Errorf("ops integrated (LVs %d-%d) but failed to move context to heads: %w", r.Start, r.End, err)
+	}
+	return nil
+}
+
+// ApplyRemote integrates a span of operations received from another peer.
+// Operations the document already knows are skipped.
+func (d *RichDoc[T]) ApplyRemote(span RichSpan[T]) error {
+	id, parents, ops := span.ID, span.Parents, span.Ops
+	if len(ops) == 0 {
+		return nil
+	}
+	if parents == nil {
+		// A nil slice would make AddRaw use our own heads.
+		parents = []causalgraph.RawVersion{}
+	}
+	if next := causalgraph.NextSeqForAgent(&d.CG, id.Agent); id.Seq < next {
+		known := next - id.Seq
+		if known >= len(ops) {
+			return nil
+		}
+		ops = ops[known:]
+		parents = []causalgraph.RawVersion{{Agent: id.Agent, Seq: next - 1}}
+		id.Seq = next
+	}
+	parentLVs := make([]causalgraph.LV, 0, len(parents))
+	for _, p := range parents {
+		lv, err := causalgraph.RawToLV(&d.CG, p.Agent, p.Seq)
+		if err != nil {
+			return fmt.Errorf("applyRemote: %w", err)
+		}
+		parentLVs = append(parentLVs, lv)
+	}
+	for i, op := range ops {
+		if op.Mark == nil {
+			continue
+		}
+		// Anchors must refer to inserts in the history of the span, or to
+		// inserts earlier in the span itself.
+		for _, a := range []MarkAnchor{op.Mark.Start, op.Mark.End} {
+			if a.Edge {
+				continue
+			}
+			if a.Item.Agent == id.Agent && a.Item.Seq >= id.Seq {
+				if a.Item.Seq >= id.Seq+i {
+					return fmt.Errorf("applyRemote: %w: mark anchor %s:%d is not before the mark", ErrInvalidOp, a.Item.Agent, a.Item.Seq)
+				}
+				if in := ops[a.Item.Seq-id.Seq]; in.Mark != nil || in.Type != ListOpTypeInsert {
+					return fmt.Errorf("applyRemote: %w: mark anchor %s:%d is not an insert", ErrInvalidOp, a.Item.Agent, a.Item.Seq)
+				}
+				continue
+			}
+			lv, err := causalgraph.RawToLV(&d.CG, a.Item.Agent, a.Item.Seq)
+			if err != nil {
+				return fmt.Errorf("applyRemote: mark anchor: %w", err)
+			}
+			if d.ops[lv].Mark != nil || d.ops[lv].Type != ListOpTypeInsert {
+				return fmt.Errorf("applyRemote: %w: mark anchor %s:%d is not an insert", ErrInvalidOp, a.Item.Agent, a.Item.Seq)
+			}
+			if known, err := causalgraph.VersionContainsLV(&d.CG, parentLVs, lv); err != nil || !known {
+				return fmt.Errorf("applyRemote: %w: mark anchor %s:%d is not in the history of the span", ErrInvalidOp, a.Item.Agent, a.Item.Seq)
+			}
+		}
+	}
+
+	if _, err := d.addSpan(id, parents, ops); err != nil {
+		return fmt.Errorf("applyRemote: %w", err)
+	}
+	return nil
+}
+
+// Spans returns the document's whole history as spans which can be passed
+// to ApplyRemote on another RichDoc.
+func (d *RichDoc[T]) Spans() ([]RichSpan[T], error) {
+	spans := make([]RichSpan[T], 0, len(d.CG.Entries))
+	for _, entry := range d.CG.Entries {
+		parents, err := causalgraph.LVToRawList(&d.CG, entry.Parents)
+		if err != nil {
+			return nil, fmt.Errorf("spans: %w", err)
+		}
+		if parents == nil {
+			parents = []causalgraph.RawVersion{}
+		}
+		spans = append(spans, RichSpan[T]{
+			ID:      causalgraph.RawVersion{Agent: entry.Agent, Seq: entry.Seq},
+			Parents: parents,
+			Ops:     slices.Clone(d.ops[entry.Version:entry.VEnd]),
+		})
+	}
+	return spans, nil
+}
+
+// Checkout returns the elements and formatting of the document at version.
+func (d *RichDoc[T]) Checkout(version []causalgraph.LV) (*RichBranch[T], error) {
+	var content Rope[T]
+	ctx, err := replay(&d.CG, version, d.eachListOp, &content)
+	if err != nil {
+		return nil, fmt.Errorf("checkout: failed to replay to version %v: %w", version, err)
+	}
+	runs, err := d.resolveMarks(ctx, version)
+	if err != nil {
+		return nil, fmt.Errorf("checkout: %w", err)
+	}
+	return &RichBranch[T]{
+		Snapshot: content.Slice(),
+		Runs:     runs,
+		Version:  append([]causalgraph.LV{}, version...),
+	}, nil
+}
+
+// Marks returns the formatted runs of the current document.
+func (d *RichDoc[T]) Marks() ([]MarkRun, error) {
+	runs, err := d.resolveMarks(d.Ctx, d.CG.Heads)
+	if err != nil {
+		return nil, fmt.Errorf("marks: %w", err)
+	}
+	return runs, nil
+}
+
+// resolveMarks computes the formatted runs of the visible items of ctx,
+// which must be at version.
+//
+// Each mark covers the items between its two boundary gaps in the merged item
+// order, including items inserted concurrently into the range. Marks are
+// painted onto the items in a total order consistent with causality, so for
+// each mark type the last mark painted on an item is the one that applies.
+func (d *RichDoc[T]) resolveMarks(ctx *EditContext, version []causalgraph.LV) ([]MarkRun, error) {
+	_, history, err := causalgraph.DiffVersions(&d.CG, nil, version)
+	if err != nil {
+		return nil, err
+	}
+	var marks []causalgraph.LV
+	for _, r := range history {
+		for lv := r.Start; lv < r.End; lv++ {
+			if d.ops[lv].Mark != nil {
+				marks = append(marks, lv)
+			}
+		}
+	}
+	if len(marks) == 0 {
+		return nil, nil
+	}
+	order := newLamportOrder(&d.CG)
+	slices.SortFunc(marks, order.cmp)
+
+	index := make(map[causalgraph.LV]int, len(ctx.Items))
+	for i, item := range ctx.Items {
+		index[item.OpID] = i
+	}
+	gap := func(a MarkAnchor) (int, error) {
+		if a.Edge {
+			if a.After {
+				return 0, nil
+			}
+			return len(ctx.Items), nil
+		}
+		lv, err := causalgraph.RawToLV(&d.CG, a.Item.Agent, a.Item.Seq)
+		if err != nil {
+			return -1, err
+		}
+		i, ok := index[lv]
+		if !ok {
+			return -1, fmt.Errorf("mark anchor LV %d is not an item", lv)
+		}
+		if a.After {
+			i++
+		}
+		return i, nil
+	}
+
+	// painted[i][type] is the LV of the mark of that type applying to item i.
+	painted := make([]map[string]causalgraph.LV, len(ctx.Items))
+	for _, lv := range marks {
+		m := d.ops[lv].Mark
+		start, err := gap(m.Start)
+		if err != nil {
+			return nil, err
+		}
+		end, err := gap(m.End)
+		if err != nil {
+			return nil, err
+		}
+		for i := start; i < end; i++ {
+			if painted[i] == nil {
+				painted[i] = make(map[string]causalgraph.LV)
+			}
+			painted[i][m.Type] = lv
+		}
+	}
+
+	var runs []MarkRun
+	pos := 0
+	for i, item := range ctx.Items {
+		if item.CurState != Inserted {
+			continue
+		}
+		set := make(map[string]string)
+		for typ, lv := range painted[i] {
+			if m := d.ops[lv].Mark; !m.Remove {
+				set[typ] = m.Value
+			}
+		}
+		if n := len(runs); n > 0 && runs[n-1].End == pos && mapsEqual(runs[n-1].Marks, set) {
+			runs[n-1].End++
+		} else if len(set) > 0 {
+			runs = append(runs, MarkRun{Start: pos, End: pos + 1, Marks: set})
+		}
+		pos++
+	}
+	return runs, nil
+}
+
+// mapsEqual reports whether two mark sets are the same.
+func mapsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Items returns the current elements of the document.
+func (d *RichDoc[T]) Items() []T {
+	return d.content.Slice()
+}
+
+// Len returns the number of elements in the current document.
+func (d *RichDoc[T]) Len() int {
+	return d.content.Len()
+}
+
+// Version returns the current version (frontier) of the document.
+func (d *RichDoc[T]) Version() []causalgraph.LV {
+	return append([]causalgraph.LV{}, d.Ctx.CurVersion...)
+}
+
+// GetCG returns a pointer to the causal graph.
+func (d *RichDoc[T]) GetCG() *causalgraph.CausalGraph {
+	return &d.CG
+}
+
+// lamportOrder orders LVs by (depth, agent, seq), where an LV's depth is the
+// length of the longest path to it from the root of the graph. Every peer
+// computes the same depths, and a descendant is always deeper than its
+// ancestors, so this is a total order consistent with causality which all
+// peers agree on.
+type lamportOrder struct {
+	cg     *causalgraph.CausalGraph
+	depths []int // Depth of the first version of each entry.
+}
+
+func newLamportOrder(cg *causalgraph.CausalGraph) *lamportOrder {
+	o := &lamportOrder{cg: cg, depths: make([]int, 0, len(cg.Entries))}
+	o.update()
+	return o
+}
+
+// update computes the depths of the entries added to the graph since the
+// order was created or last updated.
+func (o *lamportOrder) update() {
+	for _, entry := range o.cg.Entries[len(o.depths):] {
+		depth := 0
+		for _, p := range entry.Parents {
+			depth = max(depth, o.depth(p)+1)
+		}
+		o.depths = append(o.depths, depth)
+	}
+}
+
+// depth returns the depth of lv, whose entry must already have been visited.
+func (o *lamportOrder) depth(lv causalgraph.LV) int {
+	i := sort.Search(len(o.cg.Entries), func(i int) bool { return o.cg.Entries[i].VEnd > lv })
+	return o.depths[i] + int(lv-o.cg.Entries[i].Version)
+}
+
+func (o *lamportOrder) cmp(a, b causalgraph.LV) int {
+	if da, db := o.depth(a), o.depth(b); da != db {
+		return da - db
+	}
+	return lvCmp(o.cg, a, b)
+}
@@ -0,0 +1,204 @@
+package egwalker
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+func syncRichDocs[T any](t *testing.T, dst, src *RichDoc[T]) {
+	t.Helper()
+	spans, err := src.Spans()
+	if err != nil {
+		t.Fatalf("Spans failed: %v", err)
+	}
+	for _, span := range spans {
+		if err := dst.ApplyRemote(span); err != nil {
+			t.Fatalf("ApplyRemote(%+v) failed: %v", span.ID, err)
+		}
+	}
+}
+
+func richInsert(t *testing.T, d *RichDoc[rune], agent string, pos int, s string) {
+	t.Helper()
+	for i, r := range []rune(s) {
+		if _, err := d.LocalInsert(agent, pos+i, r); err != nil {
+			t.Fatalf("LocalInsert failed: %v", err)
+		}
+	}
+}
+
+func marks(kv ...string) map[string]string {
+	m := make(map[string]string)
+	for i := 0; i < len(kv); i += 2 {
+		m[kv[i]] = kv[i+1]
+	}
+	return m
+}
+
+func TestRichDoc_ExpansionRules(t *testing.T) {
+	d := NewRichDoc[rune]()
+	richInsert(t, d, "alice", 0, "ab cd ef")
+	if _, err := d.AddMark("alice", 0, 2, "bold", "", ExpandAfter); err != nil {
+		t.Fatalf("AddMark failed: %v", err)
+	}
+	if _, err := d.AddMark("alice", 3, 5, "link", "https://example.com", ExpandNone); err != nil {
+		t.Fatalf("AddMark failed: %v", err)
+	}
+	// Typing at the end of each range: bold grows, the link does not. Typing
+	// at the start of bold does not make the new text bold.
+	richInsert(t, d, "alice", 5, "X")
+	richInsert(t, d, "alice", 2, "Y")
+	richInsert(t, d, "alice", 0, "Z")
+
+	if got := string(d.Items()); got != "ZabY cdX ef" {
+		t.Fatalf("got %q", got)
+	}
+	got, err := d.Marks()
+	if err != nil {
+		t.Fatalf("Marks failed: %v", err)
+	}
+	want := []MarkRun{
+		{Start: 1, End: 4, Marks: marks("bold", "")},
+		{Start: 5, End: 7, Marks: marks("link", "https://example.com")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRichDoc_ConcurrentMarks(t *testing.T) {
+	a := NewRichDoc[rune]()
+	richInsert(t, a, "alice", 0, "The fox jumped.")
+	b := NewRichDoc[rune]()
+	syncRichDocs(t, b, a)
+
+	// Alice bolds "The fox" while Bob inserts "quick " into it and removes
+	// bold from "fox jumped" concurrently.
+	if _, err := a.AddMark("alice", 0, 7, "bold", "", ExpandAfter); err != nil {
+		t.Fatalf("AddMark failed: %v", err)
+	}
+	richInsert(t, b, "bob", 4, "quick ")
+	if _, err := b.AddMark("bob", 0, 3, "italic", "", ExpandNone); err != nil {
+		t.Fatalf("AddMark failed: %v", err)
+	}
+	syncRichDocs(t, a, b)
+	syncRichDocs(t, b, a)
+	if _, err := b.RemoveMark("bob", 10, 20, "bold", ExpandNone); err != nil {
+		t.Fatalf("RemoveMark failed: %v", err)
+	}
+	syncRichDocs(t, a, b)
+
+	want := []MarkRun{
+		{Start: 0, End: 3, Marks: marks("bold", "", "italic", "")},
+		{Start: 3, End: 10, Marks: marks("bold", "")},
+	}
+	for name, d := range map[string]*RichDoc[rune]{"alice": a, "bob": b} {
+		if got := string(d.Items()); got != "The quick fox jumped." {
+			t.Fatalf("%s: got %q", name, got)
+		}
+		got, err := d.Marks()
+		if err != nil {
+			t.Fatalf("%s: Marks failed: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %+v, want %+v", name, got, want)
+		}
+	}
+
+	// Checkout resolves marks at older versions.
+	branch, err := a.Checkout(a.Version())
+	if err != nil {
+		t.Fatalf("Checkout failed: %v", err)
+	}
+	if !reflect.DeepEqual(branch.Runs, want) {
+		t.Errorf("checkout: got %+v, want %+v", branch.Runs, want)
+	}
+	branch, err = a.Checkout(nil)
+	if err != nil || len(branch.Snapshot) != 0 || branch.Runs != nil {
+		t.Errorf("checkout at root: got %+v, %v", branch, err)
+	}
+}
+
+func TestRichDoc_InvalidMarks(t *testing.T) {
+	d := NewRichDoc[rune]()
+	richInsert(t, d, "alice", 0, "abc")
+	if _, err := d.AddMark("alice", 2, 2, "bold", "", ExpandNone); err == nil {
+		t.Error("expected error for empty range")
+	}
+	if _, err := d.AddMark("alice", 0, 4, "bold", "", ExpandNone); err == nil {
+		t.Error("expected error for out of bounds range")
+	}
+
+	// A remote mark anchored to an unknown item is rejected.
+	spans, _ := d.Spans()
+	bad := RichSpan[rune]{
+		ID:      spans[0].ID,
+		Parents: []causalgraph.RawVersion{},
+		Ops:     []RichOp[rune]{{Mark: &MarkOp{Type: "bold", Start: MarkAnchor{Item: causalgraph.RawVersion{Agent: "carol", Seq: 9}}, End: MarkAnchor{Edge: true}}}},
+	}
+	bad.ID.Agent = "mallory"
+	if err := d.ApplyRemote(bad); err == nil {
+		t.Error("expected error for mark anchored to an unknown item")
+	}
+
+	// Anchors inside the span must refer to inserts before the mark.
+	parents := []causalgraph.RawVersion{{Agent: "alice", Seq: 2}}
+	insert := RichOp[rune]{ListOp: ListOp[rune]{Type: ListOpTypeInsert, Pos: 3, Content: 'd'}}
+	del := RichOp[rune]{ListOp: ListOp[rune]{Type: ListOpTypeDelete, Pos: 0}}
+	markAt := func(seq int) RichOp[rune] {
+		anchor := MarkAnchor{Item: causalgraph.RawVersion{Agent: "mallory", Seq: seq}}
+		return RichOp[rune]{Mark: &MarkOp{Type: "bold", Start: anchor, End: MarkAnchor{Edge: true}}}
+	}
+	for name, ops := range map[string][]RichOp[rune]{
+		"itself":   {insert, markAt(1)},
+		"later op": {insert, markAt(2), insert},
+		"delete":   {del, markAt(0)},
+		"mark":     {insert, markAt(0), markAt(1)},
+	} {
+		span := RichSpan[rune]{ID: causalgraph.RawVersion{Agent: "mallory", Seq: 0}, Parents: parents, Ops: ops}
+		if err := d.ApplyRemote(span); !errors.Is(err, ErrInvalidOp) {
+			t.Errorf("%s: expected ErrInvalidOp for a mark anchored to it, got %v", name, err)
+		}
+	}
+	good := RichSpan[rune]{ID: causalgraph.RawVersion{Agent: "mallory", Seq: 0}, Parents: parents, Ops: []RichOp[rune]{insert, markAt(0)}}
+	if err := d.ApplyRemote(good); err != nil {
+		t.Fatalf("ApplyRemote failed for a mark anchored to an earlier insert: %v", err)
+	}
+	if _, err := d.Marks(); err != nil {
+		t.Errorf("Marks failed: %v", err)
+	}
+}
+
+func TestRichDoc_ApplyRemote_InvalidPos(t *testing.T) {
+	d := NewRichDoc[rune]()
+	richInsert(t, d, "alice", 0, "ab")
+
+	span := RichSpan[rune]{
+		ID:      causalgraph.RawVersion{Agent: "mallory", Seq: 0},
+		Parents: []causalgraph.RawVersion{{Agent: "alice", Seq: 1}},
+		Ops: []RichOp[rune]{
+			{ListOp: ListOp[rune]{Type: ListOpTypeInsert, Pos: 2, Content: 'c'}},
+			{ListOp: ListOp[rune]{Type: ListOpTypeDelete, Pos: 5}},
+		},
+	}
+	if err := d.ApplyRemote(span); !errors.Is(err, ErrInvalidOp) {
+		t.Fatalf("expected ErrInvalidOp, got %v", err)
+	}
+	if got := string(d.Items()); got != "ab" {
+		t.Errorf("Items: got %q, want %q", got, "ab")
+	}
+	if _, err := d.Checkout(d.CG.Heads); err != nil {
+		t.Errorf("Checkout failed after a rejected span: %v", err)
+	}
+
+	span.Ops = span.Ops[:1]
+	if err := d.ApplyRemote(span); err != nil {
+		t.Fatalf("ApplyRemote failed: %v", err)
+	}
+	if got := string(d.Items()); got != "abc" {
+		t.Errorf("Items: got %q, want %q", got, "abc")
+	}
+}
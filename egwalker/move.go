@@ -0,0 +1,284 @@
+package egwalker
+
+import (
+	"fmt"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// A move is integrated as a new item at its destination, which stands for
+// the moved element there. Each moved element thus has several position
+// items: the item of its insert and one per move. Of the position items in
+// a version, only that of the winning move is visible; the others are
+// hidden as if deleted. Deleting the element hides all of them.
+//
+// Moves of an element are ranked by their depth, which is one more than
+// the greatest depth of the element's moves they have seen, and then by
+// agent and sequence number. A move thus beats every move it has seen, and
+// peers agree on the winner among concurrent moves.
+
+// moveSet tracks the position items of an element which has been moved.
+type moveSet struct {
+	moves []moveEntry
+	// inserted is set while the element's insert is in the current version.
+	inserted bool
+	// curDeletes and endDeletes count the deletes of the element in the
+	// current version and among all operations applied.
+	curDeletes, endDeletes int
+	// curWinner and endWinner are the LVs of the visible position items in
+	// the current version and the merged document.
+	curWinner, endWinner causalgraph.LV
+}
+
+// moveEntry is a move of an element, along with its rank.
+type moveEntry struct {
+	lv    causalgraph.LV
+	depth int
+	id    causalgraph.RawVersion
+	// cur is set while the move is in the current version.
+	cur bool
+}
+
+func (a moveEntry) beats(b moveEntry) bool {
+	if a.depth != b.depth {
+		return a.depth > b.depth
+	}
+	if a.id.Agent != b.id.Agent {
+		return a.id.Agent > b.id.Agent
+	}
+	return a.id.Seq > b.id.Seq
+}
+
+// element returns the LV of the insert of the element the item created by
+// lv stands for.
+func (ctx *EditContext) element(lv causalgraph.LV) causalgraph.LV {
+	if elem, ok := ctx.MoveTargets[lv]; ok {
+		return elem
+	}
+	return lv
+}
+
+// moveSetOf returns the moveSet of elem, creating it from the state of the
+// element's item if it has not been moved before.
+func (ctx *EditContext) moveSetOf(elem causalgraph.LV) *moveSet {
+	if ms, ok := ctx.moved[elem]; ok {
+		return ms
+	}
+	item := ctx.ItemsByLV[elem]
+	ms := &moveSet{
+		inserted:   item.CurState != NotYetInserted,
+		curDeletes: max(0, int(item.CurState)),
+		endDeletes: max(0, int(item.EndState)),
+		curWinner:  elem,
+		endWinner:  elem,
+	}
+	ctx.moved[elem] = ms
+	return ms
+}
+
+// updateMoved sets the states of the position items of the moved element
+// elem from its moveSet.
+func (ctx *EditContext) updateMoved(elem causalgraph.LV) {
+	ms := ctx.moved[elem]
+	// The insert ranks below every move.
+	curBest, endBest := moveEntry{lv: elem}, moveEntry{lv: elem}
+	for _, m := range ms.moves {
+		if m.cur && m.beats(curBest) {
+			curBest = m
+		}
+		if m.beats(endBest) {
+			endBest = m
+		}
+	}
+	ms.curWinner, ms.endWinner = curBest.lv, endBest.lv
+
+	state := func(lv causalgraph.LV, applied bool) (ItemState, ItemState) {
+		cur, end := NotYetInserted, ItemState(ms.endDeletes)
+		if applied {
+			cur = ItemState(ms.curDeletes)
+			if lv != ms.curWinner {
+				cur++
+			}
+		}
+		if lv != ms.endWinner {
+			end++
+		}
+		return cur, end
+	}
+	item := ctx.ItemsByLV[elem]
+	item.CurState, item.EndState = state(elem, ms.inserted)
+	for _, m := range ms.moves {
+		item := ctx.ItemsByLV[m.lv]
+		item.CurState, item.EndState = state(m.lv, m.cur)
+	}
+}
+
+// advanceMoved re-applies lv, an operation which inserted, moved or deleted
+// a moved element, to the current version. It reports false if lv is not
+// such an operation.
+func (ctx *EditContext) advanceMoved(lv causalgraph.LV, delta int) bool {
+	if target, ok := ctx.DelTargets[lv]; ok {
+		ms, ok := ctx.moved[target]
+		if !ok {
+			return false
+		}
+		ms.curDeletes += delta
+		ctx.updateMoved(target)
+		return true
+	}
+	if elem, ok := ctx.MoveTargets[lv]; ok {
+		ms := ctx.moved[elem]
+		for i := range ms.moves {
+			if ms.moves[i].lv == lv {
+				ms.moves[i].cur = delta > 0
+			}
+		}
+		ctx.updateMoved(elem)
+		return true
+	}
+	if ms, ok := ctx.moved[lv]; ok {
+		ms.inserted = delta > 0
+		ctx.updateMoved(lv)
+		return true
+	}
+	return false
+}
+
+// deleteMoved applies the delete at lv of the moved element elem. It
+// returns the position in the merged document the element was removed
+// from, or -1 if it was already deleted there.
+func (ctx *EditContext) deleteMoved(lv, elem causalgraph.LV) (int, error) {
+	ms := ctx.moved[elem]
+	changedAt := -1
+	if ms.endDeletes == 0 {
+		pos, err := ctx.endPosOf(ms.endWinner)
+		if err != nil {
+			return -1, fmt.Errorf("applyOp: delete LV %d: %w", lv, err)
+		}
+		changedAt = pos
+	}
+	ms.curDeletes++
+	ms.endDeletes++
+	ctx.DelTargets[lv] = elem
+	ctx.updateMoved(elem)
+	return changedAt, nil
+}
+
+// endPosOf returns the number of items visible in the merged document
+// before the item created by lv.
+func (ctx *EditContext) endPosOf(lv causalgraph.LV) (int, error) {
+	pos := 0
+	for i := range ctx.Items {
+		if ctx.Items[i].OpID == lv {
+			return pos, nil
+		}
+		if ctx.Items[i].EndState == Inserted {
+			pos++
+		}
+	}
+	return -1, fmt.Errorf("item for LV %d not found in Items", lv)
+}
+
+// applyMove applies the move at lv to ctx, which must be at the parents of
+// the move. It returns the positions in the merged document the element was
+// moved from and to, the latter counted without the element, or -1 for both
+// if the merged document is unchanged.
+func applyMove[T any](ctx *EditContext, cg *causalgraph.CausalGraph, lv causalgraph.LV, op ListOp[T]) (from, to int, err error) {
+	fromIdx, _, err := ctx.findByCurPos(op.From)
+	if err != nil {
+		return -1, -1, fmt.Errorf("applyMove: LV %d: %w", lv, err)
+	}
+	for fromIdx < len(ctx.Items) && ctx.Items[fromIdx].CurState != Inserted {
+		fromIdx++
+	}
+	if fromIdx >= len(ctx.Items) {
+		return -1, -1, fmt.Errorf("applyMove: LV %d: %w: position %d is past the end of the document", lv, ErrInvalidOp, op.From)
+	}
+	elem := ctx.element(ctx.Items[fromIdx].OpID)
+
+	// The destination counts positions without the element, which is still
+	// visible in the current version.
+	dest := op.Pos
+	if dest >= op.From {
+		dest++
+	}
+	idx, endPos, err := ctx.findByCurPos(dest)
+	if err != nil {
+		return -1, -1, fmt.Errorf("applyMove: LV %d: %w", lv, err)
+	}
+
+	ms := ctx.moveSetOf(elem)
+	oldWinner := ms.endWinner
+	if ms.endDeletes == 0 {
+		if from, err = ctx.endPosOf(oldWinner); err != nil {
+			return -1, -1, fmt.Errorf("applyMove: LV %d: %w", lv, err)
+		}
+	}
+	raw, _ := causalgraph.LVToRaw(cg, lv)
+	m := moveEntry{lv: lv, depth: 1, id: raw, cur: true}
+	for _, seen := range ms.moves {
+		if seen.cur {
+			m.depth = max(m.depth, seen.depth+1)
+		}
+	}
+
+	if _, err := integrate(ctx, cg, ctx.newItem(lv, idx), idx, endPos); err != nil {
+		return -1, -1, fmt.Errorf("applyMove: LV %d: %w", lv, err)
+	}
+	ms.moves = append(ms.moves, m)
+	ctx.MoveTargets[lv] = elem
+	ctx.updateMoved(elem)
+
+	if ms.endWinner == oldWinner || ms.endDeletes > 0 {
+		return -1, -1, nil
+	}
+	if to, err = ctx.endPosOf(lv); err != nil {
+		return -1, -1, fmt.Errorf("applyMove: LV %d: %w", lv, err)
+	}
+	return from, to, nil
+}
+
+// integrateMove moves ctx to the parents of the move at lv, applies it and
+// leaves ctx at version [lv]. See applyMove for the returned positions.
+func integrateMove[T any](ctx *EditContext, cg *causalgraph.CausalGraph, lv causalgraph.LV, op ListOp[T]) (from, to int, err error) {
+	_, _, parents, found := causalgraph.LVToRawWithParents(cg, lv)
+	if !found {
+		return -1, -1, fmt.Errorf("integrateMove: LV %d not found in causal graph", lv)
+	}
+	if err := ctx.moveTo(cg, parents); err != nil {
+		return -1, -1, fmt.Errorf("integrateMove: LV %d: %w", lv, err)
+	}
+	if from, to, err = applyMove(ctx, cg, lv, op); err != nil {
+		return -1, -1, err
+	}
+	ctx.CurVersion = []causalgraph.LV{lv}
+	return from, to, nil
+}
+
+// moveInRope returns content with the element at from moved to to, as
+// reported by applyMove.
+func moveInRope[T any](content Rope[T], from, to int) Rope[T] {
+	if from < 0 {
+		return content
+	}
+	v := content.At(from)
+	return content.Delete(from).Insert(to, v)
+}
+
+// LocalMove moves the element at from so that it ends up at to in the
+// current document, on behalf of agent. The element keeps its identity:
+// concurrent edits to it follow it, and if it is moved concurrently by
+// several peers, one of the moves wins everywhere rather than duplicating
+// it.
+func (w *Walker[T]) LocalMove(agent string, from, to int) (causalgraph.LV, error) {
+	op := ListOp[T]{
+		Type: ListOpTypeMove,
+		Pos:  to,
+		From: from,
+	}
+	lv, err := w.Integrate(op, agent, nil)
+	if err != nil {
+		return -1, fmt.Errorf("localMove: failed to integrate op: %w", err)
+	}
+	return lv, nil
+}
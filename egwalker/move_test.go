@@ -0,0 +1,174 @@
+package egwalker
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+func newList(t *testing.T, agent string, items ...string) *Walker[string] {
+	t.Helper()
+	w := NewWalker[string]()
+	for i, s := range items {
+		if _, err := w.LocalInsert(agent, i, s); err != nil {
+			t.Fatalf("LocalInsert failed: %v", err)
+		}
+	}
+	return w
+}
+
+// checkItems checks the walker's content, that replaying its history gives
+// the same and that its causal graph is valid.
+func checkItems(t *testing.T, w *Walker[string], want ...string) {
+	t.Helper()
+	if got := w.GetActiveItems(); !reflect.DeepEqual(got, want) && len(got)+len(want) > 0 {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	branch, err := w.Checkout(w.GetVersion())
+	if err != nil {
+		t.Fatalf("Checkout failed: %v", err)
+	}
+	if !reflect.DeepEqual(branch.Snapshot, w.GetActiveItems()) {
+		t.Errorf("checkout %v differs from content %v", branch.Snapshot, w.GetActiveItems())
+	}
+	if err := causalgraph.Validate(&w.Log.CG); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWalker_LocalMove(t *testing.T) {
+	w := newList(t, "alice", "a", "b", "c", "d")
+	if _, err := w.LocalMove("alice", 0, 2); err != nil {
+		t.Fatalf("LocalMove failed: %v", err)
+	}
+	checkItems(t, w, "b", "c", "a", "d")
+	w.LocalMove("alice", 3, 0)
+	checkItems(t, w, "d", "b", "c", "a")
+	w.LocalMove("alice", 3, 3)
+	checkItems(t, w, "d", "b", "c", "a")
+
+	// Moved elements can be deleted and moved again.
+	w.LocalMove("alice", 0, 3)
+	checkItems(t, w, "b", "c", "a", "d")
+	w.LocalDelete("alice", 3)
+	checkItems(t, w, "b", "c", "a")
+
+	if _, err := w.LocalMove("alice", 3, 0); err == nil {
+		t.Error("expected an error moving past the end")
+	}
+	if _, err := w.LocalMove("alice", 0, 3); err == nil {
+		t.Error("expected an error moving to past the end")
+	}
+
+	// Blame credits the insert, not the move.
+	runs, err := w.Blame(w.GetVersion())
+	if err != nil {
+		t.Fatalf("Blame failed: %v", err)
+	}
+	if runs[0].LV != 1 {
+		t.Errorf("blame: got %+v", runs)
+	}
+}
+
+func TestWalker_ConcurrentMoves(t *testing.T) {
+	a := newList(t, "alice", "a", "b", "c", "d")
+	b := NewWalker[string]()
+	syncWalkers(t, b, a)
+
+	// Both move "a"; neither ends up with two copies, and bob's move wins
+	// on both sides.
+	a.LocalMove("alice", 0, 3)
+	b.LocalMove("bob", 0, 1)
+	syncWalkers(t, a, b)
+	syncWalkers(t, b, a)
+	checkItems(t, a, "b", "a", "c", "d")
+	checkItems(t, b, "b", "a", "c", "d")
+
+	// A move made after seeing another beats it, whatever the agents.
+	a.LocalMove("alice", 1, 3)
+	syncWalkers(t, b, a)
+	checkItems(t, b, "b", "c", "d", "a")
+
+	// Edits made concurrently with a move are kept.
+	a.LocalMove("alice", 3, 0)
+	b.LocalInsert("bob", 3, "e")
+	b.LocalDelete("bob", 0)
+	syncWalkers(t, a, b)
+	syncWalkers(t, b, a)
+	checkItems(t, a, "a", "c", "d", "e")
+	checkItems(t, b, "a", "c", "d", "e")
+
+	// A concurrent delete removes the element wherever it was moved.
+	a.LocalMove("alice", 0, 2)
+	b.LocalDelete("bob", 0)
+	syncWalkers(t, a, b)
+	syncWalkers(t, b, a)
+	checkItems(t, a, "c", "d", "e")
+	checkItems(t, b, "c", "d", "e")
+}
+
+func TestWalker_MoveSubscribe(t *testing.T) {
+	a := newList(t, "alice", "a", "b", "c")
+	b := NewWalker[string]()
+	syncWalkers(t, b, a)
+	m := mirror[string]{items: b.GetActiveItems()}
+	b.Subscribe(m.observe)
+
+	a.LocalMove("alice", 0, 2)
+	b.LocalMove("bob", 2, 0)
+	syncWalkers(t, b, a)
+	if !reflect.DeepEqual(m.items, b.GetActiveItems()) {
+		t.Errorf("mirror %v differs from content %v", m.items, b.GetActiveItems())
+	}
+}
+
+func TestWalker_RandomMoves(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	for round := 0; round < 20; round++ {
+		peers := []*Walker[string]{NewWalker[string](), NewWalker[string](), NewWalker[string]()}
+		agents := []string{"alice", "bob", "carol"}
+		var m mirror[string]
+		peers[0].Subscribe(m.observe)
+		n := 0
+		for step := 0; step < 60; step++ {
+			i := rng.IntN(len(peers))
+			w := peers[i]
+			switch l := w.Len(); {
+			case l < 2 || rng.IntN(3) == 0:
+				n++
+				w.LocalInsert(agents[i], rng.IntN(l+1), fmt.Sprint(n))
+			case rng.IntN(4) == 0:
+				w.LocalDelete(agents[i], rng.IntN(l))
+			default:
+				if _, err := w.LocalMove(agents[i], rng.IntN(l), rng.IntN(l)); err != nil {
+					t.Fatalf("LocalMove failed: %v", err)
+				}
+			}
+			if rng.IntN(4) == 0 {
+				syncWalkers(t, peers[rng.IntN(len(peers))], w)
+			}
+		}
+		for _, dst := range peers {
+			for _, src := range peers {
+				syncWalkers(t, dst, src)
+			}
+		}
+		want := peers[0].GetActiveItems()
+		seen := make(map[string]bool)
+		for _, s := range want {
+			if seen[s] {
+				t.Fatalf("round %d: %q appears twice in %v", round, s, want)
+			}
+			seen[s] = true
+		}
+		for _, w := range peers {
+			checkItems(t, w, want...)
+		}
+		if !reflect.DeepEqual(m.items, want) && len(m.items)+len(want) > 0 {
+			t.Errorf("round %d: mirror %v differs from content %v", round, m.items, want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package egwalker
+
+import (
+	"fmt"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// RegisterSpan is a run of consecutive register writes by a single agent, in
+// the form exchanged between peers. See RemoteSpan.
+type RegisterSpan[V any] struct {
+	ID      causalgraph.RawVersion
+	Parents []causalgraph.RawVersion
+	Ops     []V
+}
+
+// RegisterValue is one of the values of an MVRegister and the write which
+// set it.
+type RegisterValue[V any] struct {
+	Value V
+	LV    causalgraph.LV
+	ID    causalgraph.RawVersion
+}
+
+// MVRegister is a multi-value register versioned by a causal graph, which may
+// be shared with other data. Instead of picking a winner among concurrent
+// writes, it keeps all of them: its values at a version are those of the
+// writes in the version's history which no other write has seen. A write
+// made with Resolve replaces all the conflicting values at once.
+//
+// As with LWWMap, when the graph is shared, spans must be applied on other
+// peers in the order of their LVs on the sending peer.
+type MVRegister[V any] struct {
+	log *sharedLog[V]
+	// writes lists the LVs of every write, in increasing order.
+	writes []causalgraph.LV
+}
+
+// NewMVRegister creates an empty register versioned by cg.
+func NewMVRegister[V any](cg *causalgraph.CausalGraph) *MVRegister[V] {
+	return &MVRegister[V]{log: newSharedLog[V](cg)}
+}
+
+// Set writes value on behalf of agent, with the graph heads as parents.
+func (r *MVRegister[V]) Set(agent string, value V) (causalgraph.LV, error) {
+	lv, err := r.log.local(agent, nil, value)
+	if err != nil {
+		return -1, fmt.Errorf("set: %w", err)
+	}
+	r.writes = append(r.writes, lv)
+	return lv, nil
+}
+
+// Resolve writes value on behalf of agent with the register's current
+// values as parents, so it supersedes exactly the writes in conflict and no
+// others.
+func (r *MVRegister[V]) Resolve(agent string, value V) (causalgraph.LV, error) {
+	history, err := r.log.history(r.log.cg.Heads)
+	if err != nil {
+		return -1, fmt.Errorf("resolve: %w", err)
+	}
+	conflicting, err := r.log.latest(r.writes, history)
+	if err != nil {
+		return -1, fmt.Errorf("resolve: %w", err)
+	}
+	if conflicting == nil {
+		conflicting = []causalgraph.LV{}
+	}
+	lv, err := r.log.local(agent, conflicting, value)
+	if err != nil {
+		return -1, fmt.Errorf("resolve: %w", err)
+	}
+	r.writes = append(r.writes, lv)
+	return lv, nil
+}
+
+// ApplyRemote integrates a span of writes received from another peer.
+// Writes the graph already knows are skipped.
+func (r *MVRegister[V]) ApplyRemote(span RegisterSpan[V]) error {
+	added, err := r.log.applyRemote(span.ID, span.Parents, span.Ops)
+	if err != nil {
+		return fmt.Errorf("applyRemote: %w", err)
+	}
+	for lv := added.Start; lv < added.End; lv++ {
+		r.writes = append(r.writes, lv)
+	}
+	return nil
+}
+
+// Spans returns the register's whole history as spans which can be passed
+// to ApplyRemote on another MVRegister.
+func (r *MVRegister[V]) Spans() ([]RegisterSpan[V], error) {
+	var spans []RegisterSpan[V]
+	err := r.log.eachSpan(func(id causalgraph.RawVersion, parents []causalgraph.RawVersion, ops []V) {
+		spans = append(spans, RegisterSpan[V]{ID: id, Parents: parents, Ops: ops})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("spans: %w", err)
+	}
+	return spans, nil
+}
+
+// Checkout returns the register's values at version, ordered by agent and
+// then sequence number. More than one value means there are concurrent
+// writes in conflict; none means nothing has been written.
+func (r *MVRegister[V]) Checkout(version []causalgraph.LV) ([]RegisterValue[V], error) {
+	history, err := r.log.history(version)
+	if err != nil {
+		return nil, fmt.Errorf("checkout: %w", err)
+	}
+	latest, err := r.log.latest(r.writes, history)
+	if err != nil {
+		return nil, fmt.Errorf("checkout: %w", err)
+	}
+	values := make([]RegisterValue[V], 0, len(latest))
+	for _, lv := range latest {
+		raw, _ := causalgraph.LVToRaw(r.log.cg, lv)
+		values = append(values, RegisterValue[V]{Value: r.log.ops[lv], LV: lv, ID: raw})
+	}
+	return values, nil
+}
+
+// Values returns the register's current values. See Checkout.
+func (r *MVRegister[V]) Values() ([]RegisterValue[V], error) {
+	return r.Checkout(r.log.cg.Heads)
+}
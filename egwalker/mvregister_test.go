@@ -0,0 +1,108 @@
+package egwalker
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+func syncRegisters[V any](t *testing.T, dst, src *MVRegister[V]) {
+	t.Helper()
+	spans, err := src.Spans()
+	if err != nil {
+		t.Fatalf("Spans failed: %v", err)
+	}
+	for _, span := range spans {
+		if err := dst.ApplyRemote(span); err != nil {
+			t.Fatalf("ApplyRemote(%+v) failed: %v", span.ID, err)
+		}
+	}
+}
+
+func registerValues[V any](t *testing.T, r *MVRegister[V]) []V {
+	t.Helper()
+	values, err := r.Values()
+	if err != nil {
+		t.Fatalf("Values failed: %v", err)
+	}
+	out := make([]V, len(values))
+	for i, v := range values {
+		out[i] = v.Value
+	}
+	return out
+}
+
+func TestMVRegister_Conflicts(t *testing.T) {
+	a := NewMVRegister[string](causalgraph.CreateCG())
+	b := NewMVRegister[string](causalgraph.CreateCG())
+	if got := registerValues(t, a); len(got) != 0 {
+		t.Errorf("expected no values, got %v", got)
+	}
+
+	a.Set("alice", "draft")
+	syncRegisters(t, b, a)
+	before := b.log.cg.Heads
+
+	a.Set("alice", "review")
+	b.Set("bob", "published")
+	syncRegisters(t, a, b)
+	syncRegisters(t, b, a)
+
+	want := []string{"review", "published"}
+	if got := registerValues(t, a); !reflect.DeepEqual(got, want) {
+		t.Errorf("alice: got %v, want %v", got, want)
+	}
+	if got := registerValues(t, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("bob: got %v, want %v", got, want)
+	}
+	values, _ := b.Values()
+	if values[1].ID != (causalgraph.RawVersion{Agent: "bob", Seq: 0}) {
+		t.Errorf("unexpected ID %+v", values[1].ID)
+	}
+
+	// Resolving makes a write whose parents are exactly the conflicting writes.
+	lv, err := a.Resolve("alice", "review")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	_, _, parents, _ := causalgraph.LVToRawWithParents(a.log.cg, lv)
+	conflicting := []causalgraph.LV{values[0].LV, values[1].LV}
+	slices.Sort(conflicting)
+	if !reflect.DeepEqual(parents, conflicting) {
+		t.Errorf("resolve parents: got %v, want %v", parents, conflicting)
+	}
+	syncRegisters(t, b, a)
+	if got := registerValues(t, b); !reflect.DeepEqual(got, []string{"review"}) {
+		t.Errorf("after resolve: got %v", got)
+	}
+
+	got, err := b.Checkout(before)
+	if err != nil {
+		t.Fatalf("Checkout failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Value != "draft" {
+		t.Errorf("checkout: got %+v", got)
+	}
+}
+
+func TestMVRegister_ResolveKeepsConcurrentWrites(t *testing.T) {
+	a := NewMVRegister[int](causalgraph.CreateCG())
+	b := NewMVRegister[int](causalgraph.CreateCG())
+	a.Set("alice", 1)
+	b.Set("bob", 2)
+	syncRegisters(t, a, b)
+	syncRegisters(t, b, a)
+
+	// Carol's write is concurrent with alice's resolve and so survives it.
+	c := NewMVRegister[int](causalgraph.CreateCG())
+	syncRegisters(t, c, a)
+	c.Set("carol", 3)
+	a.Resolve("alice", 4)
+	syncRegisters(t, a, c)
+
+	if got, want := registerValues(t, a), []int{4, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+package egwalker
+
+import (
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// ChangeEvent describes one change to a document: a local operation, a
+// transaction, or a merged remote span.
+type ChangeEvent[T any] struct {
+	// Changes are the edits to the visible document, in order. Each position
+	// refers to the document as left by the changes before it, so applying
+	// them to the previous content yields the new content. Operations which
+	// do not change the visible document, like a delete of content already
+	// deleted concurrently, produce no change.
+	Changes []ListOp[T]
+	// LVs is the range of operations integrated.
+	LVs causalgraph.LVRange
+	// Agents lists, sorted, the agents which created the operations.
+	Agents []causalgraph.AgentID
+	// Local reports whether the operations were made locally rather than
+	// received from a peer.
+	Local bool
+	// Version is the document version after the change.
+	Version []causalgraph.LV
+}
+
+// observer is a subscribed callback.
+type observer[T any] struct {
+	id int
+	fn func(ChangeEvent[T])
+}
+
+// Subscribe registers fn to be called after every change to the walker, in
+// the order changes are made. fn runs synchronously on the goroutine making
+// the change and must not modify the walker. The returned function
+// unsubscribes fn; it is safe to call more than once.
+func (w *Walker[T]) Subscribe(fn func(ChangeEvent[T])) (unsubscribe func()) {
+	id := w.nextObserverID
+	w.nextObserverID++
+	w.observers = append(w.observers, observer[T]{id: id, fn: fn})
+	return func() {
+		for i, o := range w.observers {
+			if o.id == id {
+				w.observers = append(w.observers[:i:i], w.observers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// emit notifies observers of the operations in r, using the positional
+// changes collected while they were applied.
+func (w *Walker[T]) emit(r causalgraph.LVRange, local bool) {
+	changes := w.changes
+	w.changes = nil
+	if len(w.observers) == 0 {
+		return
+	}
+	ev := ChangeEvent[T]{
+		Changes: changes,
+		LVs:     r,
+		Agents:  sortAgents(agentsInRanges(&w.Log.CG, []causalgraph.LVRange{r})),
+		Local:   local,
+		Version: w.GetVersion(),
+	}
+	// Copy so observers may unsubscribe while being notified.
+	for _, o := range append([]observer[T]{}, w.observers...) {
+		o.fn(ev)
+	}
+}
@@ -0,0 +1,80 @@
+package egwalker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// mirror keeps a copy of a walker's content up to date from change events alone.
+type mirror[T any] struct {
+	items  []T
+	events []ChangeEvent[T]
+}
+
+func (m *mirror[T]) observe(ev ChangeEvent[T]) {
+	m.events = append(m.events, ev)
+	m.items = applyListOps(m.items, ev.Changes)
+}
+
+func TestWalker_Subscribe(t *testing.T) {
+	a := NewWalker[string]()
+	var m mirror[string]
+	unsubscribe := a.Subscribe(m.observe)
+
+	a.LocalInsert("alice", 0, "a")
+	a.LocalInsert("alice", 1, "c")
+	if len(m.events) != 2 || !m.events[0].Local || m.events[1].LVs != (causalgraph.LVRange{Start: 1, End: 2}) {
+		t.Fatalf("unexpected events %+v", m.events)
+	}
+	if got, want := m.events[1].Agents, []causalgraph.AgentID{"alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("agents: got %v, want %v", got, want)
+	}
+
+	// A concurrent remote edit is reported at its position in the merged document.
+	b := NewWalker[string]()
+	syncWalkers(t, b, a)
+	b.LocalInsert("bob", 1, "b")
+	b.LocalDelete("bob", 0)
+	a.LocalInsert("alice", 2, "d")
+	syncWalkers(t, a, b)
+
+	last := m.events[len(m.events)-1]
+	if last.Local || !reflect.DeepEqual(last.Agents, []causalgraph.AgentID{"bob"}) {
+		t.Errorf("unexpected remote event %+v", last)
+	}
+	compareLVSlices(t, last.Version, a.GetVersion())
+	if got := a.GetActiveItems(); !reflect.DeepEqual(m.items, got) {
+		t.Errorf("mirror got %v, want %v", m.items, got)
+	}
+
+	// Unsubscribing stops delivery and is idempotent.
+	n := len(m.events)
+	unsubscribe()
+	unsubscribe()
+	a.LocalInsert("alice", 0, "z")
+	if len(m.events) != n {
+		t.Errorf("received %d events after unsubscribing", len(m.events)-n)
+	}
+}
+
+func TestWalker_Subscribe_Transaction(t *testing.T) {
+	w := NewWalker[int]()
+	var m mirror[int]
+	w.Subscribe(m.observe)
+	w.Transact("alice", func(tx *Tx[int]) error {
+		tx.Insert(0, 1)
+		tx.Insert(1, 2)
+		return tx.Delete(0)
+	})
+	// A failed transaction produces no event.
+	w.Transact("alice", func(tx *Tx[int]) error { return tx.Delete(10) })
+
+	if len(m.events) != 1 || len(m.events[0].Changes) != 3 {
+		t.Fatalf("expected one event with 3 changes, got %+v", m.events)
+	}
+	if want := []int{2}; !reflect.DeepEqual(m.items, want) {
+		t.Errorf("mirror got %v, want %v", m.items, want)
+	}
+}
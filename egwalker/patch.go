@@ -0,0 +1,307 @@
+package egwalker
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// patchContext is the number of unchanged lines shown around each hunk.
+const patchContext = 3
+
+// PatchLineKind says whether a line of a hunk is context, removed or added.
+type PatchLineKind byte
+
+const (
+	PatchLineContext PatchLineKind = ' '
+	PatchLineRemoved PatchLineKind = '-'
+	PatchLineAdded   PatchLineKind = '+'
+)
+
+// PatchLine is one line of a hunk. Text includes the trailing newline, if any.
+type PatchLine struct {
+	Kind PatchLineKind
+	Text string
+}
+
+// Hunk is a group of nearby changed lines with their surrounding context.
+// Starts are 1-based line numbers as in a unified diff; a start refers to the
+// line before the hunk when its line count is 0.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []PatchLine
+	// Agents lists, sorted, the agents whose operations between the two
+	// versions inserted or deleted text inside the hunk.
+	Agents []causalgraph.AgentID
+}
+
+// Patch describes the changes between two versions of a text document.
+type Patch struct {
+	From, To []causalgraph.LV
+	Hunks    []Hunk
+	// Agents lists, sorted, every agent with operations in the causal graph
+	// diff between From and To, including those whose edits cancelled out.
+	Agents []causalgraph.AgentID
+}
+
+// charChange is one character present in only one of the two versions,
+// along with the agents whose operations made the difference.
+type charChange struct {
+	line   int // Line of the character in the version containing it.
+	added  bool
+	agents []causalgraph.AgentID
+}
+
+// Diff compares the document at from and to and returns the changed lines as
+// hunks. Rather than guessing from the two texts alone, it replays both
+// versions together so each changed character is attributed to the agents
+// whose operations inserted or deleted it.
+func (d *TextDoc) Diff(from, to []causalgraph.LV) (*Patch, error) {
+	cg := &d.Log.CG
+	aOnly, bOnly, err := causalgraph.DiffVersions(cg, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("diff: %w", err)
+	}
+	_, fromHist, err := causalgraph.DiffVersions(cg, nil, from)
+	if err != nil {
+		return nil, fmt.Errorf("diff: %w", err)
+	}
+	_, toHist, err := causalgraph.DiffVersions(cg, nil, to)
+	if err != nil {
+		return nil, fmt.Errorf("diff: %w", err)
+	}
+	union := append(append([]causalgraph.LV{}, from...), to...)
+	ctx, err := replay(cg, union, d.Log.eachOp, nil)
+	if err != nil {
+		return nil, fmt.Errorf("diff: %w", err)
+	}
+
+	_, unionHist, err := causalgraph.DiffVersions(cg, nil, union)
+	if err != nil {
+		return nil, fmt.Errorf("diff: %w", err)
+	}
+	chars := make(map[causalgraph.LV]rune)
+	for _, r := range unionHist {
+		err := d.Log.eachOp(r.Start, r.End, func(lv causalgraph.LV, op ListOp[rune]) error {
+			if op.Type == ListOpTypeInsert {
+				chars[lv] = op.Content
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("diff: %w", err)
+		}
+	}
+	deletes := make(map[causalgraph.LV][]causalgraph.LV)
+	for del, target := range ctx.DelTargets {
+		deletes[target] = append(deletes[target], del)
+	}
+
+	agentOf := func(lv causalgraph.LV) causalgraph.AgentID {
+		raw, _ := causalgraph.LVToRaw(cg, lv)
+		return raw.Agent
+	}
+	inDiff := func(lv causalgraph.LV) bool {
+		return lvRangesContain(aOnly, lv) || lvRangesContain(bOnly, lv)
+	}
+	visible := func(item Item, hist []causalgraph.LVRange) bool {
+		if !lvRangesContain(hist, item.OpID) {
+			return false
+		}
+		for _, del := range deletes[item.OpID] {
+			if lvRangesContain(hist, del) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var oldText, newText strings.Builder
+	var changes []charChange
+	oldLine, newLine := 0, 0
+	for _, item := range ctx.Items {
+		inOld, inNew := visible(item, fromHist), visible(item, toHist)
+		if !inOld && !inNew {
+			continue
+		}
+		c := chars[item.OpID]
+		if inOld != inNew {
+			change := charChange{line: oldLine, added: inNew}
+			if inNew {
+				change.line = newLine
+			}
+			for _, lv := range append([]causalgraph.LV{item.OpID}, deletes[item.OpID]...) {
+				if inDiff(lv) {
+					change.agents = append(change.agents, agentOf(lv))
+				}
+			}
+			changes = append(changes, change)
+		}
+		if inOld {
+			oldText.WriteRune(c)
+			if c == '\n' {
+				oldLine++
+			}
+		}
+		if inNew {
+			newText.WriteRune(c)
+			if c == '\n' {
+				newLine++
+			}
+		}
+	}
+
+	patch := &Patch{
+		From:  append([]causalgraph.LV{}, from...),
+		To:    append([]causalgraph.LV{}, to...),
+		Hunks: buildHunks(splitLines(oldText.String()), splitLines(newText.String())),
+	}
+	patch.Agents = append(agentsInRanges(cg, aOnly), agentsInRanges(cg, bOnly)...)
+	patch.Agents = sortAgents(patch.Agents)
+
+	for i := range patch.Hunks {
+		h := &patch.Hunks[i]
+		var agents []causalgraph.AgentID
+		for _, c := range changes {
+			start, n := h.OldStart, h.OldLines
+			if c.added {
+				start, n = h.NewStart, h.NewLines
+			}
+			if n > 0 && c.line >= start-1 && c.line < start-1+n {
+				agents = append(agents, c.agents...)
+			}
+		}
+		h.Agents = sortAgents(agents)
+	}
+	return patch, nil
+}
+
+// lvRangesContain reports whether lv lies in one of the sorted, disjoint ranges.
+func lvRangesContain(ranges []causalgraph.LVRange, lv causalgraph.LV) bool {
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i].End > lv })
+	return i < len(ranges) && ranges[i].Start <= lv
+}
+
+// sortAgents sorts agents and removes duplicates.
+func sortAgents(agents []causalgraph.AgentID) []causalgraph.AgentID {
+	slices.Sort(agents)
+	return slices.Compact(agents)
+}
+
+// agentsInRanges returns the agents of the operations in ranges, unsorted and
+// possibly repeated.
+func agentsInRanges(cg *causalgraph.CausalGraph, ranges []causalgraph.LVRange) []causalgraph.AgentID {
+	var agents []causalgraph.AgentID
+	for _, r := range ranges {
+		i := sort.Search(len(cg.Entries), func(i int) bool { return cg.Entries[i].VEnd > r.Start })
+		for ; i < len(cg.Entries) && cg.Entries[i].Version < r.End; i++ {
+			agents = append(agents, cg.Entries[i].Agent)
+		}
+	}
+	return agents
+}
+
+// splitLines splits s into lines, keeping each line's trailing newline.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// buildHunks groups a line diff of oldLines and newLines into hunks, merging
+// changes separated by no more than twice the context length.
+func buildHunks(oldLines, newLines []string) []Hunk {
+	type lineEdit struct {
+		kind       PatchLineKind
+		oldI, newI int
+	}
+	var edits []lineEdit
+	for _, run := range myersDiff(oldLines, newLines) {
+		for i := 0; i < run.Len; i++ {
+			switch run.Kind {
+			case diffEqual:
+				edits = append(edits, lineEdit{PatchLineContext, run.AStart + i, run.BStart + i})
+			case diffDelete:
+				edits = append(edits, lineEdit{PatchLineRemoved, run.AStart + i, run.BStart})
+			case diffInsert:
+				edits = append(edits, lineEdit{PatchLineAdded, run.AStart, run.BStart + i})
+			}
+		}
+	}
+
+	var hunks []Hunk
+	for i := 0; i < len(edits); {
+		if edits[i].kind == PatchLineContext {
+			i++
+			continue
+		}
+		start := max(0, i-patchContext)
+		end := i
+		for j := i; j < len(edits) && j < end+2*patchContext+1; j++ {
+			if edits[j].kind != PatchLineContext {
+				end = j + 1
+			}
+		}
+		end = min(len(edits), end+patchContext)
+
+		h := Hunk{OldStart: edits[start].oldI + 1, NewStart: edits[start].newI + 1}
+		for _, e := range edits[start:end] {
+			switch e.kind {
+			case PatchLineContext:
+				h.Lines = append(h.Lines, PatchLine{e.kind, oldLines[e.oldI]})
+				h.OldLines++
+				h.NewLines++
+			case PatchLineRemoved:
+				h.Lines = append(h.Lines, PatchLine{e.kind, oldLines[e.oldI]})
+				h.OldLines++
+			case PatchLineAdded:
+				h.Lines = append(h.Lines, PatchLine{e.kind, newLines[e.newI]})
+				h.NewLines++
+			}
+		}
+		if h.OldLines == 0 {
+			h.OldStart--
+		}
+		if h.NewLines == 0 {
+			h.NewStart--
+		}
+		hunks = append(hunks, h)
+		i = end
+	}
+	return hunks
+}
+
+// Unified formats the patch as a unified diff with the given file names.
+// It returns the empty string if the versions have the same text.
+func (p *Patch) Unified(oldName, newName string) string {
+	if len(p.Hunks) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range p.Hunks {
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+		for _, line := range h.Lines {
+			b.WriteByte(byte(line.Kind))
+			b.WriteString(line.Text)
+			if !strings.HasSuffix(line.Text, "\n") {
+				b.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// hunkRange formats a hunk's line range, omitting a count of one as diff does.
+func hunkRange(start, n int) string {
+	if n == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, n)
+}
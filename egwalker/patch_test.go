@@ -0,0 +1,100 @@
+package egwalker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+func TestTextDoc_Diff_Unified(t *testing.T) {
+	d := NewTextDoc()
+	if _, err := d.Insert("alice", 0, "one\ntwo\nthree\n"); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	from := d.Version()
+	if err := d.SetText("alice", "one\n2\nthree\nfour"); err != nil {
+		t.Fatalf("SetText failed: %v", err)
+	}
+
+	patch, err := d.Diff(from, d.Version())
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	want := "--- a\n+++ b\n@@ -1,3 +1,4 @@\n one\n-two\n+2\n three\n+four\n\\ No newline at end of file\n"
+	if got := patch.Unified("a", "b"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if got, want := patch.Agents, []causalgraph.AgentID{"alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("patch agents: got %v, want %v", got, want)
+	}
+
+	same, err := d.Diff(d.Version(), d.Version())
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(same.Hunks) != 0 || same.Unified("a", "b") != "" || len(same.Agents) != 0 {
+		t.Errorf("expected empty patch for identical versions, got %+v", same)
+	}
+	if _, err := d.Diff([]causalgraph.LV{1000}, d.Version()); err == nil {
+		t.Error("expected error for unknown version")
+	}
+}
+
+func TestTextDoc_Diff_Attribution(t *testing.T) {
+	lines := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	a := NewTextDoc()
+	if _, err := a.Insert("alice", 0, lines); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	base := a.Version()
+	b := NewTextDoc()
+	syncTextDocs(t, b, a)
+
+	// Alice edits near the top and Bob near the bottom, concurrently.
+	if err := a.SetText("alice", "1\nTWO\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"); err != nil {
+		t.Fatalf("SetText failed: %v", err)
+	}
+	if err := b.SetText("bob", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n12\n"); err != nil {
+		t.Fatalf("SetText failed: %v", err)
+	}
+	aliceVersion, bobVersion := a.Version(), b.Version()
+	syncTextDocs(t, a, b)
+
+	patch, err := a.Diff(base, a.Version())
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(patch.Hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %d:\n%s", len(patch.Hunks), patch.Unified("a", "b"))
+	}
+	if got, want := patch.Hunks[0].Agents, []causalgraph.AgentID{"alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("first hunk agents: got %v, want %v", got, want)
+	}
+	if got, want := patch.Hunks[1].Agents, []causalgraph.AgentID{"bob"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("second hunk agents: got %v, want %v", got, want)
+	}
+	if h := patch.Hunks[1]; h.OldStart != 8 || h.OldLines != 5 || h.NewStart != 8 || h.NewLines != 4 {
+		t.Errorf("second hunk range: got -%d,%d +%d,%d", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+	}
+	if got, want := patch.Agents, []causalgraph.AgentID{"alice", "bob"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("patch agents: got %v, want %v", got, want)
+	}
+
+	// Diffing between the two concurrent branches shows each side's change.
+	raw, err := causalgraph.LVToRawList(b.GetCG(), bobVersion)
+	if err != nil {
+		t.Fatalf("LVToRawList failed: %v", err)
+	}
+	bobLV, err := causalgraph.RawToLV(a.GetCG(), raw[0].Agent, raw[0].Seq)
+	if err != nil {
+		t.Fatalf("RawToLV failed: %v", err)
+	}
+	cross, err := a.Diff(aliceVersion, []causalgraph.LV{bobLV})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(cross.Hunks) != 2 {
+		t.Errorf("expected 2 hunks between branches, got %d", len(cross.Hunks))
+	}
+}
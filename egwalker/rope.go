@@ -0,0 +1,309 @@
+package egwalker
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+// ropeChunkSize is the maximum number of elements stored in a single rope node.
+const ropeChunkSize = 32
+
+// Rope is an immutable sequence of elements.
+// Modifying a Rope returns a new Rope which shares all unchanged nodes with the
+// original, so old Ropes stay valid and cheap to keep around. The zero value is
+// an empty Rope. Ropes are safe for concurrent use by multiple goroutines.
+type Rope[T any] struct {
+	root *ropeNode[T]
+	// weigh measures elements for the dims cached in each node. It is nil for
+	// ropes which only track their length.
+	weigh ropeWeigher[T]
+}
+
+// ropeNumDims is the number of additional measures a rope can cache per subtree.
+const ropeNumDims = 3
+
+// ropeDims holds additive measures of a run of elements, such as its length
+// in UTF-8 bytes.
+type ropeDims [ropeNumDims]int
+
+func (a ropeDims) add(b ropeDims) ropeDims {
+	for i := range a {
+		a[i] += b[i]
+	}
+	return a
+}
+
+// ropeWeigher returns the measures of a single element.
+type ropeWeigher[T any] func(v T) ropeDims
+
+// ropeNode is a node of a treap ordered by position, where each node holds a
+// chunk of consecutive elements. Nodes are never modified once created.
+type ropeNode[T any] struct {
+	left, right *ropeNode[T]
+	items       []T
+	size        int      // Number of elements in this subtree.
+	dims        ropeDims // Measures of this subtree, if the rope has a weigher.
+	prio        uint32
+}
+
+func newRopeNode[T any](w ropeWeigher[T], items []T, left, right *ropeNode[T], prio uint32) *ropeNode[T] {
+	n := &ropeNode[T]{
+		left:  left,
+		right: right,
+		items: items,
+		size:  ropeSize(left) + len(items) + ropeSize(right),
+		prio:  prio,
+	}
+	if w != nil {
+		n.dims = ropeDimsOf(left).add(ropeDimsOf(right))
+		for _, v := range items {
+			n.dims = n.dims.add(w(v))
+		}
+	}
+	return n
+}
+
+func ropeDimsOf[T any](n *ropeNode[T]) ropeDims {
+	if n == nil {
+		return ropeDims{}
+	}
+	return n.dims
+}
+
+func ropeSize[T any](n *ropeNode[T]) int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+// RopeFromSlice returns a Rope holding a copy of items.
+func RopeFromSlice[T any](items []T) Rope[T] {
+	return Rope[T]{}.appendSlice(items)
+}
+
+// appendSlice returns a new rope with a copy of items appended.
+func (r Rope[T]) appendSlice(items []T) Rope[T] {
+	root := r.root
+	for start := 0; start < len(items); start += ropeChunkSize {
+		end := min(start+ropeChunkSize, len(items))
+		chunk := append([]T(nil), items[start:end]...)
+		root = ropeMerge(r.weigh, root, newRopeNode(r.weigh, chunk, nil, nil, rand.Uint32()))
+	}
+	return Rope[T]{root: root, weigh: r.weigh}
+}
+
+// Len returns the number of elements in the rope.
+func (r Rope[T]) Len() int {
+	return ropeSize(r.root)
+}
+
+// At returns the element at index i. It panics if i is out of range.
+func (r Rope[T]) At(i int) T {
+	if i < 0 || i >= r.Len() {
+		panic(fmt.Sprintf("rope: index %d out of range for length %d", i, r.Len()))
+	}
+	n := r.root
+	for {
+		ls := ropeSize(n.left)
+		switch {
+		case i < ls:
+			n = n.left
+		case i < ls+len(n.items):
+			return n.items[i-ls]
+		default:
+			i -= ls + len(n.items)
+			n = n.right
+		}
+	}
+}
+
+// Each calls fn for every element in order, stopping early if fn returns false.
+func (r Rope[T]) Each(fn func(i int, v T) bool) {
+	i := 0
+	var walk func(n *ropeNode[T]) bool
+	walk = func(n *ropeNode[T]) bool {
+		if n == nil {
+			return true
+		}
+		if !walk(n.left) {
+			return false
+		}
+		for _, v := range n.items {
+			if !fn(i, v) {
+				return false
+			}
+			i++
+		}
+		return walk(n.right)
+	}
+	walk(r.root)
+}
+
+// Slice returns a copy of the rope's elements.
+func (r Rope[T]) Slice() []T {
+	out := make([]T, 0, r.Len())
+	r.Each(func(_ int, v T) bool {
+		out = append(out, v)
+		return true
+	})
+	return out
+}
+
+// Insert returns a new rope with v inserted at pos. It panics if pos is out of range.
+func (r Rope[T]) Insert(pos int, v T) Rope[T] {
+	if pos < 0 || pos > r.Len() {
+		panic(fmt.Sprintf("rope: insert position %d out of range for length %d", pos, r.Len()))
+	}
+	if root, ok := ropeInsertInChunk(r.weigh, r.root, pos, v); ok {
+		return Rope[T]{root: root, weigh: r.weigh}
+	}
+	left, right := ropeSplit(r.weigh, r.root, pos)
+	leaf := newRopeNode(r.weigh, []T{v}, nil, nil, rand.Uint32())
+	return Rope[T]{root: ropeMerge(r.weigh, ropeMerge(r.weigh, left, leaf), right), weigh: r.weigh}
+}
+
+// Delete returns a new rope with the element at pos removed. It panics if pos is out of range.
+func (r Rope[T]) Delete(pos int) Rope[T] {
+	if pos < 0 || pos >= r.Len() {
+		panic(fmt.Sprintf("rope: delete position %d out of range for length %d", pos, r.Len()))
+	}
+	return Rope[T]{root: ropeDelete(r.weigh, r.root, pos), weigh: r.weigh}
+}
+
+// ropeInsertInChunk inserts v into the chunk containing pos, copying the path
+// from the root. It returns false without allocating if that chunk is full.
+func ropeInsertInChunk[T any](w ropeWeigher[T], n *ropeNode[T], pos int, v T) (*ropeNode[T], bool) {
+	if n == nil {
+		return nil, false
+	}
+	ls := ropeSize(n.left)
+	switch {
+	case pos < ls:
+		left, ok := ropeInsertInChunk(w, n.left, pos, v)
+		if !ok {
+			return nil, false
+		}
+		return newRopeNode(w, n.items, left, n.right, n.prio), true
+	case pos > ls+len(n.items):
+		right, ok := ropeInsertInChunk(w, n.right, pos-ls-len(n.items), v)
+		if !ok {
+			return nil, false
+		}
+		return newRopeNode(w, n.items, n.left, right, n.prio), true
+	}
+	if len(n.items) >= ropeChunkSize {
+		return nil, false
+	}
+	c := pos - ls
+	items := make([]T, 0, len(n.items)+1)
+	items = append(items, n.items[:c]...)
+	items = append(items, v)
+	items = append(items, n.items[c:]...)
+	return newRopeNode(w, items, n.left, n.right, n.prio), true
+}
+
+func ropeDelete[T any](w ropeWeigher[T], n *ropeNode[T], pos int) *ropeNode[T] {
+	ls := ropeSize(n.left)
+	switch {
+	case pos < ls:
+		return newRopeNode(w, n.items, ropeDelete(w, n.left, pos), n.right, n.prio)
+	case pos >= ls+len(n.items):
+		return newRopeNode(w, n.items, n.left, ropeDelete(w, n.right, pos-ls-len(n.items)), n.prio)
+	}
+	if len(n.items) == 1 {
+		return ropeMerge(w, n.left, n.right)
+	}
+	c := pos - ls
+	items := make([]T, 0, len(n.items)-1)
+	items = append(items, n.items[:c]...)
+	items = append(items, n.items[c+1:]...)
+	return newRopeNode(w, items, n.left, n.right, n.prio)
+}
+
+// ropeSplit returns trees holding the first k elements of n and the remainder.
+func ropeSplit[T any](w ropeWeigher[T], n *ropeNode[T], k int) (*ropeNode[T], *ropeNode[T]) {
+	if n == nil {
+		return nil, nil
+	}
+	ls := ropeSize(n.left)
+	switch {
+	case k <= ls:
+		a, b := ropeSplit(w, n.left, k)
+		return a, newRopeNode(w, n.items, b, n.right, n.prio)
+	case k >= ls+len(n.items):
+		a, b := ropeSplit(w, n.right, k-ls-len(n.items))
+		return newRopeNode(w, n.items, n.left, a, n.prio), b
+	default:
+		c := k - ls
+		return newRopeNode(w, n.items[:c:c], n.left, nil, n.prio), newRopeNode(w, n.items[c:], nil, n.right, n.prio)
+	}
+}
+
+// ropeMerge concatenates two trees, keeping the treap heap order on priorities.
+func ropeMerge[T any](w ropeWeigher[T], a, b *ropeNode[T]) *ropeNode[T] {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.prio > b.prio {
+		return newRopeNode(w, a.items, a.left, ropeMerge(w, a.right, b), a.prio)
+	}
+	return newRopeNode(w, b.items, ropeMerge(w, a, b.left), b.right, b.prio)
+}
+
+// dimsBefore returns the sum of the measures of the elements before pos.
+func (r Rope[T]) dimsBefore(pos int) ropeDims {
+	var dims ropeDims
+	for n := r.root; n != nil; {
+		ls := ropeSize(n.left)
+		if pos < ls {
+			n = n.left
+			continue
+		}
+		dims = dims.add(ropeDimsOf(n.left))
+		pos -= ls
+		for i := 0; i < pos && i < len(n.items); i++ {
+			dims = dims.add(r.weigh(n.items[i]))
+		}
+		if pos <= len(n.items) {
+			break
+		}
+		pos -= len(n.items)
+		n = n.right
+	}
+	return dims
+}
+
+// posForDim returns the smallest position whose preceding elements measure at
+// least offset in dimension d, or Len() if the whole rope measures less.
+func (r Rope[T]) posForDim(d int, offset int) int {
+	pos := 0
+	for n := r.root; n != nil; {
+		if n.left != nil && offset <= n.left.dims[d] {
+			n = n.left
+			continue
+		}
+		offset -= ropeDimsOf(n.left)[d]
+		pos += ropeSize(n.left)
+		for _, v := range n.items {
+			if offset <= 0 {
+				return pos
+			}
+			offset -= r.weigh(v)[d]
+			pos++
+		}
+		if offset <= 0 {
+			return pos
+		}
+		n = n.right
+	}
+	return pos
+}
+
+// totalDims returns the measures of the whole rope.
+func (r Rope[T]) totalDims() ropeDims {
+	return ropeDimsOf(r.root)
+}
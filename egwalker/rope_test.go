@@ -0,0 +1,90 @@
+package egwalker
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestRope_RandomOpsMatchSlice(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	var rope Rope[int]
+	var want []int
+
+	for i := 0; i < 2000; i++ {
+		if len(want) > 0 && rng.IntN(3) == 0 {
+			pos := rng.IntN(len(want))
+			rope = rope.Delete(pos)
+			want = append(want[:pos], want[pos+1:]...)
+		} else {
+			pos := rng.IntN(len(want) + 1)
+			rope = rope.Insert(pos, i)
+			want = append(want[:pos], append([]int{i}, want[pos:]...)...)
+		}
+		if rope.Len() != len(want) {
+			t.Fatalf("step %d: Len() = %d, want %d", i, rope.Len(), len(want))
+		}
+	}
+	if got := rope.Slice(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Slice mismatch:\ngot:  %v\nwant: %v", got, want)
+	}
+	for i, v := range want {
+		if got := rope.At(i); got != v {
+			t.Fatalf("At(%d) = %d, want %d", i, got, v)
+		}
+	}
+}
+
+func TestRope_Persistence(t *testing.T) {
+	base := RopeFromSlice([]string{"a", "b", "c"})
+	inserted := base.Insert(1, "x")
+	deleted := inserted.Delete(0)
+
+	if got, want := base.Slice(), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("base modified: got %v, want %v", got, want)
+	}
+	if got, want := inserted.Slice(), []string{"a", "x", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("inserted: got %v, want %v", got, want)
+	}
+	if got, want := deleted.Slice(), []string{"x", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("deleted: got %v, want %v", got, want)
+	}
+
+	var empty Rope[string]
+	if empty.Len() != 0 || len(empty.Slice()) != 0 {
+		t.Errorf("zero Rope is not empty: %v", empty.Slice())
+	}
+}
+
+func TestRope_Each_StopsEarly(t *testing.T) {
+	rope := RopeFromSlice([]int{1, 2, 3, 4})
+	var seen []int
+	rope.Each(func(i int, v int) bool {
+		seen = append(seen, v)
+		return i < 1
+	})
+	if want := []int{1, 2}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("Each visited %v, want %v", seen, want)
+	}
+}
+
+func TestRope_ConcurrentReaders(t *testing.T) {
+	rope := RopeFromSlice(make([]int, 500))
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			// Every goroutine derives its own version from the shared one.
+			mine := rope
+			for i := 0; i < 100; i++ {
+				mine = mine.Insert(i, g)
+			}
+			if mine.Len() != 600 || rope.Len() != 500 {
+				t.Errorf("goroutine %d: got lengths %d/%d, want 600/500", g, mine.Len(), rope.Len())
+			}
+		}(g)
+	}
+	wg.Wait()
+}
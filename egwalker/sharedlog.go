@@ -0,0 +1,170 @@
+package egwalker
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// sharedLog stores the operations of one piece of data versioned by a causal
+// graph which other data may share. It only holds the LVs it created or
+// received; the rest of the graph belongs to the other data.
+type sharedLog[O any] struct {
+	cg  *causalgraph.CausalGraph
+	ops map[causalgraph.LV]O
+}
+
+func newSharedLog[O any](cg *causalgraph.CausalGraph) *sharedLog[O] {
+	return &sharedLog[O]{cg: cg, ops: make(map[causalgraph.LV]O)}
+}
+
+// local logs op by agent with the given parents, or the graph heads if
+// parents is nil.
+func (l *sharedLog[O]) local(agent string, parents []causalgraph.LV, op O) (causalgraph.LV, error) {
+	r, err := l.localSpan(agent, parents, []O{op})
+	if err != nil {
+		return -1, err
+	}
+	return r.Start, nil
+}
+
+// localSpan logs ops by agent as a single span with the given parents, or
+// the graph heads if parents is nil, and returns the LVs assigned to them.
+func (l *sharedLog[O]) localSpan(agent string, parents []causalgraph.LV, ops []O) (causalgraph.LVRange, error) {
+	if parents == nil {
+		parents = l.cg.Heads
+	}
+	rawParents, err := causalgraph.LVToRawList(l.cg, parents)
+	if err != nil {
+		return causalgraph.LVRange{}, fmt.Errorf("failed to convert parents to raw versions: %w", err)
+	}
+	if rawParents == nil {
+		rawParents = []causalgraph.RawVersion{}
+	}
+	cgAgentID := causalgraph.AgentID(agent)
+	id := causalgraph.RawVersion{Agent: cgAgentID, Seq: causalgraph.NextSeqForAgent(l.cg, cgAgentID)}
+	entry, err := causalgraph.AddRaw(l.cg, id, len(ops), rawParents)
+	if err != nil {
+		return causalgraph.LVRange{}, fmt.Errorf("failed to add to causal graph: %w", err)
+	}
+	for i, op := range ops {
+		l.ops[entry.Version+causalgraph.LV(i)] = op
+	}
+	return causalgraph.LVRange{Start: entry.Version, End: entry.VEnd}, nil
+}
+
+// applyRemote logs a span received from another peer, skipping operations
+// the graph already knows. It returns the range of LVs assigned to the new
+// operations, which is empty if there were none.
+func (l *sharedLog[O]) applyRemote(id causalgraph.RawVersion, parents []causalgraph.RawVersion, ops []O) (causalgraph.LVRange, error) {
+	empty := causalgraph.LVRange{Start: l.cg.NextLV, End: l.cg.NextLV}
+	if len(ops) == 0 {
+		return empty, nil
+	}
+	if parents == nil {
+		// A nil slice would make AddRaw use our own heads.
+		parents = []causalgraph.RawVersion{}
+	}
+	if next := causalgraph.NextSeqForAgent(l.cg, id.Agent); id.Seq < next {
+		known := next - id.Seq
+		if known >= len(ops) {
+			return empty, nil
+		}
+		ops = ops[known:]
+		parents = []causalgraph.RawVersion{{Agent: id.Agent, Seq: next - 1}}
+		id.Seq = next
+	}
+	entry, err := causalgraph.AddRaw(l.cg, id, len(ops), parents)
+	if err != nil {
+		return empty, fmt.Errorf("failed to add %s:%d to causal graph: %w", id.Agent, id.Seq, err)
+	}
+	for i, op := range ops {
+		l.ops[entry.Version+causalgraph.LV(i)] = op
+	}
+	return causalgraph.LVRange{Start: entry.Version, End: entry.VEnd}, nil
+}
+
+// integrateRemote logs a span as applyRemote does and calls apply with each
+// new LV in turn. If apply fails, for instance on an operation at a position
+// outside its list, the span is removed from the log and the graph, and
+// rebuild is called to recompute from the log whatever apply changed.
+func (l *sharedLog[O]) integrateRemote(id causalgraph.RawVersion, parents []causalgraph.RawVersion, ops []O, apply func(lv causalgraph.LV) error, rebuild func() error) error {
+	var r causalgraph.LVRange
+	return rollbackOnError(l.cg, id.Agent, func() error {
+		var err error
+		if r, err = l.applyRemote(id, parents, ops); err != nil {
+			return err
+		}
+		for lv := r.Start; lv < r.End; lv++ {
+			if err := apply(lv); err != nil {
+				return fmt.Errorf("LV %d: %w", lv, err)
+			}
+		}
+		return nil
+	}, func() error {
+		for lv := r.Start; lv < r.End; lv++ {
+			delete(l.ops, lv)
+		}
+		return rebuild()
+	})
+}
+
+// lvs returns the LVs of the operations in the log, in increasing order.
+func (l *sharedLog[O]) lvs() []causalgraph.LV {
+	lvs := make([]causalgraph.LV, 0, len(l.ops))
+	for lv := range l.ops {
+		lvs = append(lvs, lv)
+	}
+	slices.Sort(lvs)
+	return lvs
+}
+
+// eachSpan calls fn with each entry of the graph holding this log's
+// operations, in LV order, in the form exchanged between peers.
+func (l *sharedLog[O]) eachSpan(fn func(id causalgraph.RawVersion, parents []causalgraph.RawVersion, ops []O)) error {
+	for _, entry := range l.cg.Entries {
+		if _, ok := l.ops[entry.Version]; !ok {
+			continue
+		}
+		parents, err := causalgraph.LVToRawList(l.cg, entry.Parents)
+		if err != nil {
+			return err
+		}
+		if parents == nil {
+			parents = []causalgraph.RawVersion{}
+		}
+		ops := make([]O, 0, entry.VEnd-entry.Version)
+		for lv := entry.Version; lv < entry.VEnd; lv++ {
+			ops = append(ops, l.ops[lv])
+		}
+		fn(causalgraph.RawVersion{Agent: entry.Agent, Seq: entry.Seq}, parents, ops)
+	}
+	return nil
+}
+
+// history returns the LVs in the history of version.
+func (l *sharedLog[O]) history(version []causalgraph.LV) ([]causalgraph.LVRange, error) {
+	_, history, err := causalgraph.DiffVersions(l.cg, nil, version)
+	return history, err
+}
+
+// latest returns, ordered by agent and then sequence number, the LVs among
+// lvs which are in history and not ancestors of another of them.
+func (l *sharedLog[O]) latest(lvs []causalgraph.LV, history []causalgraph.LVRange) ([]causalgraph.LV, error) {
+	var in []causalgraph.LV
+	for _, lv := range lvs {
+		if lvRangesContain(history, lv) {
+			in = append(in, lv)
+		}
+	}
+	if len(in) == 0 {
+		return nil, nil
+	}
+	latest, err := causalgraph.FindMaximal(l.cg, in)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(latest, func(a, b causalgraph.LV) int { return lvCmp(l.cg, a, b) })
+	return latest, nil
+}
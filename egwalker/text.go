@@ -0,0 +1,404 @@
+package egwalker
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// TextOp is a run of consecutive text operations, each with its own LV.
+// An insert run inserts Content at Pos; a delete run deletes Len characters
+// starting at Pos. Positions and lengths count Unicode code points.
+type TextOp struct {
+	Type    ListOpType
+	Pos     int
+	Len     int    // Number of characters, and so of LVs, in the run.
+	Content string // Inserted text. Only used for insert runs.
+}
+
+// TextSpan is a run of text operations by a single agent, in the form
+// exchanged between peers. It is the text equivalent of RemoteSpan.
+type TextSpan struct {
+	ID      causalgraph.RawVersion
+	Parents []causalgraph.RawVersion // Parents of the first operation in the span.
+	Ops     []TextOp
+}
+
+// textRun is a TextOp stored in a TextOpLog, whose first operation has LV Start.
+type textRun struct {
+	Start causalgraph.LV
+	TextOp
+}
+
+// TextOpLog holds text operations packed into runs of UTF-8 text, rather than
+// one ListOp per character, along with their causal graph.
+type TextOpLog struct {
+	// runs are sorted by Start and cover every LV in CG without gaps.
+	runs []textRun
+	CG   causalgraph.CausalGraph
+}
+
+// TextDoc is a collaborative plain-text document.
+// It uses the same merge engine as Walker, with one LV per Unicode code point,
+// but stores its operations as packed runs of text.
+type TextDoc struct {
+	Log *TextOpLog
+	Ctx *EditContext
+	// content is the current text, updated incrementally as ops are applied.
+	// It caches the length of every subtree in each Unit.
+	content Rope[rune]
+}
+
+// NewTextDoc creates a new, empty TextDoc.
+func NewTextDoc() *TextDoc {
+	return &TextDoc{
+		Log:     &TextOpLog{CG: *causalgraph.CreateCG()},
+		Ctx:     newEditCtx(),
+		content: newTextRope(),
+	}
+}
+
+// validate checks that op is a well-formed, non-empty run.
+func (op TextOp) validate() error {
+	switch op.Type {
+	case ListOpTypeInsert:
+		if n := utf8.RuneCountInString(op.Content); n != op.Len || n == 0 {
+			return fmt.Errorf("%w: insert run has length %d but content %q has %d characters", ErrInvalidOp, op.Len, op.Content, n)
+		}
+	case ListOpTypeDelete:
+		if op.Len <= 0 {
+			return fmt.Errorf("%w: delete run length must be positive, got %d", ErrInvalidOp, op.Len)
+		}
+	default:
+		return fmt.Errorf("%w: unknown op type %q", ErrInvalidOp, op.Type)
+	}
+	if op.Pos < 0 {
+		return fmt.Errorf("%w: run position cannot be negative: %d", ErrInvalidOp, op.Pos)
+	}
+	return nil
+}
+
+// at returns the single-character operation at offset i within the run.
+// content must hold the run's Content starting at the character at offset i.
+func (op TextOp) at(i int, content string) ListOp[rune] {
+	if op.Type == ListOpTypeInsert {
+		r, _ := utf8.DecodeRuneInString(content)
+		return ListOp[rune]{Type: ListOpTypeInsert, Pos: op.Pos + i, Content: r}
+	}
+	return ListOp[rune]{Type: ListOpTypeDelete, Pos: op.Pos}
+}
+
+// slice returns the part of the run covering offsets [from, to).
+func (op TextOp) slice(from, to int) TextOp {
+	if op.Type == ListOpTypeInsert {
+		return TextOp{
+			Type:    ListOpTypeInsert,
+			Pos:     op.Pos + from,
+			Len:     to - from,
+			Content: runeSubstring(op.Content, from, to),
+		}
+	}
+	return TextOp{Type: ListOpTypeDelete, Pos: op.Pos, Len: to - from}
+}
+
+// runeSubstring returns the code points [from, to) of s.
+func runeSubstring(s string, from, to int) string {
+	start, i := len(s), 0
+	for byteIdx := range s {
+		if i == from {
+			start = byteIdx
+		}
+		if i == to {
+			return s[start:byteIdx]
+		}
+		i++
+	}
+	return s[start:]
+}
+
+// Ops returns a copy of the operation log as runs, in LV order.
+func (l *TextOpLog) Ops() []TextOp {
+	ops := make([]TextOp, len(l.runs))
+	for i, run := range l.runs {
+		ops[i] = run.TextOp
+	}
+	return ops
+}
+
+// append adds op to the log as the operations starting at LV start, extending
+// the last run when op continues it.
+func (l *TextOpLog) append(start causalgraph.LV, op TextOp) {
+	if n := len(l.runs); n > 0 {
+		last := &l.runs[n-1]
+		if last.Start+causalgraph.LV(last.Len) == start && last.Type == op.Type {
+			switch {
+			case op.Type == ListOpTypeInsert && op.Pos == last.Pos+last.Len:
+				last.Content += op.Content
+				last.Len += op.Len
+				return
+			case op.Type == ListOpTypeDelete && op.Pos == last.Pos:
+				last.Len += op.Len
+				return
+			}
+		}
+	}
+	l.runs = append(l.runs, textRun{Start: start, TextOp: op})
+}
+
+// findRun returns the index of the run containing lv.
+func (l *TextOpLog) findRun(lv causalgraph.LV) int {
+	return sort.Search(len(l.runs), func(i int) bool {
+		return l.runs[i].Start+causalgraph.LV(l.runs[i].Len) > lv
+	})
+}
+
+// eachOp is the opIter for the operations stored in a TextOpLog. LVs not
+// covered by a run belong to other data sharing the causal graph and are
+// skipped.
+func (l *TextOpLog) eachOp(start, end causalgraph.LV, fn func(lv causalgraph.LV, op ListOp[rune]) error) error {
+	for idx := l.findRun(start); idx < len(l.runs) && l.runs[idx].Start < end; idx++ {
+		run := l.runs[idx]
+		content := run.Content
+		for i := 0; i < run.Len; i++ {
+			lv := run.Start + causalgraph.LV(i)
+			if lv >= end {
+				break
+			}
+			if lv >= start {
+				if err := fn(lv, run.at(i, content)); err != nil {
+					return err
+				}
+			}
+			if run.Type == ListOpTypeInsert {
+				_, size := utf8.DecodeRuneInString(content)
+				content = content[size:]
+			}
+		}
+	}
+	return nil
+}
+
+// slice returns the runs covering LVs [start, end).
+func (l *TextOpLog) slice(start, end causalgraph.LV) []TextOp {
+	var ops []TextOp
+	for idx := l.findRun(start); idx < len(l.runs) && l.runs[idx].Start < end; idx++ {
+		run := l.runs[idx]
+		from := int(max(start-run.Start, 0))
+		to := int(min(end-run.Start, causalgraph.LV(run.Len)))
+		ops = append(ops, run.slice(from, to))
+	}
+	return ops
+}
+
+// Insert inserts text at pos (in code points) on behalf of agent, and returns
+// the LVs assigned to the inserted characters.
+func (d *TextDoc) Insert(agent string, pos int, text string) (causalgraph.LVRange, error) {
+	n := utf8.RuneCountInString(text)
+	if n == 0 {
+		return causalgraph.LVRange{Start: d.Log.CG.NextLV, End: d.Log.CG.NextLV}, nil
+	}
+	if pos < 0 || pos > d.Len() {
+		return causalgraph.LVRange{}, fmt.Errorf("insert: %w", &ErrPosOutOfRange{Pos: pos, Len: d.Len()})
+	}
+	return d.local(agent, TextOp{Type: ListOpTypeInsert, Pos: pos, Len: n, Content: text})
+}
+
+// Delete deletes length code points starting at pos on behalf of agent, and
+// returns the LVs assigned to the delete operations.
+func (d *TextDoc) Delete(agent string, pos, length int) (causalgraph.LVRange, error) {
+	if length == 0 {
+		return causalgraph.LVRange{Start: d.Log.CG.NextLV, End: d.Log.CG.NextLV}, nil
+	}
+	if pos < 0 || length < 0 || pos+length > d.Len() {
+		return causalgraph.LVRange{}, fmt.Errorf("delete: range [%d, %d): %w", pos, pos+length, &ErrPosOutOfRange{Pos: outOfRange(pos, length), Len: d.Len()})
+	}
+	return d.local(agent, TextOp{Type: ListOpTypeDelete, Pos: pos, Len: length})
+}
+
+// local adds validated local runs to the log as a single span and applies
+// them.
+func (d *TextDoc) local(agent string, ops ...TextOp) (causalgraph.LVRange, error) {
+	parents, err := causalgraph.LVToRawList(&d.Log.CG, d.Log.CG.Heads)
+	if err != nil {
+		return causalgraph.LVRange{}, fmt.Errorf("failed to convert current version to raw parents: %w", err)
+	}
+	if parents == nil {
+		parents = []causalgraph.RawVersion{}
+	}
+	cgAgentID := causalgraph.AgentID(agent)
+	id := causalgraph.RawVersion{Agent: cgAgentID, Seq: causalgraph.NextSeqForAgent(&d.Log.CG, cgAgentID)}
+	total := 0
+	for _, op := range ops {
+		total += op.Len
+	}
+	return d.addSpan(id, parents, ops, total)
+}
+
+// addSpan logs ops, which hold total operations, as a span with the given ID
+// and parents and applies them. If anything fails part way, the log, causal
+// graph and context are restored to their state before the call.
+func (d *TextDoc) addSpan(id causalgraph.RawVersion, parents []causalgraph.RawVersion, ops []TextOp, total int) (causalgraph.LVRange, error) {
+	cg := &d.Log.CG
+	// append may extend the last run in place.
+	oldRuns, oldContent := len(d.Log.runs), d.content
+	var oldLast textRun
+	if oldRuns > 0 {
+		oldLast = d.Log.runs[oldRuns-1]
+	}
+	var r causalgraph.LVRange
+	err := rollbackOnError(cg, id.Agent, func() error {
+		entry, err := causalgraph.AddRaw(cg, id, total, parents)
+		if err != nil {
+			return fmt.Errorf("failed to add %s:%d to causal graph: %w", id.Agent, id.Seq, err)
+		}
+		r = causalgraph.LVRange{Start: entry.Version, End: entry.VEnd}
+		lv := r.Start
+		for _, op := range ops {
+			d.Log.append(lv, op)
+			lv += causalgraph.LV(op.Len)
+		}
+		return d.apply(r)
+	}, func() error {
+		d.Log.runs = d.Log.runs[:oldRuns]
+		if oldRuns > 0 {
+			d.Log.runs[oldRuns-1] = oldLast
+		}
+		d.content = oldContent
+		ctx, err := replay(cg, cg.Heads, d.Log.eachOp, nil)
+		if err != nil {
+			return err
+		}
+		d.Ctx = ctx
+		return nil
+	})
+	return r, err
+}
+
+// apply integrates the logged operations in r and moves the context back to
+// the graph heads.
+func (d *TextDoc) apply(r causalgraph.LVRange) error {
+	err := d.Log.eachOp(r.Start, r.End, func(lv causalgraph.LV, op ListOp[rune]) error {
+		endPos, err := integrateOp(d.Ctx, &d.Log.CG, lv, op)
+		if err != nil {
+			return err
+		}
+		d.content = applyToRope(d.content, op, endPos)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("ops integrated (LVs %d-%d) but failed to apply to context: %w", r.Start, r.End, err)
+	}
+	if err := d.Ctx.moveTo(&d.Log.CG, d.Log.CG.Heads); err != nil {
+		return fmt.Errorf("ops integrated (LVs %d-%d) but failed to move context to heads: %w", r.Start, r.End, err)
+	}
+	return nil
+}
+
+// ApplyRemote integrates a span of text operations received from another peer.
+// Operations the document already knows are skipped.
+func (d *TextDoc) ApplyRemote(span TextSpan) error {
+	id, parents := span.ID, span.Parents
+	total := 0
+	for _, op := range span.Ops {
+		if err := op.validate(); err != nil {
+			return fmt.Errorf("applyRemote: %w", err)
+		}
+		total += op.Len
+	}
+	if total == 0 {
+		return nil
+	}
+	if parents == nil {
+		// A nil slice would make AddRaw use our own heads.
+		parents = []causalgraph.RawVersion{}
+	}
+
+	ops := span.Ops
+	if next := causalgraph.NextSeqForAgent(&d.Log.CG, id.Agent); id.Seq < next {
+		known := next - id.Seq
+		if known >= total {
+			return nil
+		}
+		ops = sliceTextOps(ops, known, total)
+		parents = []causalgraph.RawVersion{{Agent: id.Agent, Seq: next - 1}}
+		id.Seq = next
+		total -= known
+	}
+
+	if _, err := d.addSpan(id, parents, ops, total); err != nil {
+		return fmt.Errorf("applyRemote: %w", err)
+	}
+	return nil
+}
+
+// sliceTextOps returns the part of ops covering offsets [from, to), where
+// offsets count operations (characters) across all runs.
+func sliceTextOps(ops []TextOp, from, to int) []TextOp {
+	var out []TextOp
+	offset := 0
+	for _, op := range ops {
+		start, end := offset, offset+op.Len
+		offset = end
+		if end <= from || start >= to {
+			continue
+		}
+		out = append(out, op.slice(max(from-start, 0), min(to, end)-start))
+	}
+	return out
+}
+
+// Spans returns the document's whole history as spans which can be passed to
+// ApplyRemote on another TextDoc.
+func (d *TextDoc) Spans() ([]TextSpan, error) {
+	spans := make([]TextSpan, 0, len(d.Log.CG.Entries))
+	for _, entry := range d.Log.CG.Entries {
+		parents, err := causalgraph.LVToRawList(&d.Log.CG, entry.Parents)
+		if err != nil {
+			return nil, fmt.Errorf("spans: %w", err)
+		}
+		if parents == nil {
+			parents = []causalgraph.RawVersion{}
+		}
+		ops := d.Log.slice(entry.Version, entry.VEnd)
+		if len(ops) == 0 {
+			continue // The entry belongs to other data sharing the causal graph.
+		}
+		spans = append(spans, TextSpan{
+			ID:      causalgraph.RawVersion{Agent: entry.Agent, Seq: entry.Seq},
+			Parents: parents,
+			Ops:     ops,
+		})
+	}
+	return spans, nil
+}
+
+// Checkout returns the text of the document at version.
+func (d *TextDoc) Checkout(version []causalgraph.LV) (string, error) {
+	var content Rope[rune]
+	if _, err := replay(&d.Log.CG, version, d.Log.eachOp, &content); err != nil {
+		return "", fmt.Errorf("checkout: failed to replay to version %v: %w", version, err)
+	}
+	return string(content.Slice()), nil
+}
+
+// String returns the current text of the document.
+func (d *TextDoc) String() string {
+	return string(d.content.Slice())
+}
+
+// Len returns the length of the current text in code points.
+func (d *TextDoc) Len() int {
+	return d.content.Len()
+}
+
+// Version returns the current version (frontier) of the document.
+func (d *TextDoc) Version() []causalgraph.LV {
+	return append([]causalgraph.LV{}, d.Ctx.CurVersion...)
+}
+
+// GetCG returns a pointer to the causal graph.
+func (d *TextDoc) GetCG() *causalgraph.CausalGraph {
+	return &d.Log.CG
+}
@@ -0,0 +1,223 @@
+package egwalker
+
+import (
+	"errors"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// syncTextDocs delivers every span known to src to dst.
+func syncTextDocs(t *testing.T, dst, src *TextDoc) {
+	t.Helper()
+	spans, err := src.Spans()
+	if err != nil {
+		t.Fatalf("Spans failed: %v", err)
+	}
+	for _, span := range spans {
+		if err := dst.ApplyRemote(span); err != nil {
+			t.Fatalf("ApplyRemote(%+v) failed: %v", span.ID, err)
+		}
+	}
+}
+
+func TestTextDoc_InsertDelete(t *testing.T) {
+	doc := NewTextDoc()
+	r, err := doc.Insert("alice", 0, "héllo wörld")
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if want := (causalgraph.LVRange{Start: 0, End: 11}); r != want {
+		t.Errorf("Insert range: got %v, want %v", r, want)
+	}
+	if _, err := doc.Delete("alice", 5, 6); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := doc.Insert("alice", 5, "!"); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	if got, want := doc.String(), "héllo!"; got != want {
+		t.Errorf("String: got %q, want %q", got, want)
+	}
+	if doc.Len() != 6 {
+		t.Errorf("Len: got %d, want 6", doc.Len())
+	}
+	want := []TextOp{
+		{Type: ListOpTypeInsert, Pos: 0, Len: 11, Content: "héllo wörld"},
+		{Type: ListOpTypeDelete, Pos: 5, Len: 6},
+		{Type: ListOpTypeInsert, Pos: 5, Len: 1, Content: "!"},
+	}
+	if got := doc.Log.Ops(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Ops:\ngot:  %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestTextDoc_RunsAreMerged(t *testing.T) {
+	doc := NewTextDoc()
+	for i, s := range []string{"a", "b", "c"} {
+		doc.Insert("alice", i, s)
+	}
+	doc.Delete("alice", 0, 1)
+	doc.Delete("alice", 0, 1)
+
+	want := []TextOp{
+		{Type: ListOpTypeInsert, Pos: 0, Len: 3, Content: "abc"},
+		{Type: ListOpTypeDelete, Pos: 0, Len: 2},
+	}
+	if got := doc.Log.Ops(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Ops:\ngot:  %+v\nwant: %+v", got, want)
+	}
+	if got := doc.String(); got != "c" {
+		t.Errorf("String: got %q, want %q", got, "c")
+	}
+}
+
+func TestTextDoc_OutOfBounds(t *testing.T) {
+	doc := NewTextDoc()
+	doc.Insert("alice", 0, "abc")
+	if _, err := doc.Insert("alice", 4, "x"); err == nil {
+		t.Error("expected error inserting past the end")
+	}
+	if _, err := doc.Delete("alice", 2, 2); err == nil {
+		t.Error("expected error deleting past the end")
+	}
+	if doc.GetCG().NextLV != 3 {
+		t.Errorf("rejected ops must not be logged: NextLV %d", doc.GetCG().NextLV)
+	}
+}
+
+func TestTextDoc_Checkout(t *testing.T) {
+	doc := NewTextDoc()
+	doc.Insert("alice", 0, "hello")
+	v1 := doc.Version()
+	doc.Delete("alice", 0, 1)
+	doc.Insert("alice", 0, "J")
+
+	got, err := doc.Checkout(v1)
+	if err != nil {
+		t.Fatalf("Checkout failed: %v", err)
+	}
+	if got != "hello" {
+		t.Errorf("Checkout(v1): got %q, want %q", got, "hello")
+	}
+	got, err = doc.Checkout([]causalgraph.LV{2})
+	if err != nil {
+		t.Fatalf("Checkout failed: %v", err)
+	}
+	if got != "hel" {
+		t.Errorf("Checkout([2]): got %q, want %q", got, "hel")
+	}
+	if got := doc.String(); got != "Jello" {
+		t.Errorf("String: got %q, want %q", got, "Jello")
+	}
+}
+
+func TestTextDoc_ApplyRemote_PartialOverlap(t *testing.T) {
+	a := NewTextDoc()
+	a.Insert("alice", 0, "abc")
+	b := NewTextDoc()
+	syncTextDocs(t, b, a)
+
+	// A span which repeats known characters before new ones.
+	span := TextSpan{
+		ID:      causalgraph.RawVersion{Agent: "alice", Seq: 1},
+		Parents: []causalgraph.RawVersion{{Agent: "alice", Seq: 0}},
+		Ops:     []TextOp{{Type: ListOpTypeInsert, Pos: 1, Len: 4, Content: "bcde"}},
+	}
+	if err := b.ApplyRemote(span); err != nil {
+		t.Fatalf("ApplyRemote failed: %v", err)
+	}
+	if got := b.String(); got != "abcde" {
+		t.Errorf("String: got %q, want %q", got, "abcde")
+	}
+
+	bad := TextSpan{
+		ID:  causalgraph.RawVersion{Agent: "carol", Seq: 0},
+		Ops: []TextOp{{Type: ListOpTypeInsert, Pos: 0, Len: 2, Content: "x"}},
+	}
+	if err := b.ApplyRemote(bad); err == nil {
+		t.Error("expected error for run whose length does not match its content")
+	}
+}
+
+func TestTextDoc_ApplyRemote_InvalidPos(t *testing.T) {
+	d := NewTextDoc()
+	d.Insert("alice", 0, "ab")
+
+	// The insert extends alice's run; the delete is past the end.
+	span := TextSpan{
+		ID:      causalgraph.RawVersion{Agent: "alice", Seq: 2},
+		Parents: []causalgraph.RawVersion{{Agent: "alice", Seq: 1}},
+		Ops: []TextOp{
+			{Type: ListOpTypeInsert, Pos: 2, Len: 1, Content: "c"},
+			{Type: ListOpTypeDelete, Pos: 5, Len: 1},
+		},
+	}
+	if err := d.ApplyRemote(span); !errors.Is(err, ErrInvalidOp) {
+		t.Fatalf("expected ErrInvalidOp, got %v", err)
+	}
+	if got := d.String(); got != "ab" {
+		t.Errorf("String: got %q, want %q", got, "ab")
+	}
+	if got, err := d.Checkout(d.Log.CG.Heads); err != nil || got != "ab" {
+		t.Errorf("Checkout: got %q, %v", got, err)
+	}
+
+	span.Ops = span.Ops[:1]
+	if err := d.ApplyRemote(span); err != nil {
+		t.Fatalf("ApplyRemote failed: %v", err)
+	}
+	if got := d.String(); got != "abc" {
+		t.Errorf("String: got %q, want %q", got, "abc")
+	}
+}
+
+func TestTextDoc_ConcurrentEdits_Converge(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	docs := []*TextDoc{NewTextDoc(), NewTextDoc(), NewTextDoc()}
+	agents := []string{"alice", "bob", "carol"}
+	alphabet := []rune("abcdéf€😀\n")
+
+	for round := 0; round < 30; round++ {
+		for i, doc := range docs {
+			for k := 0; k < 3; k++ {
+				if doc.Len() > 0 && rng.IntN(3) == 0 {
+					pos := rng.IntN(doc.Len())
+					length := 1 + rng.IntN(min(3, doc.Len()-pos))
+					if _, err := doc.Delete(agents[i], pos, length); err != nil {
+						t.Fatalf("Delete failed: %v", err)
+					}
+				} else {
+					text := string([]rune{alphabet[rng.IntN(len(alphabet))], alphabet[rng.IntN(len(alphabet))]})
+					if _, err := doc.Insert(agents[i], rng.IntN(doc.Len()+1), text); err != nil {
+						t.Fatalf("Insert failed: %v", err)
+					}
+				}
+			}
+		}
+		a, b := rng.IntN(len(docs)), rng.IntN(len(docs))
+		syncTextDocs(t, docs[a], docs[b])
+	}
+	for _, dst := range docs {
+		for _, src := range docs {
+			syncTextDocs(t, dst, src)
+		}
+	}
+
+	want := docs[0].String()
+	for i, doc := range docs {
+		if got := doc.String(); got != want {
+			t.Errorf("doc %d diverged:\ngot:  %q\nwant: %q", i, got, want)
+		}
+		checkedOut, err := doc.Checkout(doc.Version())
+		if err != nil {
+			t.Fatalf("Checkout failed: %v", err)
+		}
+		if checkedOut != want {
+			t.Errorf("doc %d Checkout(heads) = %q, want %q", i, checkedOut, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package egwalker
+
+import (
+	"fmt"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// LineCol is a zero-based line and column in a TextDoc.
+// Lines are separated by '\n'; the column is measured in the Unit passed
+// alongside it.
+type LineCol struct {
+	Line int
+	Col  int
+}
+
+// LineCount returns the number of lines in the current text. An empty
+// document has a single, empty line.
+func (d *TextDoc) LineCount() int {
+	return d.content.totalDims()[dimNewlines] + 1
+}
+
+// lineBounds returns the code point positions of the start of line and of
+// the end of its content, excluding the trailing newline.
+func (d *TextDoc) lineBounds(line int) (start, end int, err error) {
+	if line < 0 || line >= d.LineCount() {
+		return -1, -1, fmt.Errorf("line %d is out of bounds for document of %d lines", line, d.LineCount())
+	}
+	start = d.content.posForDim(dimNewlines, line)
+	if line+1 < d.LineCount() {
+		end = d.content.posForDim(dimNewlines, line+1) - 1
+	} else {
+		end = d.content.Len()
+	}
+	return start, end, nil
+}
+
+// Line returns the content of line, without its trailing newline.
+func (d *TextDoc) Line(line int) (string, error) {
+	start, end, err := d.lineBounds(line)
+	if err != nil {
+		return "", fmt.Errorf("line: %w", err)
+	}
+	runes := make([]rune, 0, end-start)
+	d.content.Each(func(i int, r rune) bool {
+		if i >= end {
+			return false
+		}
+		if i >= start {
+			runes = append(runes, r)
+		}
+		return true
+	})
+	return string(runes), nil
+}
+
+// OffsetToLineCol converts pos, measured in unit, to a line and column.
+func (d *TextDoc) OffsetToLineCol(pos int, unit Unit) (LineCol, error) {
+	cp, err := d.toCodePoints(pos, unit)
+	if err != nil {
+		return LineCol{}, fmt.Errorf("offsetToLineCol: %w", err)
+	}
+	line := d.content.dimsBefore(cp)[dimNewlines]
+	start, _, err := d.lineBounds(line)
+	if err != nil {
+		return LineCol{}, fmt.Errorf("offsetToLineCol: %w", err)
+	}
+	startInUnit, err := d.fromCodePoints(start, unit)
+	if err != nil {
+		return LineCol{}, fmt.Errorf("offsetToLineCol: %w", err)
+	}
+	return LineCol{Line: line, Col: pos - startInUnit}, nil
+}
+
+// LineColToOffset converts a line and column to a position, both measured in unit.
+func (d *TextDoc) LineColToOffset(lc LineCol, unit Unit) (int, error) {
+	start, end, err := d.lineBounds(lc.Line)
+	if err != nil {
+		return -1, fmt.Errorf("lineColToOffset: %w", err)
+	}
+	startInUnit, err := d.fromCodePoints(start, unit)
+	if err != nil {
+		return -1, fmt.Errorf("lineColToOffset: %w", err)
+	}
+	endInUnit, err := d.fromCodePoints(end, unit)
+	if err != nil {
+		return -1, fmt.Errorf("lineColToOffset: %w", err)
+	}
+	if lc.Col < 0 || startInUnit+lc.Col > endInUnit {
+		return -1, fmt.Errorf("lineColToOffset: column %d is out of bounds for line %d of length %d", lc.Col, lc.Line, endInUnit-startInUnit)
+	}
+	return startInUnit + lc.Col, nil
+}
+
+// InsertAtLineCol inserts text at lc, whose column is measured in unit, on
+// behalf of agent.
+func (d *TextDoc) InsertAtLineCol(agent string, lc LineCol, unit Unit, text string) (causalgraph.LVRange, error) {
+	pos, err := d.LineColToOffset(lc, unit)
+	if err != nil {
+		return causalgraph.LVRange{}, fmt.Errorf("insert: %w", err)
+	}
+	return d.InsertAt(agent, pos, unit, text)
+}
+
+// DeleteLineColRange deletes the text from start up to end on behalf of agent.
+// Columns are measured in unit. The range may span several lines, in which
+// case the newlines between them are deleted too.
+func (d *TextDoc) DeleteLineColRange(agent string, start, end LineCol, unit Unit) (causalgraph.LVRange, error) {
+	from, err := d.LineColToOffset(start, unit)
+	if err != nil {
+		return causalgraph.LVRange{}, fmt.Errorf("delete: %w", err)
+	}
+	to, err := d.LineColToOffset(end, unit)
+	if err != nil {
+		return causalgraph.LVRange{}, fmt.Errorf("delete: %w", err)
+	}
+	if to < from {
+		return causalgraph.LVRange{}, fmt.Errorf("delete: end %+v is before start %+v", end, start)
+	}
+	return d.DeleteAt(agent, from, to-from, unit)
+}
@@ -0,0 +1,102 @@
+package egwalker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTextDoc_LineIndex(t *testing.T) {
+	doc := NewTextDoc()
+	doc.Insert("alice", 0, "first\nsécond\n😀 third")
+
+	if got := doc.LineCount(); got != 3 {
+		t.Fatalf("LineCount = %d, want 3", got)
+	}
+	for i, want := range []string{"first", "sécond", "😀 third"} {
+		got, err := doc.Line(i)
+		if err != nil || got != want {
+			t.Errorf("Line(%d) = %q, %v; want %q", i, got, err, want)
+		}
+	}
+	if _, err := doc.Line(3); err == nil {
+		t.Error("expected error for line past the end")
+	}
+
+	tests := []struct {
+		name string
+		pos  int
+		unit Unit
+		want LineCol
+	}{
+		{"Start", 0, UnitCodePoint, LineCol{0, 0}},
+		{"EndOfFirstLine", 5, UnitCodePoint, LineCol{0, 5}},
+		{"StartOfSecondLine", 6, UnitCodePoint, LineCol{1, 0}},
+		{"SecondLine_Bytes", 6 + 3, UnitByte, LineCol{1, 3}},  // After "sé".
+		{"ThirdLine_UTF16", 13 + 2, UnitUTF16, LineCol{2, 2}}, // After "😀".
+		{"End", 20, UnitCodePoint, LineCol{2, 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := doc.OffsetToLineCol(tt.pos, tt.unit)
+			if err != nil {
+				t.Fatalf("OffsetToLineCol failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("OffsetToLineCol(%d, %v) = %+v, want %+v", tt.pos, tt.unit, got, tt.want)
+			}
+			back, err := doc.LineColToOffset(got, tt.unit)
+			if err != nil || back != tt.pos {
+				t.Errorf("LineColToOffset(%+v, %v) = %d, %v; want %d", got, tt.unit, back, err, tt.pos)
+			}
+		})
+	}
+
+	if _, err := doc.LineColToOffset(LineCol{Line: 0, Col: 6}, UnitCodePoint); err == nil {
+		t.Error("expected error for column past the end of the line")
+	}
+}
+
+func TestTextDoc_LineColEdits(t *testing.T) {
+	doc := NewTextDoc()
+	doc.Insert("alice", 0, "one\ntwo\nthree")
+
+	if _, err := doc.InsertAtLineCol("alice", LineCol{Line: 1, Col: 3}, UnitCodePoint, "!"); err != nil {
+		t.Fatalf("InsertAtLineCol failed: %v", err)
+	}
+	if _, err := doc.DeleteLineColRange("alice", LineCol{Line: 0, Col: 1}, LineCol{Line: 2, Col: 2}, UnitCodePoint); err != nil {
+		t.Fatalf("DeleteLineColRange failed: %v", err)
+	}
+	if got := doc.String(); got != "oree" {
+		t.Errorf("got %q, want %q", got, "oree")
+	}
+	if doc.LineCount() != 1 {
+		t.Errorf("LineCount = %d, want 1", doc.LineCount())
+	}
+	if _, err := doc.DeleteLineColRange("alice", LineCol{Line: 0, Col: 2}, LineCol{Line: 0, Col: 1}, UnitCodePoint); err == nil {
+		t.Error("expected error for reversed range")
+	}
+}
+
+func TestTextDoc_LineIndex_RemoteMerge(t *testing.T) {
+	a := NewTextDoc()
+	a.Insert("alice", 0, "header\nbody")
+	b := NewTextDoc()
+	syncTextDocs(t, b, a)
+
+	a.InsertAtLineCol("alice", LineCol{Line: 1, Col: 0}, UnitCodePoint, "line\n")
+	b.InsertAtLineCol("bob", LineCol{Line: 0, Col: 6}, UnitCodePoint, "\nsub")
+	syncTextDocs(t, b, a)
+
+	want := "header\nsub\nline\nbody"
+	if got := b.String(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := b.LineCount(); got != strings.Count(want, "\n")+1 {
+		t.Errorf("LineCount = %d, want %d", got, strings.Count(want, "\n")+1)
+	}
+	for i, line := range strings.Split(want, "\n") {
+		if got, err := b.Line(i); err != nil || got != line {
+			t.Errorf("Line(%d) = %q, %v; want %q", i, got, err, line)
+		}
+	}
+}
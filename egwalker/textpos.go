@@ -0,0 +1,167 @@
+package egwalker
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// Unit is a unit in which positions and lengths in a TextDoc are measured.
+type Unit int
+
+const (
+	// UnitCodePoint counts Unicode code points. It is the unit used by
+	// TextDoc.Insert, TextDoc.Delete and by the operation log.
+	UnitCodePoint Unit = iota
+	// UnitByte counts bytes of the UTF-8 encoding, as used by Go strings.
+	UnitByte
+	// UnitUTF16 counts UTF-16 code units, as used by JavaScript and LSP clients.
+	UnitUTF16
+)
+
+// String returns the name of the unit.
+func (u Unit) String() string {
+	switch u {
+	case UnitCodePoint:
+		return "code point"
+	case UnitByte:
+		return "byte"
+	case UnitUTF16:
+		return "UTF-16 code unit"
+	}
+	return fmt.Sprintf("Unit(%d)", int(u))
+}
+
+// Indexes into the ropeDims cached in a TextDoc's content.
+const (
+	dimBytes = iota
+	dimUTF16
+	dimNewlines
+)
+
+// textWeigh measures a single character for the dims cached in text ropes.
+func textWeigh(r rune) ropeDims {
+	var dims ropeDims
+	dims[dimBytes] = utf8.RuneLen(r)
+	if dims[dimBytes] < 0 {
+		dims[dimBytes] = utf8.RuneLen(utf8.RuneError)
+	}
+	dims[dimUTF16] = 1
+	if r >= 0x10000 {
+		dims[dimUTF16] = 2
+	}
+	if r == '\n' {
+		dims[dimNewlines] = 1
+	}
+	return dims
+}
+
+// newTextRope returns an empty rope which caches text measures.
+func newTextRope() Rope[rune] {
+	return Rope[rune]{weigh: textWeigh}
+}
+
+// unitDim returns the rope dimension measuring unit.
+func unitDim(unit Unit) (int, error) {
+	switch unit {
+	case UnitByte:
+		return dimBytes, nil
+	case UnitUTF16:
+		return dimUTF16, nil
+	}
+	return -1, fmt.Errorf("unknown unit %v", unit)
+}
+
+// LenIn returns the length of the current text measured in unit.
+func (d *TextDoc) LenIn(unit Unit) (int, error) {
+	n, err := d.lenIn(unit)
+	if err != nil {
+		return -1, fmt.Errorf("lenIn: %w", err)
+	}
+	return n, nil
+}
+
+func (d *TextDoc) lenIn(unit Unit) (int, error) {
+	if unit == UnitCodePoint {
+		return d.content.Len(), nil
+	}
+	dim, err := unitDim(unit)
+	if err != nil {
+		return -1, err
+	}
+	return d.content.totalDims()[dim], nil
+}
+
+// toCodePoints converts pos, measured in unit, to a code point position.
+func (d *TextDoc) toCodePoints(pos int, unit Unit) (int, error) {
+	n, err := d.lenIn(unit)
+	if err != nil {
+		return -1, err
+	}
+	if pos < 0 || pos > n {
+		return -1, fmt.Errorf("%ss: %w", unit, &ErrPosOutOfRange{Pos: pos, Len: n})
+	}
+	if unit == UnitCodePoint {
+		return pos, nil
+	}
+	dim, err := unitDim(unit)
+	if err != nil {
+		return -1, err
+	}
+	cp := d.content.posForDim(dim, pos)
+	if d.content.dimsBefore(cp)[dim] != pos {
+		return -1, fmt.Errorf("%s position %d falls inside a character", unit, pos)
+	}
+	return cp, nil
+}
+
+// fromCodePoints converts a code point position to one measured in unit.
+func (d *TextDoc) fromCodePoints(cp int, unit Unit) (int, error) {
+	if cp < 0 || cp > d.content.Len() {
+		return -1, fmt.Errorf("code points: %w", &ErrPosOutOfRange{Pos: cp, Len: d.content.Len()})
+	}
+	if unit == UnitCodePoint {
+		return cp, nil
+	}
+	dim, err := unitDim(unit)
+	if err != nil {
+		return -1, err
+	}
+	return d.content.dimsBefore(cp)[dim], nil
+}
+
+// ConvertPos converts a position in the current text from one unit to another.
+func (d *TextDoc) ConvertPos(pos int, from, to Unit) (int, error) {
+	cp, err := d.toCodePoints(pos, from)
+	if err != nil {
+		return -1, fmt.Errorf("convertPos: %w", err)
+	}
+	out, err := d.fromCodePoints(cp, to)
+	if err != nil {
+		return -1, fmt.Errorf("convertPos: %w", err)
+	}
+	return out, nil
+}
+
+// InsertAt inserts text at pos, measured in unit, on behalf of agent.
+func (d *TextDoc) InsertAt(agent string, pos int, unit Unit, text string) (causalgraph.LVRange, error) {
+	cp, err := d.toCodePoints(pos, unit)
+	if err != nil {
+		return causalgraph.LVRange{}, fmt.Errorf("insert: %w", err)
+	}
+	return d.Insert(agent, cp, text)
+}
+
+// DeleteAt deletes the range [pos, pos+length), measured in unit, on behalf of agent.
+func (d *TextDoc) DeleteAt(agent string, pos, length int, unit Unit) (causalgraph.LVRange, error) {
+	start, err := d.toCodePoints(pos, unit)
+	if err != nil {
+		return causalgraph.LVRange{}, fmt.Errorf("delete: %w", err)
+	}
+	end, err := d.toCodePoints(pos+length, unit)
+	if err != nil {
+		return causalgraph.LVRange{}, fmt.Errorf("delete: %w", err)
+	}
+	return d.Delete(agent, start, end-start)
+}
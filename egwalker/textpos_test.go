@@ -0,0 +1,102 @@
+package egwalker
+
+import (
+	"math/rand/v2"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestTextDoc_ConvertPos(t *testing.T) {
+	doc := NewTextDoc()
+	doc.Insert("alice", 0, "a€😀b") // Bytes: 1,3,4,1. UTF-16: 1,1,2,1.
+
+	tests := []struct {
+		name    string
+		pos     int
+		from    Unit
+		to      Unit
+		want    int
+		wantErr bool
+	}{
+		{"CodePointToByte", 2, UnitCodePoint, UnitByte, 4, false},
+		{"CodePointToUTF16_AfterEmoji", 3, UnitCodePoint, UnitUTF16, 4, false},
+		{"ByteToUTF16", 8, UnitByte, UnitUTF16, 4, false},
+		{"UTF16ToCodePoint_End", 5, UnitUTF16, UnitCodePoint, 4, false},
+		{"ByteToCodePoint_Start", 0, UnitByte, UnitCodePoint, 0, false},
+		{"Byte_InsideChar", 2, UnitByte, UnitCodePoint, 0, true},
+		{"UTF16_InsideSurrogatePair", 3, UnitUTF16, UnitCodePoint, 0, true},
+		{"Byte_PastEnd", 10, UnitByte, UnitCodePoint, 0, true},
+		{"CodePoint_Negative", -1, UnitCodePoint, UnitByte, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := doc.ConvertPos(tt.pos, tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConvertPos(%d, %v, %v) error = %v, wantErr %v", tt.pos, tt.from, tt.to, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ConvertPos(%d, %v, %v) = %d, want %d", tt.pos, tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+
+	for unit, want := range map[Unit]int{UnitCodePoint: 4, UnitByte: 9, UnitUTF16: 5} {
+		if got, err := doc.LenIn(unit); err != nil || got != want {
+			t.Errorf("LenIn(%v) = %d, %v, want %d", unit, got, err, want)
+		}
+	}
+	if _, err := doc.LenIn(Unit(42)); err == nil {
+		t.Error("LenIn: expected error for unknown unit")
+	}
+}
+
+func TestTextDoc_InsertAtDeleteAt(t *testing.T) {
+	doc := NewTextDoc()
+	doc.Insert("alice", 0, "a😀b")
+
+	if _, err := doc.InsertAt("alice", 3, UnitUTF16, "X"); err != nil {
+		t.Fatalf("InsertAt failed: %v", err)
+	}
+	if got := doc.String(); got != "a😀Xb" {
+		t.Errorf("after InsertAt: got %q, want %q", got, "a😀Xb")
+	}
+	if _, err := doc.DeleteAt("alice", 1, 5, UnitByte); err != nil { // Deletes "😀X".
+		t.Fatalf("DeleteAt failed: %v", err)
+	}
+	if got := doc.String(); got != "ab" {
+		t.Errorf("after DeleteAt: got %q, want %q", got, "ab")
+	}
+	if _, err := doc.InsertAt("alice", 2, UnitUTF16, "x"); err != nil {
+		t.Fatalf("InsertAt at end failed: %v", err)
+	}
+	if _, err := doc.DeleteAt("alice", 0, 2, Unit(42)); err == nil {
+		t.Error("expected error for unknown unit")
+	}
+}
+
+func TestTextDoc_ConvertPos_LongText(t *testing.T) {
+	rng := rand.New(rand.NewPCG(5, 6))
+	alphabet := []rune("aé€😀\n")
+	doc := NewTextDoc()
+	for i := 0; i < 400; i++ {
+		r := alphabet[rng.IntN(len(alphabet))]
+		if _, err := doc.Insert("alice", rng.IntN(doc.Len()+1), string(r)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	runes := []rune(doc.String())
+	for cp := 0; cp <= len(runes); cp++ {
+		prefix := string(runes[:cp])
+		if got, _ := doc.ConvertPos(cp, UnitCodePoint, UnitByte); got != len(prefix) {
+			t.Fatalf("byte offset of %d: got %d, want %d", cp, got, len(prefix))
+		}
+		wantUTF16 := len(utf16.Encode(runes[:cp]))
+		if got, _ := doc.ConvertPos(cp, UnitCodePoint, UnitUTF16); got != wantUTF16 {
+			t.Fatalf("UTF-16 offset of %d: got %d, want %d", cp, got, wantUTF16)
+		}
+		if back, err := doc.ConvertPos(wantUTF16, UnitUTF16, UnitCodePoint); err != nil || back != cp {
+			t.Fatalf("UTF-16 offset %d back to code points: got %d (%v), want %d", wantUTF16, back, err, cp)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package egwalker
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// Tx accumulates the operations of a transaction started by Walker.Transact.
+// Positions refer to the document as left by the transaction's earlier
+// operations. A Tx must not be used after its callback returns.
+type Tx[T any] struct {
+	ops    []ListOp[T]
+	length int
+}
+
+// Insert adds an insert of content at pos to the transaction.
+func (tx *Tx[T]) Insert(pos int, content T) error {
+	if pos < 0 || pos > tx.length {
+		return fmt.Errorf("insert: %w", &ErrPosOutOfRange{Pos: pos, Len: tx.length})
+	}
+	tx.ops = append(tx.ops, ListOp[T]{Type: ListOpTypeInsert, Pos: pos, Content: content})
+	tx.length++
+	return nil
+}
+
+// Delete adds a delete of the element at pos to the transaction.
+func (tx *Tx[T]) Delete(pos int) error {
+	if pos < 0 || pos >= tx.length {
+		return fmt.Errorf("delete: %w", &ErrPosOutOfRange{Pos: pos, Len: tx.length})
+	}
+	tx.ops = append(tx.ops, ListOp[T]{Type: ListOpTypeDelete, Pos: pos})
+	tx.length--
+	return nil
+}
+
+// Move adds a move of the element at from to the transaction, so that it
+// ends up at to. See Walker.LocalMove.
+func (tx *Tx[T]) Move(from, to int) error {
+	if from < 0 || from >= tx.length {
+		return fmt.Errorf("move source: %w", &ErrPosOutOfRange{Pos: from, Len: tx.length})
+	}
+	if to < 0 || to >= tx.length {
+		return fmt.Errorf("move destination: %w", &ErrPosOutOfRange{Pos: to, Len: tx.length})
+	}
+	tx.ops = append(tx.ops, ListOp[T]{Type: ListOpTypeMove, Pos: to, From: from})
+	return nil
+}
+
+// Len returns the length of the document including the transaction's operations.
+func (tx *Tx[T]) Len() int {
+	return tx.length
+}
+
+// Transact runs fn to build a group of local operations and commits them
+// atomically on behalf of agent. The operations get one contiguous range of
+// sequence numbers and a single causal graph entry, so peers receive them as
+// one span. If fn returns an error, nothing is committed and the error is
+// returned. It returns the LVs of the committed operations.
+func (w *Walker[T]) Transact(agent string, fn func(tx *Tx[T]) error) (causalgraph.LVRange, error) {
+	tx := &Tx[T]{length: w.Len()}
+	if err := fn(tx); err != nil {
+		return causalgraph.LVRange{}, err
+	}
+	if len(tx.ops) == 0 {
+		next := w.Log.CG.NextLV
+		return causalgraph.LVRange{Start: next, End: next}, nil
+	}
+	r, err := w.commit(causalgraph.AgentID(agent), tx.ops)
+	if err != nil {
+		return causalgraph.LVRange{}, fmt.Errorf("transact: %w", err)
+	}
+	return r, nil
+}
+
+// commit logs ops as a single span by agent with the current heads as
+// parents. If anything fails part way, the log, causal graph and context are
+// restored to their state before the call.
+func (w *Walker[T]) commit(agent causalgraph.AgentID, ops []ListOp[T]) (causalgraph.LVRange, error) {
+	cg := &w.Log.CG
+	parents, err := causalgraph.LVToRawList(cg, cg.Heads)
+	if err != nil {
+		return causalgraph.LVRange{}, err
+	}
+	if parents == nil {
+		parents = []causalgraph.RawVersion{}
+	}
+	id := causalgraph.RawVersion{Agent: agent, Seq: causalgraph.NextSeqForAgent(cg, agent)}
+	r, err := w.addSpan(id, parents, ops)
+	if err != nil {
+		return causalgraph.LVRange{}, err
+	}
+	w.emit(r, true)
+	return r, nil
+}
+
+// addSpan logs ops as a span with the given ID and parents and applies them.
+// If anything fails part way, such as a remote operation at a position
+// outside the document, the log, causal graph and context are restored to
+// their state before the call.
+func (w *Walker[T]) addSpan(id causalgraph.RawVersion, parents []causalgraph.RawVersion, ops []ListOp[T]) (causalgraph.LVRange, error) {
+	cg := &w.Log.CG
+	oldOps, oldContent := len(w.Log.Ops), w.content
+	var r causalgraph.LVRange
+	err := rollbackOnError(cg, id.Agent, func() error {
+		entry, err := causalgraph.AddRaw(cg, id, len(ops), parents)
+		if err != nil {
+			return fmt.Errorf("failed to add %s:%d to causal graph: %w", id.Agent, id.Seq, err)
+		}
+		r = causalgraph.LVRange{Start: entry.Version, End: entry.VEnd}
+		w.Log.Ops = append(w.Log.Ops, ops...)
+		for lv := r.Start; lv < r.End; lv++ {
+			if err := w.apply(lv); err != nil {
+				return err
+			}
+		}
+		return w.Ctx.moveTo(cg, cg.Heads)
+	}, func() error {
+		w.Log.Ops = w.Log.Ops[:oldOps]
+		w.content = oldContent
+		w.changes = nil
+		ctx, err := w.replay(cg.Heads)
+		if err != nil {
+			return err
+		}
+		w.Ctx = ctx
+		return nil
+	})
+	return r, err
+}
+
+// rollbackOnError calls add, which adds one span by agent to cg and applies
+// it to the data cg versions. If add fails after the span was added, cg is
+// restored to its state before the call and restore is called to undo the
+// rest, so a span rejected part way leaves no trace.
+func rollbackOnError(cg *causalgraph.CausalGraph, agent causalgraph.AgentID, add func() error, restore func() error) error {
+	oldHeads := slices.Clone(cg.Heads)
+	oldEntries, oldNext := len(cg.Entries), cg.NextLV
+	oldRuns, hadRuns := cg.AgentToVersion[agent]
+	err := add()
+	if err == nil || cg.NextLV == oldNext {
+		// AddRaw changes nothing when it fails.
+		return err
+	}
+	cg.Heads = oldHeads
+	cg.Entries = cg.Entries[:oldEntries]
+	cg.NextLV = oldNext
+	if hadRuns {
+		cg.AgentToVersion[agent] = oldRuns
+	} else {
+		delete(cg.AgentToVersion, agent)
+	}
+	if rbErr := restore(); rbErr != nil {
+		return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package egwalker
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+func TestWalker_Transact(t *testing.T) {
+	w := NewWalker[string]()
+	w.LocalInsert("alice", 0, "a")
+
+	r, err := w.Transact("bob", func(tx *Tx[string]) error {
+		if err := tx.Insert(1, "b"); err != nil {
+			return err
+		}
+		if err := tx.Insert(2, "c"); err != nil {
+			return err
+		}
+		return tx.Delete(0)
+	})
+	if err != nil {
+		t.Fatalf("Transact failed: %v", err)
+	}
+	if want := (causalgraph.LVRange{Start: 1, End: 4}); r != want {
+		t.Errorf("got range %+v, want %+v", r, want)
+	}
+	if got, want := w.GetActiveItems(), []string{"b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	cg := w.GetCG()
+	if len(cg.Entries) != 2 {
+		t.Errorf("expected the transaction to add one causal graph entry, got %d entries", len(cg.Entries))
+	}
+	compareLVSlices(t, cg.Heads, []causalgraph.LV{3})
+	compareLVSlices(t, w.GetVersion(), []causalgraph.LV{3})
+
+	// Peers receive the transaction as a single span.
+	spans, err := w.Spans()
+	if err != nil {
+		t.Fatalf("Spans failed: %v", err)
+	}
+	if len(spans) != 2 || len(spans[1].Ops) != 3 || spans[1].ID != (causalgraph.RawVersion{Agent: "bob", Seq: 0}) {
+		t.Errorf("unexpected spans %+v", spans)
+	}
+	peer := NewWalker[string]()
+	syncWalkers(t, peer, w)
+	if got := peer.GetActiveItems(); !reflect.DeepEqual(got, w.GetActiveItems()) {
+		t.Errorf("peer got %v, want %v", got, w.GetActiveItems())
+	}
+}
+
+func TestWalker_Transact_Rollback(t *testing.T) {
+	w := NewWalker[string]()
+	w.LocalInsert("alice", 0, "a")
+	before := w.GetCG().NextLV
+
+	errBoom := errors.New("boom")
+	_, err := w.Transact("alice", func(tx *Tx[string]) error {
+		tx.Insert(1, "b")
+		return errBoom
+	})
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("expected callback error, got %v", err)
+	}
+	_, err = w.Transact("alice", func(tx *Tx[string]) error {
+		return tx.Delete(5)
+	})
+	if err == nil {
+		t.Fatal("expected error for out of bounds delete")
+	}
+	if w.GetCG().NextLV != before || len(w.GetOps()) != 1 || len(w.GetCG().Entries) != 1 {
+		t.Errorf("failed transactions changed the log: NextLV %d, %d ops", w.GetCG().NextLV, len(w.GetOps()))
+	}
+	if got, want := w.GetActiveItems(), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// The agent's sequence numbers continue without a gap.
+	r, err := w.Transact("alice", func(tx *Tx[string]) error { return tx.Insert(1, "b") })
+	if err != nil {
+		t.Fatalf("Transact failed: %v", err)
+	}
+	if raw, _ := causalgraph.LVToRaw(w.GetCG(), r.Start); raw.Seq != 1 {
+		t.Errorf("expected seq 1, got %d", raw.Seq)
+	}
+}
+
+func TestWalker_Transact_Empty(t *testing.T) {
+	w := NewWalker[string]()
+	r, err := w.Transact("alice", func(tx *Tx[string]) error { return nil })
+	if err != nil {
+		t.Fatalf("Transact failed: %v", err)
+	}
+	if r.Start != r.End || w.GetCG().NextLV != 0 {
+		t.Errorf("empty transaction logged ops: range %+v", r)
+	}
+}
+
+func TestWalker_Transact_Move(t *testing.T) {
+	w := NewWalker[string]()
+	_, err := w.Transact("alice", func(tx *Tx[string]) error {
+		for i, s := range []string{"a", "b", "c"} {
+			if err := tx.Insert(i, s); err != nil {
+				return err
+			}
+		}
+		return tx.Move(0, 2)
+	})
+	if err != nil {
+		t.Fatalf("Transact failed: %v", err)
+	}
+	if got, want := w.GetActiveItems(), []string{"b", "c", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	_, err = w.Transact("alice", func(tx *Tx[string]) error { return tx.Move(0, 3) })
+	var posErr *ErrPosOutOfRange
+	if !errors.As(err, &posErr) {
+		t.Errorf("expected ErrPosOutOfRange for a move past the end, got %v", err)
+	}
+}
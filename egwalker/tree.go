@@ -0,0 +1,567 @@
+package egwalker
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// TreeOpType is the type of a TreeOp.
+type TreeOpType string
+
+const (
+	TreeOpCreate TreeOpType = "create"
+	TreeOpMove   TreeOpType = "move"
+	TreeOpDelete TreeOpType = "delete"
+)
+
+// TreeOp is a single operation on a Tree. Nodes are identified by the ID of
+// the operation which created them.
+type TreeOp[V any] struct {
+	Type TreeOpType
+	// Node is the node moved or deleted.
+	Node causalgraph.RawVersion
+	// Parent is the node the node created or moved goes under, or nil for
+	// the root.
+	Parent *causalgraph.RawVersion
+	// Pos is the position among the parent's placements at which the node
+	// is placed. See Tree.
+	Pos   int
+	Value V // Value of the node created.
+}
+
+// TreeSpan is a run of consecutive tree operations by a single agent, in
+// the form exchanged between peers. See RemoteSpan.
+type TreeSpan[V any] struct {
+	ID      causalgraph.RawVersion
+	Parents []causalgraph.RawVersion
+	Ops     []TreeOp[V]
+}
+
+// TreeNode is a node of a checked out Tree.
+type TreeNode[V any] struct {
+	ID       causalgraph.RawVersion
+	LV       causalgraph.LV
+	Value    V
+	Children []*TreeNode[V]
+}
+
+// treeRoot is the LV standing for the root of a Tree.
+const treeRoot causalgraph.LV = -1
+
+// treeRef holds the LVs of the node and parent an operation refers to.
+type treeRef struct {
+	node, parent causalgraph.LV
+}
+
+// treeList orders the placements under a node: the creates and moves which
+// put a node under it. Placements are never removed from the list; those
+// which do not hold their node in a version are skipped when reading it.
+type treeList struct {
+	ctx     *EditContext
+	content Rope[causalgraph.LV] // LVs of the placements, in list order.
+}
+
+// treeState is the shape of a Tree at a version.
+type treeState struct {
+	parent    map[causalgraph.LV]causalgraph.LV
+	placement map[causalgraph.LV]causalgraph.LV // Placement holding each node.
+	deleted   map[causalgraph.LV]bool
+}
+
+// Tree is a tree CRDT for hierarchical data such as outlines, versioned by
+// a causal graph which may be shared with other data. Nodes can be created,
+// moved to another parent and deleted concurrently.
+//
+// The children of each node are ordered by the same engine as Walker, as a
+// list of placements: every create or move putting a node under it adds one.
+// A node is held by the placement of its latest move, where moves are
+// replayed in an order consistent with causality on which all peers agree,
+// and a move is skipped if it would put a node under itself. Deleting a node
+// hides it along with whatever is under it.
+//
+// As with LWWMap, when the graph is shared, spans must be applied on other
+// peers in the order of their LVs on the sending peer.
+type Tree[V any] struct {
+	log   *sharedLog[TreeOp[V]]
+	refs  map[causalgraph.LV]treeRef
+	lists map[causalgraph.LV]*treeList
+	// placements, moves and deletes list the LVs of those operations in
+	// increasing order. Placements are the creates and moves.
+	placements, moves, deletes []causalgraph.LV
+	// lastMove is the latest move in the order moves are replayed, or -1.
+	lastMove causalgraph.LV
+	order    *lamportOrder
+	// current caches the state at the graph heads, if still valid. It is
+	// kept up to date as operations are applied, unless a move arrives which
+	// is replayed before moves already applied.
+	current *treeState
+}
+
+// NewTree creates an empty tree versioned by cg.
+func NewTree[V any](cg *causalgraph.CausalGraph) *Tree[V] {
+	return &Tree[V]{
+		log:      newSharedLog[TreeOp[V]](cg),
+		refs:     make(map[causalgraph.LV]treeRef),
+		lists:    make(map[causalgraph.LV]*treeList),
+		lastMove: -1,
+		order:    newLamportOrder(cg),
+	}
+}
+
+// Create creates a node holding value on behalf of agent, and places it at
+// index among the children of parent, or of the root if parent is -1.
+func (t *Tree[V]) Create(agent string, parent causalgraph.LV, index int, value V) (causalgraph.LV, error) {
+	pos, err := t.placePos(parent, -1, index)
+	if err != nil {
+		return -1, fmt.Errorf("create: %w", err)
+	}
+	lv, err := t.local(agent, TreeOp[V]{Type: TreeOpCreate, Pos: pos, Value: value}, -1, parent)
+	if err != nil {
+		return -1, fmt.Errorf("create: %w", err)
+	}
+	return lv, nil
+}
+
+// Move moves node, with everything under it, to index among the children of
+// parent on behalf of agent. Index counts the children other than node.
+func (t *Tree[V]) Move(agent string, node, parent causalgraph.LV, index int) (causalgraph.LV, error) {
+	state, err := t.state()
+	if err != nil {
+		return -1, fmt.Errorf("move: %w", err)
+	}
+	if !t.visible(state, node) {
+		return -1, fmt.Errorf("move: node %d is not in the tree", node)
+	}
+	if state.isUnder(parent, node) {
+		return -1, fmt.Errorf("move: node %d cannot be moved under itself", node)
+	}
+	pos, err := t.placePos(parent, node, index)
+	if err != nil {
+		return -1, fmt.Errorf("move: %w", err)
+	}
+	lv, err := t.local(agent, TreeOp[V]{Type: TreeOpMove, Pos: pos}, node, parent)
+	if err != nil {
+		return -1, fmt.Errorf("move: %w", err)
+	}
+	return lv, nil
+}
+
+// Delete deletes node, with everything under it, on behalf of agent.
+func (t *Tree[V]) Delete(agent string, node causalgraph.LV) (causalgraph.LV, error) {
+	state, err := t.state()
+	if err != nil {
+		return -1, fmt.Errorf("delete: %w", err)
+	}
+	if !t.visible(state, node) {
+		return -1, fmt.Errorf("delete: node %d is not in the tree", node)
+	}
+	lv, err := t.local(agent, TreeOp[V]{Type: TreeOpDelete}, node, -1)
+	if err != nil {
+		return -1, fmt.Errorf("delete: %w", err)
+	}
+	return lv, nil
+}
+
+// placePos returns the position in the placement list of parent which puts
+// a node at index among the current children of parent other than skip.
+func (t *Tree[V]) placePos(parent, skip causalgraph.LV, index int) (int, error) {
+	state, err := t.state()
+	if err != nil {
+		return -1, err
+	}
+	if parent != treeRoot && !t.visible(state, parent) {
+		return -1, fmt.Errorf("parent %d is not in the tree", parent)
+	}
+	list := t.lists[parent]
+	if list == nil {
+		if index != 0 {
+			return -1, fmt.Errorf("index: %w", &ErrPosOutOfRange{Pos: index, Len: 0})
+		}
+		return 0, nil
+	}
+	placements := list.content.Slice()
+	n := 0
+	for i, pl := range placements {
+		node := t.refs[pl].node
+		if node == skip || state.placement[node] != pl || state.deleted[node] {
+			continue
+		}
+		if n == index {
+			return i, nil
+		}
+		n++
+	}
+	if index != n {
+		return -1, fmt.Errorf("index: %w", &ErrPosOutOfRange{Pos: index, Len: n})
+	}
+	return len(placements), nil
+}
+
+// local logs op, which refers to node and parent, with the graph heads as
+// parents, and applies it.
+func (t *Tree[V]) local(agent string, op TreeOp[V], node, parent causalgraph.LV) (causalgraph.LV, error) {
+	if node >= 0 {
+		op.Node, _ = causalgraph.LVToRaw(t.log.cg, node)
+	}
+	if parent != treeRoot && op.Type != TreeOpDelete {
+		raw, _ := causalgraph.LVToRaw(t.log.cg, parent)
+		op.Parent = &raw
+	}
+	lv, err := t.log.local(agent, nil, op)
+	if err != nil {
+		return -1, err
+	}
+	if err := t.apply(lv); err != nil {
+		return -1, err
+	}
+	return lv, nil
+}
+
+// apply adds the logged operation lv to the tree.
+func (t *Tree[V]) apply(lv causalgraph.LV) error {
+	op := t.log.ops[lv]
+	ref := treeRef{node: lv, parent: treeRoot}
+	var err error
+	if op.Type != TreeOpCreate {
+		if ref.node, err = causalgraph.RawToLV(t.log.cg, op.Node.Agent, op.Node.Seq); err != nil {
+			return err
+		}
+	}
+	if op.Parent != nil && op.Type != TreeOpDelete {
+		if ref.parent, err = causalgraph.RawToLV(t.log.cg, op.Parent.Agent, op.Parent.Seq); err != nil {
+			return err
+		}
+	}
+	t.refs[lv] = ref
+
+	switch op.Type {
+	case TreeOpCreate:
+		if t.current != nil {
+			t.current.parent[lv] = ref.parent
+			t.current.placement[lv] = lv
+		}
+	case TreeOpMove:
+		t.moves = append(t.moves, lv)
+		t.order.update()
+		if t.lastMove < 0 || t.order.cmp(t.lastMove, lv) < 0 {
+			// Replayed after every move so far, as local moves always are.
+			t.lastMove = lv
+			if t.current != nil {
+				t.current.move(ref, lv)
+			}
+		} else {
+			t.current = nil
+		}
+	case TreeOpDelete:
+		t.deletes = append(t.deletes, lv)
+		if t.current != nil {
+			t.current.deleted[ref.node] = true
+		}
+		return nil
+	}
+	t.placements = append(t.placements, lv)
+	list := t.lists[ref.parent]
+	if list == nil {
+		list = &treeList{ctx: newEditCtx()}
+		t.lists[ref.parent] = list
+	}
+	listOp := ListOp[causalgraph.LV]{Type: ListOpTypeInsert, Pos: op.Pos, Content: lv}
+	endPos, err := integrateOp(list.ctx, t.log.cg, lv, listOp)
+	if err != nil {
+		return err
+	}
+	list.content = applyToRope(list.content, listOp, endPos)
+	return nil
+}
+
+// ApplyRemote integrates a span of tree operations received from another
+// peer. Operations the graph already knows are skipped.
+func (t *Tree[V]) ApplyRemote(span TreeSpan[V]) error {
+	if err := t.checkRemote(span); err != nil {
+		return fmt.Errorf("applyRemote: %w", err)
+	}
+	if err := t.log.integrateRemote(span.ID, span.Parents, span.Ops, t.apply, t.rebuild); err != nil {
+		return fmt.Errorf("applyRemote: %w", err)
+	}
+	return nil
+}
+
+// rebuild recomputes the tree from its log.
+func (t *Tree[V]) rebuild() error {
+	t.refs = make(map[causalgraph.LV]treeRef)
+	t.lists = make(map[causalgraph.LV]*treeList)
+	t.placements, t.moves, t.deletes, t.current = nil, nil, nil, nil
+	t.lastMove, t.order = -1, newLamportOrder(t.log.cg)
+	for _, lv := range t.log.lvs() {
+		if err := t.apply(lv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRemote checks that every node the operations of span refer to was
+// created by an operation they have seen, and that no position is negative.
+func (t *Tree[V]) checkRemote(span TreeSpan[V]) error {
+	parents := make([]causalgraph.LV, 0, len(span.Parents))
+	for _, p := range span.Parents {
+		lv, err := causalgraph.RawToLV(t.log.cg, p.Agent, p.Seq)
+		if err != nil {
+			return err
+		}
+		parents = append(parents, lv)
+	}
+	for i, op := range span.Ops {
+		check := func(id causalgraph.RawVersion) error {
+			if id.Agent == span.ID.Agent && id.Seq >= span.ID.Seq && id.Seq < span.ID.Seq+i {
+				if span.Ops[id.Seq-span.ID.Seq].Type != TreeOpCreate {
+					return fmt.Errorf("op %d: %w: %s:%d did not create a node", i, ErrInvalidOp, id.Agent, id.Seq)
+				}
+				return nil
+			}
+			lv, err := causalgraph.RawToLV(t.log.cg, id.Agent, id.Seq)
+			if err != nil {
+				return fmt.Errorf("op %d: %w", i, err)
+			}
+			if created, ok := t.log.ops[lv]; !ok || created.Type != TreeOpCreate {
+				return fmt.Errorf("op %d: %w: %s:%d did not create a node", i, ErrInvalidOp, id.Agent, id.Seq)
+			}
+			seen, err := causalgraph.VersionContainsLV(t.log.cg, parents, lv)
+			if err != nil {
+				return fmt.Errorf("op %d: %w", i, err)
+			}
+			if !seen {
+				return fmt.Errorf("op %d: %w: node %s:%d is not in the span's history", i, ErrInvalidOp, id.Agent, id.Seq)
+			}
+			return nil
+		}
+		switch op.Type {
+		case TreeOpCreate, TreeOpMove, TreeOpDelete:
+		default:
+			return fmt.Errorf("op %d: %w: unknown operation type %q", i, ErrInvalidOp, op.Type)
+		}
+		if op.Type != TreeOpDelete && op.Pos < 0 {
+			// Positions past the end are caught when the op is applied.
+			return fmt.Errorf("op %d: %w: position %d is negative", i, ErrInvalidOp, op.Pos)
+		}
+		if op.Type != TreeOpCreate {
+			if err := check(op.Node); err != nil {
+				return err
+			}
+		}
+		if op.Parent != nil && op.Type != TreeOpDelete {
+			if err := check(*op.Parent); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Spans returns the tree's whole history as spans which can be passed to
+// ApplyRemote on another Tree.
+func (t *Tree[V]) Spans() ([]TreeSpan[V], error) {
+	var spans []TreeSpan[V]
+	err := t.log.eachSpan(func(id causalgraph.RawVersion, parents []causalgraph.RawVersion, ops []TreeOp[V]) {
+		spans = append(spans, TreeSpan[V]{ID: id, Parents: parents, Ops: ops})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("spans: %w", err)
+	}
+	return spans, nil
+}
+
+// state returns the current state of the tree.
+func (t *Tree[V]) state() (*treeState, error) {
+	if t.current == nil {
+		history, err := t.log.history(t.log.cg.Heads)
+		if err != nil {
+			return nil, err
+		}
+		t.current = t.stateAt(history)
+	}
+	return t.current, nil
+}
+
+// stateAt returns the state of the tree in the version with the given
+// history.
+func (t *Tree[V]) stateAt(history []causalgraph.LVRange) *treeState {
+	s := &treeState{
+		parent:    make(map[causalgraph.LV]causalgraph.LV),
+		placement: make(map[causalgraph.LV]causalgraph.LV),
+		deleted:   make(map[causalgraph.LV]bool),
+	}
+	for lv, ref := range t.refs {
+		if t.log.ops[lv].Type == TreeOpCreate && lvRangesContain(history, lv) {
+			s.parent[lv] = ref.parent
+			s.placement[lv] = lv
+		}
+	}
+	var moves []causalgraph.LV
+	for _, lv := range t.moves {
+		if lvRangesContain(history, lv) {
+			moves = append(moves, lv)
+		}
+	}
+	t.order.update()
+	slices.SortFunc(moves, t.order.cmp)
+	for _, lv := range moves {
+		s.move(t.refs[lv], lv)
+	}
+	for _, lv := range t.deletes {
+		if lvRangesContain(history, lv) {
+			s.deleted[t.refs[lv].node] = true
+		}
+	}
+	return s
+}
+
+// move applies the move lv, which refers to ref, unless it would put the
+// node under itself.
+func (s *treeState) move(ref treeRef, lv causalgraph.LV) {
+	if s.isUnder(ref.parent, ref.node) {
+		return
+	}
+	s.parent[ref.node] = ref.parent
+	s.placement[ref.node] = lv
+}
+
+// isUnder reports whether node is ancestor or lies under it.
+func (s *treeState) isUnder(node, ancestor causalgraph.LV) bool {
+	for node != treeRoot {
+		if node == ancestor {
+			return true
+		}
+		parent, ok := s.parent[node]
+		if !ok {
+			return false
+		}
+		node = parent
+	}
+	return false
+}
+
+// visible reports whether node is in the tree in state: neither it nor any
+// node above it is deleted.
+func (t *Tree[V]) visible(state *treeState, node causalgraph.LV) bool {
+	if _, ok := state.parent[node]; !ok {
+		return false
+	}
+	for ; node != treeRoot; node = state.parent[node] {
+		if state.deleted[node] {
+			return false
+		}
+	}
+	return true
+}
+
+// Children returns the current children of node, or of the root if node is
+// -1, in order.
+func (t *Tree[V]) Children(node causalgraph.LV) ([]causalgraph.LV, error) {
+	state, err := t.state()
+	if err != nil {
+		return nil, fmt.Errorf("children: %w", err)
+	}
+	if node != treeRoot && !t.visible(state, node) {
+		return nil, fmt.Errorf("children: node %d is not in the tree", node)
+	}
+	var children []causalgraph.LV
+	if list := t.lists[node]; list != nil {
+		children = t.children(state, list.content.Slice())
+	}
+	return children, nil
+}
+
+// Parent returns the current parent of node, or -1 if it is at the root.
+func (t *Tree[V]) Parent(node causalgraph.LV) (causalgraph.LV, error) {
+	state, err := t.state()
+	if err != nil {
+		return -1, fmt.Errorf("parent: %w", err)
+	}
+	if !t.visible(state, node) {
+		return -1, fmt.Errorf("parent: node %d is not in the tree", node)
+	}
+	return state.parent[node], nil
+}
+
+// children returns the nodes held by placements, in order, leaving out
+// deleted ones.
+func (t *Tree[V]) children(state *treeState, placements []causalgraph.LV) []causalgraph.LV {
+	var children []causalgraph.LV
+	for _, pl := range placements {
+		node := t.refs[pl].node
+		if state.placement[node] == pl && !state.deleted[node] {
+			children = append(children, node)
+		}
+	}
+	return children
+}
+
+// Checkout returns the nodes at the root of the tree at version, with the
+// nodes under them.
+func (t *Tree[V]) Checkout(version []causalgraph.LV) ([]*TreeNode[V], error) {
+	history, err := t.log.history(version)
+	if err != nil {
+		return nil, fmt.Errorf("checkout: %w", err)
+	}
+	state := t.stateAt(history)
+	lists, err := t.listsAt(version, history)
+	if err != nil {
+		return nil, fmt.Errorf("checkout: %w", err)
+	}
+	var build func(parent causalgraph.LV) []*TreeNode[V]
+	build = func(parent causalgraph.LV) []*TreeNode[V] {
+		var nodes []*TreeNode[V]
+		for _, lv := range t.children(state, lists[parent]) {
+			raw, _ := causalgraph.LVToRaw(t.log.cg, lv)
+			nodes = append(nodes, &TreeNode[V]{ID: raw, LV: lv, Value: t.log.ops[lv].Value, Children: build(lv)})
+		}
+		return nodes
+	}
+	return build(treeRoot), nil
+}
+
+// listsAt returns the placement list of each node at version, whose history
+// is given. The placements of every list are replayed in a single pass over
+// the history.
+func (t *Tree[V]) listsAt(version []causalgraph.LV, history []causalgraph.LVRange) (map[causalgraph.LV][]causalgraph.LV, error) {
+	lists := make(map[causalgraph.LV][]causalgraph.LV, len(t.lists))
+	if frontiersEqual(version, t.log.cg.Heads) {
+		for parent, list := range t.lists {
+			lists[parent] = list.content.Slice()
+		}
+		return lists, nil
+	}
+	ctxs := make(map[causalgraph.LV]*EditContext)
+	contents := make(map[causalgraph.LV]Rope[causalgraph.LV])
+	for _, r := range history {
+		i := sort.Search(len(t.placements), func(i int) bool { return t.placements[i] >= r.Start })
+		for ; i < len(t.placements) && t.placements[i] < r.End; i++ {
+			lv := t.placements[i]
+			parent := t.refs[lv].parent
+			ctx := ctxs[parent]
+			if ctx == nil {
+				ctx = newEditCtx()
+				ctxs[parent] = ctx
+			}
+			op := ListOp[causalgraph.LV]{Type: ListOpTypeInsert, Pos: t.log.ops[lv].Pos, Content: lv}
+			endPos, err := integrateOp(ctx, t.log.cg, lv, op)
+			if err != nil {
+				return nil, err
+			}
+			contents[parent] = applyToRope(contents[parent], op, endPos)
+		}
+	}
+	for parent, content := range contents {
+		lists[parent] = content.Slice()
+	}
+	return lists, nil
+}
+
+// Snapshot returns the current tree. See Checkout.
+func (t *Tree[V]) Snapshot() ([]*TreeNode[V], error) {
+	return t.Checkout(t.log.cg.Heads)
+}
@@ -0,0 +1,288 @@
+package egwalker
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+func syncTrees[V any](t *testing.T, dst, src *Tree[V]) {
+	t.Helper()
+	spans, err := src.Spans()
+	if err != nil {
+		t.Fatalf("Spans failed: %v", err)
+	}
+	for _, span := range spans {
+		if err := dst.ApplyRemote(span); err != nil {
+			t.Fatalf("ApplyRemote(%+v) failed: %v", span.ID, err)
+		}
+	}
+}
+
+// outline formats nodes as "a(b,c),d".
+func outline(nodes []*TreeNode[string]) string {
+	parts := make([]string, len(nodes))
+	for i, n := range nodes {
+		parts[i] = n.Value
+		if len(n.Children) > 0 {
+			parts[i] += "(" + outline(n.Children) + ")"
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func checkOutline(t *testing.T, tree *Tree[string], want string) {
+	t.Helper()
+	nodes, err := tree.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if got := outline(nodes); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func mustCreate(t *testing.T, tree *Tree[string], agent string, parent causalgraph.LV, index int, value string) causalgraph.LV {
+	t.Helper()
+	lv, err := tree.Create(agent, parent, index, value)
+	if err != nil {
+		t.Fatalf("Create(%s) failed: %v", value, err)
+	}
+	return lv
+}
+
+func TestTree_Local(t *testing.T) {
+	tree := NewTree[string](causalgraph.CreateCG())
+	a := mustCreate(t, tree, "alice", -1, 0, "a")
+	b := mustCreate(t, tree, "alice", -1, 1, "b")
+	mustCreate(t, tree, "alice", a, 0, "a2")
+	a1 := mustCreate(t, tree, "alice", a, 0, "a1")
+	checkOutline(t, tree, "a(a1,a2),b")
+	v1 := tree.log.cg.Heads
+
+	if _, err := tree.Move("alice", a1, b, 0); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	checkOutline(t, tree, "a(a2),b(a1)")
+	if _, err := tree.Move("alice", b, -1, 0); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	checkOutline(t, tree, "b(a1),a(a2)")
+	if p, err := tree.Parent(a1); err != nil || p != b {
+		t.Errorf("Parent = %d, %v; want %d", p, err, b)
+	}
+	if _, err := tree.Move("alice", b, a1, 0); err == nil {
+		t.Error("expected an error moving a node under itself")
+	}
+
+	tree.Delete("alice", b)
+	checkOutline(t, tree, "a(a2)")
+	if _, err := tree.Children(a1); err == nil {
+		t.Error("expected an error for a node under a deleted one")
+	}
+	if children, _ := tree.Children(-1); len(children) != 1 || children[0] != a {
+		t.Errorf("Children = %v", children)
+	}
+
+	nodes, err := tree.Checkout(v1)
+	if err != nil {
+		t.Fatalf("Checkout failed: %v", err)
+	}
+	if got := outline(nodes); got != "a(a1,a2),b" {
+		t.Errorf("checkout: got %s", got)
+	}
+}
+
+func TestTree_ConcurrentMovesAvoidCycles(t *testing.T) {
+	x := NewTree[string](causalgraph.CreateCG())
+	a := mustCreate(t, x, "alice", -1, 0, "a")
+	b := mustCreate(t, x, "alice", -1, 1, "b")
+	y := NewTree[string](causalgraph.CreateCG())
+	syncTrees(t, y, x)
+
+	// Each alone is fine, but together they would make a cycle. Alice's
+	// move comes first in the causal order, so Bob's is skipped.
+	x.Move("alice", a, b, 0)
+	y.Move("bob", b, a, 0)
+	syncTrees(t, x, y)
+	syncTrees(t, y, x)
+	checkOutline(t, x, "b(a)")
+	checkOutline(t, y, "b(a)")
+
+	// Concurrent moves of the same node put it in one place.
+	c := mustCreate(t, x, "alice", -1, 1, "c")
+	syncTrees(t, y, x)
+	x.Move("alice", c, a, 0)
+	y.Move("bob", c, b, 0)
+	syncTrees(t, x, y)
+	syncTrees(t, y, x)
+	checkOutline(t, x, "b(c,a)")
+	checkOutline(t, y, "b(c,a)")
+}
+
+func TestTree_DeleteWithConcurrentMoveOut(t *testing.T) {
+	x := NewTree[string](causalgraph.CreateCG())
+	a := mustCreate(t, x, "alice", -1, 0, "a")
+	a1 := mustCreate(t, x, "alice", a, 0, "a1")
+	mustCreate(t, x, "alice", a, 1, "a2")
+	y := NewTree[string](causalgraph.CreateCG())
+	syncTrees(t, y, x)
+
+	x.Delete("alice", a)
+	y.Move("bob", a1, -1, 0)
+	y.Create("bob", -1, 1, "b")
+	syncTrees(t, x, y)
+	syncTrees(t, y, x)
+	checkOutline(t, x, "a1,b")
+	checkOutline(t, y, "a1,b")
+}
+
+func TestTree_ApplyRemoteErrors(t *testing.T) {
+	tree := NewTree[string](causalgraph.CreateCG())
+	mustCreate(t, tree, "alice", -1, 0, "a")
+	bad := TreeSpan[string]{
+		ID:      causalgraph.RawVersion{Agent: "bob", Seq: 0},
+		Parents: []causalgraph.RawVersion{},
+		Ops:     []TreeOp[string]{{Type: TreeOpDelete, Node: causalgraph.RawVersion{Agent: "alice", Seq: 0}}},
+	}
+	if err := tree.ApplyRemote(bad); err == nil {
+		t.Error("expected an error deleting a node outside the span's history")
+	}
+	bad.Ops = []TreeOp[string]{{Type: "rename"}}
+	if err := tree.ApplyRemote(bad); err == nil {
+		t.Error("expected an error for an unknown operation type")
+	}
+}
+
+func TestTree_ApplyRemote_InvalidPos(t *testing.T) {
+	tree := NewTree[string](causalgraph.CreateCG())
+	mustCreate(t, tree, "alice", -1, 0, "a")
+	parents := []causalgraph.RawVersion{{Agent: "alice", Seq: 0}}
+
+	for _, pos := range []int{5, -1} {
+		span := TreeSpan[string]{
+			ID:      causalgraph.RawVersion{Agent: "bob", Seq: 0},
+			Parents: parents,
+			Ops: []TreeOp[string]{
+				{Type: TreeOpCreate, Pos: 1, Value: "b"},
+				{Type: TreeOpCreate, Pos: pos, Value: "c"},
+			},
+		}
+		if err := tree.ApplyRemote(span); !errors.Is(err, ErrInvalidOp) {
+			t.Fatalf("Pos %d: expected ErrInvalidOp, got %v", pos, err)
+		}
+		checkOutline(t, tree, "a")
+		if n := causalgraph.NextSeqForAgent(tree.log.cg, "bob"); n != 0 {
+			t.Errorf("Pos %d: rejected span was added to the graph (next seq %d)", pos, n)
+		}
+	}
+
+	span := TreeSpan[string]{
+		ID:      causalgraph.RawVersion{Agent: "bob", Seq: 0},
+		Parents: parents,
+		Ops:     []TreeOp[string]{{Type: TreeOpCreate, Pos: 1, Value: "b"}},
+	}
+	if err := tree.ApplyRemote(span); err != nil {
+		t.Fatalf("ApplyRemote failed: %v", err)
+	}
+	checkOutline(t, tree, "a,b")
+}
+
+// flatten returns the LVs of nodes and of every node under them.
+func flatten(nodes []*TreeNode[string]) []causalgraph.LV {
+	var lvs []causalgraph.LV
+	for _, n := range nodes {
+		lvs = append(lvs, n.LV)
+		lvs = append(lvs, flatten(n.Children)...)
+	}
+	return lvs
+}
+
+func TestTree_Random(t *testing.T) {
+	type checkpoint struct {
+		version []causalgraph.LV
+		outline string
+	}
+	rng := rand.New(rand.NewPCG(7, 11))
+	agents := []string{"alice", "bob", "carol"}
+	trees := make([]*Tree[string], len(agents))
+	checkpoints := make([][]checkpoint, len(agents))
+	for i := range trees {
+		trees[i] = NewTree[string](causalgraph.CreateCG())
+	}
+	for step := 0; step < 300; step++ {
+		i := rng.IntN(len(trees))
+		tree := trees[i]
+		if rng.IntN(5) == 0 {
+			syncTrees(t, tree, trees[rng.IntN(len(trees))])
+		} else {
+			nodes, err := tree.Snapshot()
+			if err != nil {
+				t.Fatalf("Snapshot failed: %v", err)
+			}
+			all := flatten(nodes)
+			parent := causalgraph.LV(-1)
+			if len(all) > 0 && rng.IntN(2) == 0 {
+				parent = all[rng.IntN(len(all))]
+			}
+			children, err := tree.Children(parent)
+			if err != nil {
+				t.Fatalf("Children failed: %v", err)
+			}
+			switch op := rng.IntN(4); {
+			case op < 2 || len(all) == 0:
+				mustCreate(t, tree, agents[i], parent, rng.IntN(len(children)+1), fmt.Sprint(step))
+			case op == 2:
+				// Moves under the node itself are rejected; skip them.
+				node := all[rng.IntN(len(all))]
+				n := len(children)
+				if slices.Contains(children, node) {
+					n--
+				}
+				tree.Move(agents[i], node, parent, rng.IntN(n+1))
+			default:
+				if _, err := tree.Delete(agents[i], all[rng.IntN(len(all))]); err != nil {
+					t.Fatalf("Delete failed: %v", err)
+				}
+			}
+		}
+
+		state, err := tree.state()
+		if err != nil {
+			t.Fatalf("state failed: %v", err)
+		}
+		history, err := tree.log.history(tree.log.cg.Heads)
+		if err != nil {
+			t.Fatalf("history failed: %v", err)
+		}
+		if want := tree.stateAt(history); !reflect.DeepEqual(state, want) {
+			t.Fatalf("step %d: cached state %+v differs from recomputed %+v", step, state, want)
+		}
+		nodes, err := tree.Snapshot()
+		if err != nil {
+			t.Fatalf("Snapshot failed: %v", err)
+		}
+		version := slices.Clone(tree.log.cg.Heads)
+		checkpoints[i] = append(checkpoints[i], checkpoint{version, outline(nodes)})
+	}
+
+	// Checking out an older version replays the history rather than reading
+	// the lists kept at the heads.
+	for i, tree := range trees {
+		for _, cp := range checkpoints[i] {
+			nodes, err := tree.Checkout(cp.version)
+			if err != nil {
+				t.Fatalf("Checkout(%v) failed: %v", cp.version, err)
+			}
+			if got := outline(nodes); got != cp.outline {
+				t.Errorf("%s: Checkout(%v) = %s, want %s", agents[i], cp.version, got, cp.outline)
+			}
+		}
+	}
+}
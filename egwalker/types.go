@@ -8,6 +8,8 @@ type ListOpType string
 const (
 	ListOpTypeInsert ListOpType = "ins"
 	ListOpTypeDelete ListOpType = "del"
+	// ListOpTypeMove moves the element at From so that it ends up at Pos.
+	ListOpTypeMove ListOpType = "mov"
 )
 
 // ListOp represents a single operation on a list.
@@ -15,7 +17,8 @@ const (
 type ListOp[T any] struct {
 	Type    ListOpType
 	Pos     int
-	Content T // Only used for insert operations.
+	Content T   // Only used for insert operations.
+	From    int // Position of the element moved. Only used for move operations.
 }
 
 // ListOpLog holds the sequence of operations and their causal relationships.
@@ -27,6 +30,15 @@ type ListOpLog[T any] struct {
 	CG causalgraph.CausalGraph
 }
 
+// RemoteSpan is a run of consecutive operations by a single agent, in the form
+// exchanged between peers. The operation at index i has sequence number ID.Seq+i,
+// and each operation after the first has the previous one as its only parent.
+type RemoteSpan[T any] struct {
+	ID      causalgraph.RawVersion
+	Parents []causalgraph.RawVersion // Parents of the first operation in the span.
+	Ops     []ListOp[T]
+}
+
 // ItemState represents the state of an item during merging.
 type ItemState int
 
@@ -68,6 +80,11 @@ type EditContext struct {
 	// DelTargets maps the LV of a delete operation to the LV of the item it deletes.
 	// delTarget[delLV] = targetLV.
 	DelTargets map[causalgraph.LV]causalgraph.LV // Using a map for sparse LVs
+	// MoveTargets maps the LV of a move operation to the LV of the insert of
+	// the element it moves. See move.go.
+	MoveTargets map[causalgraph.LV]causalgraph.LV
+	// moved tracks the position items of every element which has been moved.
+	moved map[causalgraph.LV]*moveSet
 	// ItemsByLV provides quick access to items by their OpID (LV).
 	ItemsByLV map[causalgraph.LV]*Item // Using a map for sparse LVs
 	// CurVersion is the current version (frontier) of the EditContext.
@@ -86,5 +103,21 @@ type Branch[T any] struct {
 type Walker[T any] struct {
 	Log *ListOpLog[T]
 	Ctx *EditContext
-	// TODO: Add other fields as needed, e.g., for caching or specific algorithms.
+	// content is the current document, updated incrementally as ops are applied.
+	content Rope[T]
+
+	// observers are notified of every change, in subscription order.
+	observers      []observer[T]
+	nextObserverID int
+	// changes collects the positional changes of the mutation in progress
+	// while there are observers.
+	changes []ListOp[T]
+}
+
+// View is an immutable snapshot of a document at a version.
+// It shares structure with the document it was taken from, so taking a View is
+// cheap and holding one does not block or slow down writers.
+type View[T any] struct {
+	Content Rope[T]
+	Version []causalgraph.LV
 }
@@ -0,0 +1,450 @@
+package egwalker
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/JonyBepary/go-eg-walker/causalgraph"
+)
+
+// UndoOptions configures an UndoManager.
+type UndoOptions struct {
+	// CaptureTimeout groups local edits made within this long of the previous
+	// one into a single undo step. Zero makes every edit its own step.
+	CaptureTimeout time.Duration
+	// Now returns the current time. It defaults to time.Now and can be replaced
+	// in tests.
+	Now func() time.Time
+}
+
+// UndoManager provides undo and redo of one agent's edits to a Walker.
+//
+// Undo never rewinds history: it generates new operations which delete the
+// items a step inserted, move the elements it moved back and reinsert the
+// content it deleted next to their surviving neighbours, as one atomic span.
+// Edits made by other agents since are kept, so each user only undoes their
+// own work.
+type UndoManager[T any] struct {
+	w     *Walker[T]
+	agent string
+	opts  UndoOptions
+
+	undoStack [][]causalgraph.LV
+	redoStack [][]causalgraph.LV
+	// lastEdit is when the top of undoStack was last extended.
+	lastEdit time.Time
+	// capturing reports whether the next edit may join the top of undoStack.
+	capturing bool
+	// replaced maps a deleted item to the item which reinserted its content,
+	// so later steps touching the original act on its replacement.
+	replaced map[causalgraph.LV]causalgraph.LV
+}
+
+// NewUndoManager returns an undo manager for agent's edits to w. Edits must be
+// made through the manager, or passed to Record, to be undoable.
+func NewUndoManager[T any](w *Walker[T], agent string, opts UndoOptions) *UndoManager[T] {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &UndoManager[T]{w: w, agent: agent, opts: opts, replaced: make(map[causalgraph.LV]causalgraph.LV)}
+}
+
+// Insert inserts content at pos on behalf of the manager's agent and records
+// it for undo.
+func (u *UndoManager[T]) Insert(pos int, content T) (causalgraph.LV, error) {
+	lv, err := u.w.LocalInsert(u.agent, pos, content)
+	if err != nil {
+		return lv, err
+	}
+	u.Record(lv)
+	return lv, nil
+}
+
+// Delete deletes the item at pos on behalf of the manager's agent and records
+// it for undo.
+func (u *UndoManager[T]) Delete(pos int) (causalgraph.LV, error) {
+	lv, err := u.w.LocalDelete(u.agent, pos)
+	if err != nil {
+		return lv, err
+	}
+	u.Record(lv)
+	return lv, nil
+}
+
+// Move moves the element at from to to on behalf of the manager's agent and
+// records it for undo. See Walker.LocalMove.
+func (u *UndoManager[T]) Move(from, to int) (causalgraph.LV, error) {
+	lv, err := u.w.LocalMove(u.agent, from, to)
+	if err != nil {
+		return lv, err
+	}
+	u.Record(lv)
+	return lv, nil
+}
+
+// Record adds local operations made directly on the walker to the undo
+// history and clears the redo history.
+func (u *UndoManager[T]) Record(lvs ...causalgraph.LV) {
+	if len(lvs) == 0 {
+		return
+	}
+	now := u.opts.Now()
+	if n := len(u.undoStack); n > 0 && u.capturing && now.Sub(u.lastEdit) < u.opts.CaptureTimeout {
+		u.undoStack[n-1] = append(u.undoStack[n-1], lvs...)
+	} else {
+		u.undoStack = append(u.undoStack, append([]causalgraph.LV{}, lvs...))
+	}
+	u.lastEdit = now
+	u.capturing = true
+	u.redoStack = nil
+}
+
+// StopCapturing ends the current undo step, so the next edit starts a new one
+// however soon it is made.
+func (u *UndoManager[T]) StopCapturing() {
+	u.capturing = false
+}
+
+// CanUndo reports whether there is a step to undo.
+func (u *UndoManager[T]) CanUndo() bool {
+	return len(u.undoStack) > 0
+}
+
+// CanRedo reports whether there is an undone step to redo.
+func (u *UndoManager[T]) CanRedo() bool {
+	return len(u.redoStack) > 0
+}
+
+// Undo reverts the most recent step. It reports false if there was nothing to undo.
+func (u *UndoManager[T]) Undo() (bool, error) {
+	if len(u.undoStack) == 0 {
+		return false, nil
+	}
+	step := u.undoStack[len(u.undoStack)-1]
+	lvs, err := u.invert(step)
+	if err != nil {
+		return false, fmt.Errorf("undo: %w", err)
+	}
+	u.undoStack = u.undoStack[:len(u.undoStack)-1]
+	u.redoStack = append(u.redoStack, lvs)
+	u.capturing = false
+	return true, nil
+}
+
+// Redo reapplies the most recently undone step. It reports false if there
+// was nothing to redo.
+func (u *UndoManager[T]) Redo() (bool, error) {
+	if len(u.redoStack) == 0 {
+		return false, nil
+	}
+	step := u.redoStack[len(u.redoStack)-1]
+	lvs, err := u.invert(step)
+	if err != nil {
+		return false, fmt.Errorf("redo: %w", err)
+	}
+	u.redoStack = u.redoStack[:len(u.redoStack)-1]
+	u.undoStack = append(u.undoStack, lvs)
+	u.capturing = false
+	return true, nil
+}
+
+// invert commits, as a single span, operations reverting the effect of step
+// on the current document and returns their LVs. If it fails, nothing is
+// committed.
+//
+// Items the step inserted are deleted. Elements it moved are moved back and
+// content it deleted is reinserted, each just after the nearest item to its
+// left, just before the step first moved or deleted it, which still holds
+// its content, or at the start if there is none. Content is looked up
+// through replaced, since content restored by an earlier undo lives in new
+// items, which need not sit where the originals did.
+func (u *UndoManager[T]) invert(step []causalgraph.LV) ([]causalgraph.LV, error) {
+	ctx := u.w.Ctx
+	inStep := make(map[causalgraph.LV]bool, len(step))
+	inserted := make(map[causalgraph.LV]bool)
+	for _, lv := range step {
+		inStep[lv] = true
+		if u.w.Log.Ops[lv].Type == ListOpTypeInsert {
+			inserted[lv] = true
+		}
+	}
+	// removedBy maps each element to put back to the operation which first
+	// took it from its place: its delete, or the step's first move of it.
+	var targets []causalgraph.LV
+	removedBy := make(map[causalgraph.LV]causalgraph.LV)
+	reinsert := make(map[causalgraph.LV]bool)
+	for _, lv := range step {
+		// Content inserted and deleted within the same step has no net effect.
+		target, ok := ctx.DelTargets[lv]
+		if _, seen := removedBy[target]; ok && !seen && !inserted[target] {
+			targets = append(targets, target)
+			removedBy[target] = lv
+			reinsert[target] = true
+		}
+	}
+	for i := len(step) - 1; i >= 0; i-- {
+		elem, ok := ctx.MoveTargets[step[i]]
+		if !ok || inserted[elem] {
+			continue
+		}
+		if _, seen := removedBy[elem]; !seen {
+			winner := ctx.moved[elem].curWinner
+			if raw, _ := causalgraph.LVToRaw(&u.w.Log.CG, winner); !inStep[winner] && string(raw.Agent) != u.agent {
+				continue // Moved again since by someone else.
+			}
+			targets = append(targets, elem)
+		}
+		removedBy[elem] = step[i]
+	}
+
+	doc := newUndoDoc(ctx)
+	anchors, lefts, err := u.neighbours(doc, targets, removedBy, inserted)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(targets, func(a, b causalgraph.LV) int { return anchors[a] - anchors[b] })
+
+	// restored lists the item each reinsertion replaces and the index of
+	// the reinsertion among the span's operations.
+	type restore struct {
+		item causalgraph.LV
+		op   int
+	}
+	var restored []restore
+	r, err := u.w.Transact(u.agent, func(tx *Tx[T]) error {
+		for i := len(step) - 1; i >= 0; i-- {
+			if !inserted[step[i]] {
+				continue
+			}
+			it := doc.byLV[ctx.itemOf(u.resolve(step[i]))]
+			if !it.visible {
+				continue // Already deleted, by this step or by someone else.
+			}
+			if err := tx.Delete(doc.pos(it)); err != nil {
+				return err
+			}
+			it.visible = false
+		}
+
+		// placed maps elements put back by this span to the items holding
+		// them in doc.
+		placed := make(map[causalgraph.LV]*undoItem)
+		for _, target := range targets {
+			cur := doc.byLV[ctx.itemOf(u.resolve(target))]
+			if reinsert[target] == cur.visible {
+				// Content already restored by another step, or a moved
+				// element deleted since.
+				continue
+			}
+			var left *undoItem
+			for _, lv := range lefts[target] {
+				elem := ctx.element(lv)
+				it, ok := placed[elem]
+				if !ok {
+					it = doc.byLV[ctx.itemOf(u.resolve(elem))]
+				}
+				if it.visible {
+					left = it
+					break
+				}
+			}
+			pos, at := 0, 0
+			if left != nil {
+				pos, at = doc.pos(left)+1, doc.index(left)+1
+			}
+			if !reinsert[target] {
+				from := doc.pos(cur)
+				if from < pos {
+					pos-- // The element no longer counts once it is moved.
+				}
+				if from == pos {
+					placed[target] = cur
+					continue
+				}
+				if err := tx.Move(from, pos); err != nil {
+					return err
+				}
+				cur.visible = false
+				it := &undoItem{lv: -1, visible: true}
+				doc.insert(at, it)
+				placed[target] = it
+				continue
+			}
+			if err := tx.Insert(pos, u.w.Log.Ops[target].Content); err != nil {
+				return err
+			}
+			it := &undoItem{lv: -1, visible: true}
+			doc.insert(at, it)
+			placed[target] = it
+			restored = append(restored, restore{u.resolve(target), len(tx.ops) - 1})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, re := range restored {
+		u.replaced[re.item] = r.Start + causalgraph.LV(re.op)
+	}
+	var lvs []causalgraph.LV
+	for lv := r.Start; lv < r.End; lv++ {
+		lvs = append(lvs, lv)
+	}
+	return lvs, nil
+}
+
+// neighbours looks at the document just before removedBy[target] took each
+// of targets from its place. It returns the index in Items at which the
+// target then stood, and the items then visible to its left, nearest first,
+// up to the first which still holds its content in doc, the current
+// document, or is where another target stood. Items of deleted elements and
+// other items of targets are left out. The context is back at the graph
+// heads when it returns.
+func (u *UndoManager[T]) neighbours(doc *undoDoc, targets []causalgraph.LV, removedBy map[causalgraph.LV]causalgraph.LV, deleted map[causalgraph.LV]bool) (map[causalgraph.LV]int, map[causalgraph.LV][]causalgraph.LV, error) {
+	ctx, cg := u.w.Ctx, &u.w.Log.CG
+	isTarget := make(map[causalgraph.LV]bool, len(targets))
+	for _, target := range targets {
+		isTarget[target] = true
+	}
+	byRemoval := slices.Clone(targets)
+	// Visited in log order, so the context moves a little at a time.
+	slices.SortFunc(byRemoval, func(a, b causalgraph.LV) int { return int(removedBy[a] - removedBy[b]) })
+	anchors := make(map[causalgraph.LV]int, len(targets))
+	lefts := make(map[causalgraph.LV][]causalgraph.LV, len(targets))
+	// The anchors are found first, so targets removed from the document
+	// before another target can still serve as its neighbours.
+	for _, target := range byRemoval {
+		_, _, parents, _ := causalgraph.LVToRawWithParents(cg, removedBy[target])
+		if err := ctx.moveTo(cg, parents); err != nil {
+			return nil, nil, err
+		}
+		idx := slices.IndexFunc(ctx.Items, func(item Item) bool {
+			return item.CurState == Inserted && ctx.element(item.OpID) == target
+		})
+		if idx < 0 {
+			// Not visible there, if someone else removed it first; fall
+			// back to where it stands now.
+			idx = doc.index(doc.byLV[ctx.itemOf(target)])
+		}
+		anchors[target] = idx
+	}
+	for _, target := range byRemoval {
+		_, _, parents, _ := causalgraph.LVToRawWithParents(cg, removedBy[target])
+		if err := ctx.moveTo(cg, parents); err != nil {
+			return nil, nil, err
+		}
+		for j := anchors[target] - 1; j >= 0; j-- {
+			item := ctx.Items[j]
+			elem := ctx.element(item.OpID)
+			if anchor, ok := anchors[elem]; ok && anchor == j {
+				lefts[target] = append(lefts[target], item.OpID)
+				break
+			}
+			if item.CurState != Inserted || deleted[elem] || isTarget[elem] {
+				continue
+			}
+			lefts[target] = append(lefts[target], item.OpID)
+			if doc.byLV[ctx.itemOf(u.resolve(elem))].visible {
+				break
+			}
+		}
+	}
+	if err := ctx.moveTo(cg, cg.Heads); err != nil {
+		return nil, nil, err
+	}
+	return anchors, lefts, nil
+}
+
+// undoItem is an item of the document as left by the operations of an
+// inverse built so far, so positions can be worked out before anything is
+// committed.
+type undoItem struct {
+	lv      causalgraph.LV // LV of the item in the context, or -1 for a new one.
+	visible bool
+}
+
+// undoDoc holds the items of the current document, in order, while an
+// inverse is built.
+type undoDoc struct {
+	items []*undoItem
+	byLV  map[causalgraph.LV]*undoItem
+}
+
+func newUndoDoc(ctx *EditContext) *undoDoc {
+	d := &undoDoc{
+		items: make([]*undoItem, len(ctx.Items)),
+		byLV:  make(map[causalgraph.LV]*undoItem, len(ctx.Items)),
+	}
+	for i, item := range ctx.Items {
+		d.items[i] = &undoItem{lv: item.OpID, visible: item.CurState == Inserted}
+		d.byLV[item.OpID] = d.items[i]
+	}
+	return d
+}
+
+// index returns the index of it among the items.
+func (d *undoDoc) index(it *undoItem) int {
+	return slices.Index(d.items, it)
+}
+
+// pos returns the number of visible items before it.
+func (d *undoDoc) pos(it *undoItem) int {
+	n := 0
+	for _, other := range d.items {
+		if other == it {
+			break
+		}
+		if other.visible {
+			n++
+		}
+	}
+	return n
+}
+
+// insert adds it at index i.
+func (d *undoDoc) insert(i int, it *undoItem) {
+	d.items = slices.Insert(d.items, i, it)
+}
+
+// resolve follows replaced from the item inserted at lv to the item which
+// currently holds its content.
+func (u *UndoManager[T]) resolve(lv causalgraph.LV) causalgraph.LV {
+	for {
+		next, ok := u.replaced[lv]
+		if !ok {
+			return lv
+		}
+		lv = next
+	}
+}
+
+// curPos returns the number of items visible in the current version before
+// the element inserted at lv, and whether that element is itself visible.
+func (ctx *EditContext) curPos(lv causalgraph.LV) (pos int, visible bool, err error) {
+	return ctx.itemCurPos(ctx.itemOf(lv))
+}
+
+// itemOf returns the item at which the element inserted at lv currently
+// stands: the winning move's, if it was moved.
+func (ctx *EditContext) itemOf(lv causalgraph.LV) causalgraph.LV {
+	if ms, ok := ctx.moved[lv]; ok {
+		return ms.curWinner
+	}
+	return lv
+}
+
+// itemCurPos returns the number of items visible in the current version
+// before the item created by lv, and whether that item is itself visible.
+// Unlike curPos, it does not follow moves.
+func (ctx *EditContext) itemCurPos(lv causalgraph.LV) (pos int, visible bool, err error) {
+	for _, item := range ctx.Items {
+		if item.OpID == lv {
+			return pos, item.CurState == Inserted, nil
+		}
+		if item.CurState == Inserted {
+			pos++
+		}
+	}
+	return -1, false, fmt.Errorf("item for LV %d not found in Items", lv)
+}
@@ -0,0 +1,240 @@
+package egwalker
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for undo capture tests.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func typeString(t *testing.T, u *UndoManager[string], pos int, s string) {
+	t.Helper()
+	for i, r := range s {
+		if _, err := u.Insert(pos+i, string(r)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+}
+
+func joined(w *Walker[string]) string {
+	return strings.Join(w.GetActiveItems(), "")
+}
+
+func TestUndoManager_UndoRedo(t *testing.T) {
+	w := NewWalker[string]()
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	u := NewUndoManager(w, "alice", UndoOptions{CaptureTimeout: 500 * time.Millisecond, Now: clock.now})
+
+	if ok, err := u.Undo(); ok || err != nil {
+		t.Errorf("Undo on empty history: got %t, %v", ok, err)
+	}
+
+	typeString(t, u, 0, "hello") // Typed quickly: one step.
+	clock.t = clock.t.Add(time.Second)
+	typeString(t, u, 5, " world")
+	clock.t = clock.t.Add(time.Second)
+	for i := 0; i < 5; i++ { // Select "hello" and delete it.
+		if _, err := u.Delete(0); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	if got := joined(w); got != " world" {
+		t.Fatalf("got %q, want %q", got, " world")
+	}
+
+	steps := []string{"hello world", "hello", ""}
+	for _, want := range steps {
+		if ok, err := u.Undo(); !ok || err != nil {
+			t.Fatalf("Undo: got %t, %v", ok, err)
+		}
+		if got := joined(w); got != want {
+			t.Errorf("after undo: got %q, want %q", got, want)
+		}
+	}
+	if u.CanUndo() {
+		t.Error("expected nothing left to undo")
+	}
+
+	for _, want := range []string{"hello", "hello world", " world"} {
+		if ok, err := u.Redo(); !ok || err != nil {
+			t.Fatalf("Redo: got %t, %v", ok, err)
+		}
+		if got := joined(w); got != want {
+			t.Errorf("after redo: got %q, want %q", got, want)
+		}
+	}
+	if u.CanRedo() {
+		t.Error("expected nothing left to redo")
+	}
+
+	// A new edit clears the redo history.
+	u.Undo()
+	typeString(t, u, 0, "!")
+	if u.CanRedo() {
+		t.Error("expected redo history to be cleared by a new edit")
+	}
+}
+
+func TestUndoManager_StopCapturing(t *testing.T) {
+	w := NewWalker[string]()
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	u := NewUndoManager(w, "alice", UndoOptions{CaptureTimeout: time.Hour, Now: clock.now})
+
+	typeString(t, u, 0, "ab")
+	u.StopCapturing()
+	typeString(t, u, 2, "cd")
+	u.Undo()
+	if got := joined(w); got != "ab" {
+		t.Errorf("got %q, want %q", got, "ab")
+	}
+}
+
+func TestUndoManager_KeepsOtherAgentsEdits(t *testing.T) {
+	a := NewWalker[string]()
+	ua := NewUndoManager(a, "alice", UndoOptions{})
+	typeString(t, ua, 0, "ac")
+
+	b := NewWalker[string]()
+	syncWalkers(t, b, a)
+	ub := NewUndoManager(b, "bob", UndoOptions{})
+	typeString(t, ub, 1, "b")
+	if _, err := ub.Delete(2); err != nil { // bob deletes "c"
+		t.Fatalf("Delete failed: %v", err)
+	}
+	syncWalkers(t, a, b)
+	if got := joined(a); got != "ab" {
+		t.Fatalf("got %q, want %q", got, "ab")
+	}
+
+	// Alice's last step typed "c"; bob already deleted it, so undo is a no-op there.
+	ua.Undo()
+	if got := joined(a); got != "ab" {
+		t.Errorf("got %q, want %q", got, "ab")
+	}
+	// Undoing alice's "a" keeps bob's "b".
+	ua.Undo()
+	if got := joined(a); got != "b" {
+		t.Errorf("got %q, want %q", got, "b")
+	}
+
+	// Bob undoes his delete of "c" at its current position.
+	syncWalkers(t, b, a)
+	ub.Undo()
+	if got := joined(b); got != "bc" {
+		t.Errorf("got %q, want %q", got, "bc")
+	}
+	syncWalkers(t, a, b)
+	if !reflect.DeepEqual(a.GetActiveItems(), b.GetActiveItems()) {
+		t.Errorf("peers diverged: %v vs %v", a.GetActiveItems(), b.GetActiveItems())
+	}
+}
+
+func TestUndoManager_Move(t *testing.T) {
+	w := NewWalker[string]()
+	u := NewUndoManager(w, "alice", UndoOptions{})
+	typeString(t, u, 0, "abcd")
+	if _, err := u.Move(0, 3); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	if got := joined(w); got != "bcda" {
+		t.Fatalf("got %q, want %q", got, "bcda")
+	}
+	u.Undo()
+	if got := joined(w); got != "abcd" {
+		t.Errorf("after undo: got %q, want %q", got, "abcd")
+	}
+	u.Redo()
+	if got := joined(w); got != "bcda" {
+		t.Errorf("after redo: got %q, want %q", got, "bcda")
+	}
+
+	// Content moved and then deleted in one step comes back where it was
+	// before the move.
+	move, _ := w.LocalMove("alice", 0, 2) // "cdba"
+	del, _ := w.LocalDelete("alice", 2)   // "cda"
+	u.Record(move, del)
+	u.Undo()
+	if got := joined(w); got != "bcda" {
+		t.Errorf("after undoing move and delete: got %q, want %q", got, "bcda")
+	}
+
+	// A move which another agent's move overrode is left alone.
+	if _, err := u.Move(0, 1); err != nil { // "cbda"
+		t.Fatalf("Move failed: %v", err)
+	}
+	w.LocalMove("bob", 1, 3) // "cdab"
+	u.Undo()
+	if got := joined(w); got != "cdab" {
+		t.Errorf("after undoing an overridden move: got %q, want %q", got, "cdab")
+	}
+}
+
+func TestUndoManager_UndoDeletesInOrder(t *testing.T) {
+	w := NewWalker[string]()
+	u := NewUndoManager(w, "alice", UndoOptions{})
+	typeString(t, u, 0, "ab")
+	for i := 0; i < 2; i++ { // Two steps, each deleting the first character.
+		if _, err := u.Delete(0); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	for _, want := range []string{"b", "ab"} {
+		if _, err := u.Undo(); err != nil {
+			t.Fatalf("Undo failed: %v", err)
+		}
+		if got := joined(w); got != want {
+			t.Errorf("after undo: got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestUndoManager_Random(t *testing.T) {
+	for seed := uint64(0); seed < 200; seed++ {
+		rng := rand.New(rand.NewPCG(seed, 1))
+		w := NewWalker[string]()
+		u := NewUndoManager(w, "alice", UndoOptions{})
+		// Each edit is its own step, so undo and redo go back and forth
+		// between the documents seen so far.
+		var undos, redos []string
+		for i := 0; i < 60; i++ {
+			before := joined(w)
+			n := len(before)
+			want := ""
+			var err error
+			switch op := rng.IntN(6); {
+			case op == 0 && len(undos) > 0:
+				want = undos[len(undos)-1]
+				undos, redos = undos[:len(undos)-1], append(redos, before)
+				_, err = u.Undo()
+			case op == 1 && len(redos) > 0:
+				want = redos[len(redos)-1]
+				undos, redos = append(undos, before), redos[:len(redos)-1]
+				_, err = u.Redo()
+			case op == 2 && n > 0:
+				undos, redos = append(undos, before), nil
+				_, err = u.Delete(rng.IntN(n))
+				want = joined(w)
+			case op == 3 && n > 1:
+				undos, redos = append(undos, before), nil
+				_, err = u.Move(rng.IntN(n), rng.IntN(n))
+				want = joined(w)
+			default:
+				undos, redos = append(undos, before), nil
+				_, err = u.Insert(rng.IntN(n+1), string(rune('a'+i%26)))
+				want = joined(w)
+			}
+			if err != nil {
+				t.Fatalf("seed %d, step %d: %v", seed, i, err)
+			}
+			if got := joined(w); got != want {
+				t.Fatalf("seed %d, step %d: got %q, want %q", seed, i, got, want)
+			}
+		}
+	}
+}